@@ -0,0 +1,79 @@
+package cloudio
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/flynnletford/icp-go/kitti"
+	"github.com/flynnletford/icp-go/las"
+	"github.com/flynnletford/icp-go/pcd"
+	"github.com/flynnletford/icp-go/ply"
+	"github.com/flynnletford/icp-go/point"
+	"github.com/flynnletford/icp-go/xyz"
+)
+
+func init() {
+	Register(&Codec{
+		Name:       "ply",
+		Extensions: []string{".ply"},
+		Magic: func(header []byte) bool {
+			return bytes.HasPrefix(header, []byte("ply\n")) || bytes.HasPrefix(header, []byte("ply\r\n"))
+		},
+		Decode: func(r io.Reader) (*point.Points3D, error) { return ply.Decode(r, nil) },
+		Encode: func(w io.Writer, points *point.Points3D) error {
+			return ply.Encode(w, points, &ply.WriteOptions{Format: ply.BinaryLittleEndian})
+		},
+	})
+
+	Register(&Codec{
+		Name:       "pcd",
+		Extensions: []string{".pcd"},
+		Magic: func(header []byte) bool {
+			for _, prefix := range []string{"# .PCD", "VERSION", "FIELDS"} {
+				if bytes.HasPrefix(header, []byte(prefix)) {
+					return true
+				}
+			}
+			return false
+		},
+		Decode: func(r io.Reader) (*point.Points3D, error) { return pcd.Decode(r, nil) },
+		Encode: func(w io.Writer, points *point.Points3D) error { return pcd.Encode(w, points, nil) },
+	})
+
+	Register(&Codec{
+		Name:       "las",
+		Extensions: []string{".las"},
+		Magic:      func(header []byte) bool { return bytes.HasPrefix(header, []byte("LASF")) },
+		Decode:     func(r io.Reader) (*point.Points3D, error) { return las.Decode(r, nil) },
+		Encode:     func(w io.Writer, points *point.Points3D) error { return las.Encode(w, points, nil) },
+	})
+
+	Register(&Codec{
+		Name:       "xyz",
+		Extensions: []string{".xyz", ".txt", ".pts", ".asc"},
+		Decode:     func(r io.Reader) (*point.Points3D, error) { return xyz.Decode(r, nil) },
+		Encode:     func(w io.Writer, points *point.Points3D) error { return xyz.Encode(w, points, nil) },
+	})
+
+	Register(&Codec{
+		Name:       "csv",
+		Extensions: []string{".csv"},
+		Decode:     func(r io.Reader) (*point.Points3D, error) { return xyz.Decode(r, nil) },
+		Encode:     func(w io.Writer, points *point.Points3D) error { return xyz.Encode(w, points, xyz.CSVWriteOptions) },
+	})
+
+	Register(&Codec{
+		Name:       "tsv",
+		Extensions: []string{".tsv"},
+		Decode:     func(r io.Reader) (*point.Points3D, error) { return xyz.Decode(r, nil) },
+		Encode: func(w io.Writer, points *point.Points3D) error {
+			return xyz.Encode(w, points, &xyz.WriteOptions{Delimiter: '\t', Header: true})
+		},
+	})
+
+	Register(&Codec{
+		Name:       "kitti",
+		Extensions: []string{".bin"},
+		Decode:     func(r io.Reader) (*point.Points3D, error) { return kitti.DecodeScan(r, nil) },
+	})
+}
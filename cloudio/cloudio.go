@@ -0,0 +1,186 @@
+package cloudio
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// sniffLen is the number of bytes passed to Codec.Magic.
+const sniffLen = 512
+
+// Codec reads and writes one point cloud format.
+type Codec struct {
+	// Unique name of the format, e.g. "ply".
+	Name string
+
+	// File extensions of the format including the dot, e.g. ".ply". Matching is case insensitive.
+	Extensions []string
+
+	// Magic reports whether a stream starting with header holds this format. header holds up to 512 bytes.
+	// Nil for formats which can only be recognised by extension.
+	Magic func(header []byte) bool
+
+	Decode func(r io.Reader) (*point.Points3D, error)
+
+	// Nil for read-only formats.
+	Encode func(w io.Writer, points *point.Points3D) error
+}
+
+var (
+	mu     sync.RWMutex
+	codecs []*Codec
+)
+
+// ErrUnknownFormat is returned when no registered codec matches a file.
+var ErrUnknownFormat = errors.New("unknown point cloud format")
+
+// Register makes a codec available to Load, Save and Decode. Codecs registered later take precedence when
+// they claim the same extension. Register panics if a codec with the same name is already registered.
+func Register(c *Codec) {
+	if c == nil || c.Name == "" || c.Decode == nil {
+		panic("cloudio: Register needs a named codec with a decoder")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, existing := range codecs {
+		if existing.Name == c.Name {
+			panic("cloudio: Register called twice for codec " + c.Name)
+		}
+	}
+
+	codecs = append(codecs, c)
+}
+
+// Lookup returns the codec registered under name, or nil.
+func Lookup(name string) *Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, c := range codecs {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Codecs returns the registered codecs in registration order.
+func Codecs() []*Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return append([]*Codec(nil), codecs...)
+}
+
+// Load reads the point cloud at path, picking the codec from the file contents and falling back to the
+// extension for formats without magic bytes.
+func Load(path string) (*point.Points3D, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	br := bufio.NewReaderSize(file, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+
+	c := byMagic(header)
+	if c == nil {
+		c = byExtension(path)
+	}
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+
+	points, err := c.Decode(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s as %s: %w", path, c.Name, err)
+	}
+
+	return points, nil
+}
+
+// Save writes points to path using the codec registered for its extension, replacing any existing file.
+func Save(path string, points *point.Points3D) error {
+	c := byExtension(path)
+	if c == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, path)
+	}
+	if c.Encode == nil {
+		return fmt.Errorf("format %s is read-only", c.Name)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Encode(file, points); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Decode reads a point cloud from r, which must hold a format with magic bytes. It returns the name of the
+// codec used.
+func Decode(r io.Reader) (*point.Points3D, string, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	header, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	c := byMagic(header)
+	if c == nil {
+		return nil, "", ErrUnknownFormat
+	}
+
+	points, err := c.Decode(br)
+	return points, c.Name, err
+}
+
+func byMagic(header []byte) *Codec {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for i := len(codecs) - 1; i >= 0; i-- {
+		if c := codecs[i]; c.Magic != nil && c.Magic(header) {
+			return c
+		}
+	}
+	return nil
+}
+
+func byExtension(path string) *Codec {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return nil
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for i := len(codecs) - 1; i >= 0; i-- {
+		for _, e := range codecs[i].Extensions {
+			if strings.ToLower(e) == ext {
+				return codecs[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package cloudio
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flynnletford/icp-go/ply"
+	"github.com/flynnletford/icp-go/point"
+	"github.com/flynnletford/icp-go/xyz"
+)
+
+// gridPoints returns bare positions on the millimetre grid, which every format stores exactly.
+func gridPoints() *point.Points3D {
+	points := point.Points3D{
+		{X: 1, Y: 2, Z: 3},
+		{X: -4.5, Y: 0.25, Z: 6},
+		{X: 0, Y: -1, Z: 0.125},
+	}
+	return &points
+}
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, name := range []string{"cloud.ply", "cloud.pcd", "cloud.las", "cloud.xyz", "cloud.csv", "cloud.tsv", "CLOUD.PLY"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			points := gridPoints()
+
+			if err := Save(path, points); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			loaded, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, points) {
+				t.Errorf("got %v, want %v", loaded, points)
+			}
+		})
+	}
+}
+
+func TestLoadPrefersMagicToExtension(t *testing.T) {
+	dir := t.TempDir()
+	points := gridPoints()
+
+	var plyFile bytes.Buffer
+	if err := ply.Encode(&plyFile, points, nil); err != nil {
+		t.Fatalf("ply.Encode: %v", err)
+	}
+	var xyzFile bytes.Buffer
+	if err := xyz.Encode(&xyzFile, points, nil); err != nil {
+		t.Fatalf("xyz.Encode: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		file []byte
+		err  error
+	}{
+		{name: "ply.pcd", file: plyFile.Bytes()},
+		{name: "ply.txt", file: plyFile.Bytes()},
+		{name: "ply", file: plyFile.Bytes()},
+		{name: "points.pts", file: xyzFile.Bytes()},
+		{name: "points.unknown", file: xyzFile.Bytes(), err: ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(path, tt.file, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := Load(path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if tt.err == nil && !reflect.DeepEqual(loaded, points) {
+				t.Errorf("got %v, want %v", loaded, points)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	points := gridPoints()
+
+	var plyFile bytes.Buffer
+	if err := ply.Encode(&plyFile, points, nil); err != nil {
+		t.Fatalf("ply.Encode: %v", err)
+	}
+
+	decoded, name, err := Decode(&plyFile)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if name != "ply" {
+		t.Errorf("got codec %q, want ply", name)
+	}
+	if !reflect.DeepEqual(decoded, points) {
+		t.Errorf("got %v, want %v", decoded, points)
+	}
+
+	if _, _, err := Decode(bytes.NewReader([]byte("1 2 3\n"))); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got error %v decoding text without magic bytes, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if err := Save(filepath.Join(dir, "cloud.unknown"), gridPoints()); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("got error %v saving an unknown extension, want %v", err, ErrUnknownFormat)
+	}
+	if err := Save(filepath.Join(dir, "scan.bin"), gridPoints()); err == nil {
+		t.Errorf("got no error saving to the read-only kitti format")
+	}
+}
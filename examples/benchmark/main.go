@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flynnletford/icp-go/icp"
+	"github.com/flynnletford/icp-go/ply"
+	"github.com/flynnletford/icp-go/point"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// Compares exact and approximate nearest neighbor search for correspondence finding on the sample clouds:
+// the time and accuracy of the searches alone, then the time and result of ICP using them.
+
+// Number of times each registration is run, keeping the fastest.
+const runs = 5
+
+type setting struct {
+	name      string
+	epsilon   float64
+	maxLeaves int
+}
+
+var settings = []setting{
+	{name: "exact"},
+	{name: "epsilon 0.5", epsilon: 0.5},
+	{name: "epsilon 1", epsilon: 1},
+	{name: "epsilon 2", epsilon: 2},
+	{name: "4 leaves", maxLeaves: 4},
+	{name: "2 leaves", maxLeaves: 2},
+	{name: "1 leaf", maxLeaves: 1},
+}
+
+func main() {
+
+	sourceFile := "../../pointCloudFiles/2m.ply"
+	targetFile := "../../pointCloudFiles/1m.ply"
+
+	readOptions := &ply.ReadOptions{CropOptions: point.CropOptions{MinRange: 1.5}}
+
+	source, err := ply.Read(sourceFile, readOptions)
+	if err != nil {
+		log.Fatalf("Failed to read source PLY: %v", err)
+	}
+
+	target, err := ply.Read(targetFile, readOptions)
+	if err != nil {
+		log.Fatalf("Failed to read target PLY: %v", err)
+	}
+
+	compareSearches(source, target)
+	fmt.Println()
+	compareRegistrations(source, target)
+}
+
+// compareSearches matches every source point against the target as the first ICP iteration does.
+func compareSearches(source, target *point.Points3D) {
+	voxelSize := icp.DefaultFilterParams.VoxelSize
+	tree := point.NewKDTree(target, icp.VoxelIndices(target, voxelSize))
+	queries := source.Subset(icp.VoxelIndices(source, voxelSize))
+
+	exact := make([]float64, queries.Len())
+	for i, q := range queries.Raw() {
+		_, exact[i] = tree.Nearest(q.X, q.Y, q.Z)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Search\tTime\tExact matches\tMean extra distance (mm)\tMax extra distance (mm)\t\n")
+
+	for _, s := range settings {
+		var matched int
+		var sumExtra, maxExtra float64
+
+		startTime := time.Now()
+		distances := make([]float64, queries.Len())
+		for i, q := range queries.Raw() {
+			_, distances[i] = tree.NearestApprox(q.X, q.Y, q.Z, s.epsilon, s.maxLeaves)
+		}
+		elapsed := time.Since(startTime)
+
+		for i, d := range distances {
+			extra := math.Sqrt(d) - math.Sqrt(exact[i])
+			if extra == 0 {
+				matched++
+			}
+			sumExtra += extra
+			maxExtra = math.Max(maxExtra, extra)
+		}
+
+		fmt.Fprintf(w, "%s\t%v\t%.1f%%\t%.2f\t%.1f\t\n", s.name, elapsed.Round(time.Microsecond),
+			100*float64(matched)/float64(queries.Len()), 1000*sumExtra/float64(queries.Len()), 1000*maxExtra)
+	}
+
+	w.Flush()
+}
+
+// compareRegistrations runs ICP with each search, comparing the transform found with that of the exact search.
+func compareRegistrations(source, target *point.Points3D) {
+	algorithms := []struct {
+		name     string
+		register func(source, target *point.Points3D, params *icp.Params) (*icp.Result, error)
+	}{
+		{"PointToPlane", icp.PointToPlane},
+		{"PointToPoint", icp.PointToPoint},
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "Algorithm\tSearch\tTime\tTranslation (m)\tTranslation error (mm)\tYaw error (mrad)\t\n")
+
+	for _, algorithm := range algorithms {
+		var reference *transform.Matrix4
+
+		for _, s := range settings {
+			params := *icp.DefaultParams
+			params.ApproxEpsilon = s.epsilon
+			params.ApproxMaxLeaves = s.maxLeaves
+
+			var result *icp.Result
+			for i := 0; i < runs; i++ {
+				r, err := algorithm.register(source.Copy(), target.Copy(), &params)
+				if err != nil {
+					log.Fatalf("%s with %s search failed: %v", algorithm.name, s.name, err)
+				}
+				if result == nil || r.ElapsedTime < result.ElapsedTime {
+					result = r
+				}
+			}
+
+			if reference == nil {
+				reference = result.FinalTransform
+			}
+
+			t := result.FinalTransform.Translation()
+			r := reference.Translation()
+			translationError := math.Sqrt((t.X-r.X)*(t.X-r.X) + (t.Y-r.Y)*(t.Y-r.Y) + (t.Z-r.Z)*(t.Z-r.Z))
+			yawError := transform.EulerFromQuaternion(result.FinalTransform.Quaternion()).Yaw -
+				transform.EulerFromQuaternion(reference.Quaternion()).Yaw
+
+			fmt.Fprintf(w, "%s\t%s\t%v\t%.3f, %.3f, %.3f\t%.2f\t%.2f\t\n", algorithm.name, s.name,
+				result.ElapsedTime.Round(time.Millisecond), t.X, t.Y, t.Z, 1000*translationError, 1000*math.Abs(yawError))
+		}
+	}
+
+	w.Flush()
+}
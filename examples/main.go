@@ -6,6 +6,7 @@ import (
 
 	"github.com/flynnletford/icp-go/icp"
 	"github.com/flynnletford/icp-go/ply"
+	"github.com/flynnletford/icp-go/point"
 	"github.com/team-rocos/go-common/transform"
 )
 
@@ -18,12 +19,15 @@ func main() {
 	sourceFile := "../pointCloudFiles/2m.ply"
 	targetFile := "../pointCloudFiles/1m.ply"
 
-	source, err := ply.Read(sourceFile, false)
+	// Both clouds were captured on the robot, so crop out the returns from its own body.
+	readOptions := &ply.ReadOptions{CropOptions: point.CropOptions{MinRange: 1.5}}
+
+	source, err := ply.Read(sourceFile, readOptions)
 	if err != nil {
 		log.Fatalf("Failed to read source PLY: %v", err)
 	}
 
-	target, err := ply.Read(targetFile, false)
+	target, err := ply.Read(targetFile, readOptions)
 	if err != nil {
 		log.Fatalf("Failed to read target PLY: %v", err)
 	}
@@ -40,11 +44,13 @@ func main() {
 	fmt.Printf("Estimated Rotation Matrix:\n%+v\n", result.FinalTransform.Elements())
 	fmt.Printf("Estimated Translation: X=%.3f, Y=%.4f, Z=%.4f\n", translation.X, translation.Y, translation.Z)
 	fmt.Printf("Estimated Yaw: %.3f\n", yaw)
+	fmt.Printf("Fitness: %.3f, Inlier RMSE: %.4f\n", result.Fitness, result.InlierRMSE)
+	fmt.Printf("Iterations: %d (%v)\n", result.Iterations, result.ConvergenceReason)
 	fmt.Printf("Time Elapsed: %v\n", result.ElapsedTime)
 	fmt.Printf("Num Target Points: %d\n", result.NumTargetPoints)
 	fmt.Printf("Num Source Points: %d\n", result.NumSourcePoints)
 
-	if err := ply.Write("transformed_source.ply", result.TransformedPoints); err != nil {
+	if err := ply.Write("transformed_source.ply", result.TransformedPoints, ply.DefaultWriteOptions); err != nil {
 		log.Fatalf("Failed to write transformed points: %v", err)
 	}
 }
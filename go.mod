@@ -5,6 +5,7 @@ go 1.23.2
 toolchain go1.23.8
 
 require (
+	github.com/klauspost/compress v1.18.0
 	github.com/pkg/errors v0.9.1
 	github.com/team-rocos/go-common v1.18.7
 	gonum.org/v1/gonum v0.16.0
@@ -0,0 +1,134 @@
+package icp
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// TestCloud registers clouds with each algorithm, which must give the result of registering the same points
+// held as Points3D.
+func TestCloud(t *testing.T) {
+	source, target := partialOverlap(rigidTransform(0.05, 0.01, -0.01, [3]float64{0.3, -0.2, 0.05}))
+
+	algorithms := []struct {
+		name          string
+		register      func(source, target *point.Points3D, params *Params) (*Result, error)
+		registerCloud func(source, target *point.Cloud, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPoint, PointToPointCloud},
+		{"PointToPlane", PointToPlane, PointToPlaneCloud},
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			params := *DefaultParams
+			params.MaxCorrespondenceDistance = 0.3
+
+			targetPoints := target.Copy()
+			result, err := algorithm.register(source.Copy(), targetPoints, &params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			targetCloud := point.FromPoints(target)
+			resultCloud, err := algorithm.registerCloud(point.FromPoints(source), targetCloud, &params)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := resultCloud.FinalTransform.Elements(), result.FinalTransform.Elements(); got != want {
+				t.Errorf("got transform %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(resultCloud.Evaluation, result.Evaluation) {
+				t.Errorf("got evaluation %+v, want %+v", resultCloud.Evaluation, result.Evaluation)
+			}
+			if resultCloud.NumSourcePoints != result.NumSourcePoints || resultCloud.NumTargetPoints != result.NumTargetPoints {
+				t.Errorf("got %d source and %d target points, want %d and %d", resultCloud.NumSourcePoints, resultCloud.NumTargetPoints, result.NumSourcePoints, result.NumTargetPoints)
+			}
+			if resultCloud.TransformedPoints != nil {
+				t.Error("got transformed points, want the transformed cloud only")
+			}
+			if got := resultCloud.TransformedCloud.Points(); !reflect.DeepEqual(got, result.TransformedPoints) {
+				t.Error("got a transformed cloud differing from the transformed points")
+			}
+
+			// Normals estimated for the target are stored in it alike.
+			if got := targetCloud.Points(); !reflect.DeepEqual(got, targetPoints) {
+				t.Error("got target normals differing from those stored in the points")
+			}
+		})
+	}
+}
+
+// TestCloud32 registers float32 clouds of a georeferenced scene, whose coordinates float32 rounds to a few
+// centimetres. Registration reads them as float64 around a local origin, so it must find the transform that
+// registering the same rounded points held in float64 finds.
+func TestCloud32(t *testing.T) {
+	motion := rigidTransform(0.1, 0.01, -0.02, [3]float64{0.3, -0.2, 0.05})
+	offset := rigidTransform(0, 0, 0, [3]float64{2e5, 1.5e5, 120})
+
+	points := scene()
+	target := point.FromPoints32(moved(*moved(points, transform.Matrix4Identity()), offset))
+	source := point.FromPoints32(moved(*moved(points, inverse(motion)), offset))
+
+	algorithms := []struct {
+		name       string
+		register   func(source, target *point.Cloud, params *Params) (*Result, error)
+		register32 func(source, target *point.Cloud32, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPointCloud, PointToPointCloud32},
+		{"PointToPlane", PointToPlaneCloud, PointToPlaneCloud32},
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			target32 := target.Copy()
+
+			result32, err := algorithm.register32(source.Copy(), target32, DefaultParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			target64 := target.Cloud()
+			result, err := algorithm.register(source.Cloud(), target64, DefaultParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result32.ConvergenceReason != Converged {
+				t.Errorf("got %v, want converged", result32.ConvergenceReason)
+			}
+			// Transforms are compared in the frame of the scene, where the translation does not grow with the
+			// distance to the georeferenced origin.
+			local := inverse(offset).Dot(result32.FinalTransform).Dot(offset)
+			checkTransform(t, local, inverse(offset).Dot(result.FinalTransform).Dot(offset), 1e-5, 1e-6)
+			checkTransform(t, local, motion, 0.002, 0.0005)
+
+			if algorithm.name != "PointToPlane" {
+				return
+			}
+
+			// The normals estimated for the target are stored in it, rounded from those of the float64 registration.
+			if target32.Normals == nil {
+				t.Fatal("got no normals in the target")
+			}
+			var estimated int
+			for i := 0; i < target32.Len(); i++ {
+				nx, ny, nz := target32.Normal(i)
+				wx, wy, wz := target64.Normal(i)
+				if math.Abs(nx-wx)+math.Abs(ny-wy)+math.Abs(nz-wz) > 1e-6 {
+					t.Fatalf("target point %d has normal (%v, %v, %v), want (%v, %v, %v)", i, nx, ny, nz, wx, wy, wz)
+				}
+				if nx != 0 || ny != 0 || nz != 0 {
+					estimated++
+				}
+			}
+			if estimated == 0 {
+				t.Error("got no estimated normals in the target")
+			}
+		})
+	}
+}
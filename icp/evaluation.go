@@ -0,0 +1,171 @@
+package icp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// ConvergenceReason reports why registration stopped iterating.
+type ConvergenceReason int
+
+const (
+	// The last update moved the source less than Params.Tolerance.
+	Converged ConvergenceReason = iota
+
+	// Params.MaxIterations were run without converging.
+	MaxIterationsReached
+
+	// The correspondences did not constrain every degree of freedom, e.g. when every source point matches a
+	// single plane, so the transform could not be solved for. The transform found before is returned.
+	Degenerate
+
+	// Every correspondence was lost, or the transform stopped being finite. The transform is not reliable.
+	Diverged
+)
+
+func (c ConvergenceReason) String() string {
+	switch c {
+	case Converged:
+		return "converged"
+	case MaxIterationsReached:
+		return "max iterations"
+	case Degenerate:
+		return "degenerate"
+	case Diverged:
+		return "diverged"
+	default:
+		return fmt.Sprintf("ConvergenceReason(%d)", int(c))
+	}
+}
+
+// Evaluation measures how well the transformed source aligns with the target. Statistics are taken over the
+// inliers: the source points whose closest target point lies within the correspondence distance used last.
+type Evaluation struct {
+	// Fraction of the downsampled source points that are inliers.
+	Fitness float64 `json:"fitness"`
+
+	// Root mean square distance between inliers and their closest target points.
+	InlierRMSE float64 `json:"inlierRMSE"`
+
+	// Mean and median absolute residual of the inliers: the distance to the closest target point for
+	// point-to-point registration, and to its tangent plane for point-to-plane registration.
+	MeanResidual   float64 `json:"meanResidual"`
+	MedianResidual float64 `json:"medianResidual"`
+
+	// Number of transform updates computed.
+	Iterations int `json:"iterations"`
+
+	ConvergenceReason ConvergenceReason `json:"convergenceReason"`
+
+	// Inlier correspondences once the source is transformed.
+	Correspondences []Correspondence `json:"correspondences,omitempty"`
+}
+
+// Correspondence pairs a source point with its closest target point.
+type Correspondence struct {
+	Source   int     `json:"source"` // Index in Result.TransformedPoints or Result.TransformedCloud.
+	Target   int     `json:"target"` // Index in the target cloud.
+	Distance float64 `json:"distance"`
+}
+
+var (
+	errNoCorrespondences = errors.New("no valid correspondences found")
+	errDegenerate        = errors.New("degenerate correspondences")
+)
+
+// stop records why registration ended before converging at the given iteration, returning an error if it
+// cannot produce a result at all.
+func (r *registration) stop(iteration int, err error) error {
+	switch {
+	case errors.Is(err, errNoCorrespondences) && iteration > 0:
+		r.evaluation.ConvergenceReason = Diverged
+	case errors.Is(err, errDegenerate):
+		r.evaluation.ConvergenceReason = Degenerate
+	default:
+		return err
+	}
+	return nil
+}
+
+// update applies tform, computed at the given iteration, to the source and composes it with final. It
+// returns the composed transform and whether registration is complete. A transform that is not finite is
+// not applied, so the source and the transform returned are those of the last finite update.
+func (r *registration) update(iteration int, tform, final *transform.Matrix4) (*transform.Matrix4, bool) {
+	r.evaluation.Iterations = iteration + 1
+
+	for _, row := range tform.Elements() {
+		for _, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				r.evaluation.ConvergenceReason = Diverged
+				return final, true
+			}
+		}
+	}
+
+	TransformCloud(r.source, tform)
+
+	// The update applies after the transforms found so far.
+	final = tform.Dot(final)
+
+	if r.converged(tform) {
+		r.evaluation.ConvergenceReason = Converged
+		return final, true
+	}
+	return final, false
+}
+
+// evaluate matches the transformed source against the target and fills in the statistics of the evaluation.
+// Residuals are distances between points, or given by residual if set.
+func (r *registration) evaluate(residual func(i, j int) (float64, error)) error {
+	closest, distances := r.closestPoints()
+
+	var sumSquared float64
+	var residuals []float64
+
+	for i, j := range closest {
+		if j < 0 || !r.accepts(distances[i]) {
+			continue
+		}
+
+		distance := math.Sqrt(distances[i])
+		r.evaluation.Correspondences = append(r.evaluation.Correspondences, Correspondence{Source: i, Target: j, Distance: distance})
+		sumSquared += distances[i]
+
+		if residual == nil {
+			residuals = append(residuals, distance)
+			continue
+		}
+		res, err := residual(i, j)
+		if err != nil {
+			return err
+		}
+		residuals = append(residuals, math.Abs(res))
+	}
+
+	n := len(residuals)
+	if n == 0 {
+		return nil
+	}
+
+	r.evaluation.Fitness = float64(n) / float64(r.source.Len())
+	r.evaluation.InlierRMSE = math.Sqrt(sumSquared / float64(n))
+
+	var sum float64
+	for _, res := range residuals {
+		sum += res
+	}
+	r.evaluation.MeanResidual = sum / float64(n)
+
+	sort.Float64s(residuals)
+	if n%2 == 1 {
+		r.evaluation.MedianResidual = residuals[n/2]
+	} else {
+		r.evaluation.MedianResidual = (residuals[n/2-1] + residuals[n/2]) / 2
+	}
+
+	return nil
+}
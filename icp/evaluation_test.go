@@ -0,0 +1,199 @@
+package icp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+	"github.com/pkg/errors"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// lattice returns the points of a cube of side 1 m sampled every 10 cm, so that no two points share a voxel
+// when downsampling.
+func lattice() *point.Points3D {
+	var points point.Points3D
+	for i := 0; i <= 10; i++ {
+		for j := 0; j <= 10; j++ {
+			for k := 0; k <= 10; k++ {
+				points = append(points, &point.Point3D{X: float64(i) / 10, Y: float64(j) / 10, Z: float64(k) / 10})
+			}
+		}
+	}
+	return &points
+}
+
+// blindSearcher stops finding neighbors once it has answered a number of nearest neighbor queries.
+type blindSearcher struct {
+	point.NeighborSearcher
+	queries int
+}
+
+func (s *blindSearcher) Nearest(x, y, z float64) (int, float64) {
+	if s.queries == 0 {
+		return -1, math.Inf(1)
+	}
+	s.queries--
+	return s.NeighborSearcher.Nearest(x, y, z)
+}
+
+// TestFitnessAndInlierRMSE evaluates a source lying 1 cm above the target with outliers beyond the
+// correspondence distance. No iterations are run, so the initial alignment is evaluated.
+func TestFitnessAndInlierRMSE(t *testing.T) {
+	target := lattice()
+	source := moved(*target, rigidTransform(0, 0, 0, [3]float64{0, 0, 0.01}))
+
+	const outliers = 100
+	for i := 0; i < outliers; i++ {
+		source.Merge(&point.Points3D{{X: float64(i), Y: 0, Z: 50}})
+	}
+
+	params := *DefaultParams
+	params.MaxIterations = 0
+
+	result, err := PointToPoint(source, target, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inliers := target.Len()
+	if want := float64(inliers) / float64(inliers+outliers); math.Abs(result.Fitness-want) > 1e-12 {
+		t.Errorf("got fitness %v, want %v", result.Fitness, want)
+	}
+	if math.Abs(result.InlierRMSE-0.01) > 1e-9 || math.Abs(result.MeanResidual-0.01) > 1e-9 || math.Abs(result.MedianResidual-0.01) > 1e-9 {
+		t.Errorf("got inlier RMSE %v and mean and median residuals %v and %v, want 0.01", result.InlierRMSE, result.MeanResidual, result.MedianResidual)
+	}
+	if len(result.Correspondences) != inliers {
+		t.Fatalf("got %d correspondences, want %d", len(result.Correspondences), inliers)
+	}
+	for _, c := range result.Correspondences {
+		if c.Source != c.Target || math.Abs(c.Distance-0.01) > 1e-9 {
+			t.Fatalf("got correspondence %+v, want each source point matched to the target point below it", c)
+		}
+	}
+	if result.ConvergenceReason != MaxIterationsReached || result.Iterations != 0 || !isIdentity(result.FinalTransform) {
+		t.Errorf("got %v after %d iterations, want max iterations after 0 and the identity", result.ConvergenceReason, result.Iterations)
+	}
+}
+
+func TestConvergenceReasons(t *testing.T) {
+	motion := rigidTransform(0.05, 0, 0, [3]float64{0.1, -0.05, 0.02})
+
+	tests := []struct {
+		name     string
+		register func() (*Result, error)
+		want     ConvergenceReason
+
+		// Number of transform updates computed, or -1 if it is not checked.
+		iterations int
+	}{
+		{
+			name: "converged",
+			register: func() (*Result, error) {
+				return PointToPoint(moved(scene(), inverse(motion)), moved(scene(), transform.Matrix4Identity()), DefaultParams)
+			},
+			want:       Converged,
+			iterations: -1,
+		},
+		{
+			name: "max iterations",
+			register: func() (*Result, error) {
+				params := *DefaultParams
+				params.MaxIterations = 2
+				return PointToPlane(moved(scene(), inverse(motion)), moved(scene(), transform.Matrix4Identity()), &params)
+			},
+			want:       MaxIterationsReached,
+			iterations: 2,
+		},
+		{
+			// A single plane leaves the motion within it unconstrained.
+			name: "degenerate",
+			register: func() (*Result, error) {
+				floor := lattice().Crop(&point.CropOptions{Keep: func(p *point.Point3D) bool { return p.Z == 0 }})
+				return PointToPlane(moved(*floor, inverse(motion)), floor, DefaultParams)
+			},
+			want:       Degenerate,
+			iterations: 0,
+		},
+		{
+			// Correspondences are lost after the first iteration.
+			name: "diverged",
+			register: func() (*Result, error) {
+				target := lattice()
+				searcher := &blindSearcher{NeighborSearcher: point.NewKDTree(target, nil), queries: target.Len()}
+				return PointToPointSearcher(moved(*lattice(), inverse(motion)), searcher, DefaultParams)
+			},
+			want:       Diverged,
+			iterations: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.register()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.ConvergenceReason != tt.want {
+				t.Errorf("got %v after %d iterations, want %v", result.ConvergenceReason, result.Iterations, tt.want)
+			}
+			if tt.iterations >= 0 && result.Iterations != tt.iterations {
+				t.Errorf("got %d iterations, want %d", result.Iterations, tt.iterations)
+			}
+			for _, row := range result.FinalTransform.Elements() {
+				for _, v := range row {
+					if math.IsNaN(v) || math.IsInf(v, 0) {
+						t.Fatalf("got a transform that is not finite: %v", result.FinalTransform.Elements())
+					}
+				}
+			}
+			if tt.want == Converged && (result.Fitness != 1 || result.InlierRMSE > 1e-6) {
+				t.Errorf("got fitness %v and inlier RMSE %v once converged, want 1 and 0", result.Fitness, result.InlierRMSE)
+			}
+		})
+	}
+}
+
+// TestNoCorrespondences fails registration that finds no correspondence at all, rather than reporting it as
+// diverged as when correspondences are lost after the first iteration.
+func TestNoCorrespondences(t *testing.T) {
+	target := lattice()
+	searcher := &blindSearcher{NeighborSearcher: point.NewKDTree(target, nil)}
+
+	if _, err := PointToPointSearcher(lattice(), searcher, DefaultParams); !errors.Is(err, errNoCorrespondences) {
+		t.Errorf("PointToPointSearcher returned error %v, want %v", err, errNoCorrespondences)
+	}
+	if _, err := PointToPlaneSearcher(lattice(), searcher, DefaultParams); !errors.Is(err, errNoCorrespondences) {
+		t.Errorf("PointToPlaneSearcher returned error %v, want %v", err, errNoCorrespondences)
+	}
+
+	// Every source point lies beyond the correspondence distance.
+	far := moved(*lattice(), rigidTransform(0, 0, 0, [3]float64{0, 0, 10}))
+	if _, err := PointToPlane(far, target, DefaultParams); !errors.Is(err, errNoCorrespondences) {
+		t.Errorf("PointToPlane returned error %v, want %v", err, errNoCorrespondences)
+	}
+}
+
+// TestUpdateNotFinite checks that a transform that is not finite is reported as diverged without being applied.
+func TestUpdateNotFinite(t *testing.T) {
+	r := newRegistration(lattice(), lattice(), DefaultParams)
+	before := r.source.Copy()
+
+	final := rigidTransform(0.1, 0, 0, [3]float64{1, 2, 3})
+	tform := rigidTransform(0, 0, 0, [3]float64{math.NaN(), 0, 0})
+
+	got, done := r.update(4, tform, final)
+	if !done || r.evaluation.ConvergenceReason != Diverged || r.evaluation.Iterations != 5 {
+		t.Errorf("got done %v, %v after %d iterations, want done and diverged after 5", done, r.evaluation.ConvergenceReason, r.evaluation.Iterations)
+	}
+	if got != final {
+		t.Errorf("got transform %v, want the last finite one", got.Elements())
+	}
+	for i := 0; i < r.source.Len(); i++ {
+		x, y, z := r.source.At(i)
+		bx, by, bz := before.At(i)
+		if x != bx || y != by || z != bz {
+			t.Fatalf("source point %d moved from %v, %v, %v to %v, %v, %v", i, bx, by, bz, x, y, z)
+		}
+	}
+}
@@ -8,7 +8,6 @@ import (
 
 	"github.com/flynnletford/icp-go/point"
 	"gonum.org/v1/gonum/mat"
-	"gonum.org/v1/gonum/spatial/kdtree"
 )
 
 type Result struct {
@@ -17,18 +16,39 @@ type Result struct {
 	ElapsedTime       time.Duration      `json:"elapsedTime"`
 	NumTargetPoints   int                `json:"numTargetPoints"`
 	NumSourcePoints   int                `json:"numSourcePoints"`
+
+	// Transformed source of the Cloud and Cloud32 variants, held in float64. They leave TransformedPoints nil.
+	TransformedCloud *point.Cloud `json:"transformedCloud,omitempty"`
+
+	// Local origin both clouds were shifted to while solving, or nil if they were used as given. See
+	// Params.OriginShiftThreshold.
+	LocalOrigin *point.Point3D `json:"localOrigin,omitempty"`
+
+	// Quality of the alignment and how registration ended.
+	Evaluation
 }
 
+// ICPRefine aligns the source coarsely with point-to-plane ICP, then refines the result with point-to-point ICP.
+// Both stages use params, or DefaultParams if it is nil, so they gate correspondences alike. The initial
+// transform is only applied by the first stage, whose result the second starts from.
 func ICPRefine(source *point.Points3D, target *point.Points3D, params *Params) (*Result, error) {
 
 	startTime := time.Now()
 
-	planeResult, err := PointToPlane(source.Copy(), target.Copy(), DefaultParams)
+	planeParams := *DefaultParams
+	if params != nil {
+		planeParams = *params
+	}
+
+	pointParams := planeParams
+	pointParams.InitialTransform = nil
+
+	planeResult, err := PointToPlane(source.Copy(), target.Copy(), &planeParams)
 	if err != nil {
 		return nil, err
 	}
 
-	pointResult, err := PointToPoint(planeResult.TransformedPoints.Copy(), target.Copy(), DefaultParams)
+	pointResult, err := PointToPoint(planeResult.TransformedPoints.Copy(), target.Copy(), &pointParams)
 	if err != nil {
 		return nil, err
 	}
@@ -36,117 +56,198 @@ func ICPRefine(source *point.Points3D, target *point.Points3D, params *Params) (
 	planeTform := planeResult.FinalTransform
 	pointTform := pointResult.FinalTransform
 
-	totalTransform := planeTform.Dot(pointTform)
+	// The point-to-point transform applies after the point-to-plane one.
+	totalTransform := pointTform.Dot(planeTform)
+
+	// Report the evaluation and local origin of the final stage, counting the iterations of both.
+	evaluation := pointResult.Evaluation
+	evaluation.Iterations += planeResult.Iterations
 
 	result := &Result{
 		FinalTransform:    totalTransform,
 		TransformedPoints: pointResult.TransformedPoints,
 		ElapsedTime:       time.Since(startTime),
 		NumTargetPoints:   target.Len(),
-		NumSourcePoints:   target.Len(),
+		NumSourcePoints:   source.Len(),
+		LocalOrigin:       pointResult.LocalOrigin,
+		Evaluation:        evaluation,
 	}
 
 	return result, nil
 }
 
+// PointToPoint performs ICP using point-to-point error minimization.
 func PointToPoint(source *point.Points3D, target *point.Points3D, params *Params) (*Result, error) {
 
 	startTime := time.Now()
 
-	_, transformed := Filter(source, params)
-	targetTree, _ := Filter(target, params)
+	r := newRegistration(source, target, params)
 
-	// Initialise our final transform calculated.
-	finalTransform := transform.Matrix4Identity()
+	finalTransform, err := r.pointToPoint()
+	if err != nil {
+		return nil, err
+	}
 
-	for i := 0; i < params.MaxIterations; i++ {
+	result := &Result{
+		FinalTransform:    finalTransform,
+		TransformedPoints: r.source.Points(),
+		ElapsedTime:       time.Since(startTime),
+		NumTargetPoints:   target.Len(),
+		NumSourcePoints:   source.Len(),
+		LocalOrigin:       r.origin,
+		Evaluation:        r.evaluation,
+	}
 
-		// TODO: only transform points inside closest points as required.
-		closest, _ := closestPoints(transformed, targetTree)
+	return result, nil
+}
 
-		tform, err := computeOptimalTransform(transformed, closest, params.MaxCorrespondenceDistance)
-		if err != nil {
-			return nil, err
-		}
+// PointToPointCloud performs point-to-point ICP on clouds stored as contiguous arrays. The transformed
+// source is returned in Result.TransformedCloud.
+func PointToPointCloud(source *point.Cloud, target *point.Cloud, params *Params) (*Result, error) {
 
-		TransformPoints(transformed, tform)
+	startTime := time.Now()
 
-		// Update our transform.
-		finalTransform = finalTransform.Dot(tform)
+	r := newRegistration(source, target, params)
 
-		// Check if our current transform is within the tolerance.
-		if isWithinThreshold(tform, params.Tolerance) {
-			break
-		}
+	finalTransform, err := r.pointToPoint()
+	if err != nil {
+		return nil, err
 	}
 
 	result := &Result{
-		FinalTransform:    finalTransform,
-		TransformedPoints: transformed,
-		ElapsedTime:       time.Since(startTime),
-		NumTargetPoints:   target.Len(),
-		NumSourcePoints:   source.Len(),
+		FinalTransform:   finalTransform,
+		TransformedCloud: r.source,
+		ElapsedTime:      time.Since(startTime),
+		NumTargetPoints:  target.Len(),
+		NumSourcePoints:  source.Len(),
+		LocalOrigin:      r.origin,
+		Evaluation:       r.evaluation,
 	}
 
 	return result, nil
 }
 
-func computeCentroid(points *point.Points3D) *point.Point3D {
-	var sumX, sumY, sumZ float64
-	n := float64(len(points.Raw()))
+// PointToPointCloud32 performs point-to-point ICP on float32 clouds, accumulating in float64. The transformed
+// source is returned in Result.TransformedCloud.
+func PointToPointCloud32(source *point.Cloud32, target *point.Cloud32, params *Params) (*Result, error) {
 
-	for _, p := range points.Raw() {
-		sumX += p.X
-		sumY += p.Y
-		sumZ += p.Z
+	startTime := time.Now()
+
+	r := newRegistration(source, target, params)
+
+	finalTransform, err := r.pointToPoint()
+	if err != nil {
+		return nil, err
 	}
 
-	return &point.Point3D{X: sumX / n, Y: sumY / n, Z: sumZ / n}
+	result := &Result{
+		FinalTransform:   finalTransform,
+		TransformedCloud: r.source,
+		ElapsedTime:      time.Since(startTime),
+		NumTargetPoints:  target.Len(),
+		NumSourcePoints:  source.Len(),
+		LocalOrigin:      r.origin,
+		Evaluation:       r.evaluation,
+	}
+
+	return result, nil
 }
 
-func closestPoints(source *point.Points3D, target *kdtree.Tree) (*point.Points3D, float64) {
-	closest := make([]*point.Point3D, source.Len())
+// PointToPointSearcher performs point-to-point ICP against every point held by a searcher over the target, such as a
+// map maintained incrementally with a point.IKDTree, VoxelMap or Octree. The target is not downsampled,
+// and its coordinates must implement point.Surface.
+func PointToPointSearcher(source *point.Points3D, target point.NeighborSearcher, params *Params) (*Result, error) {
+
+	startTime := time.Now()
+
+	r, err := newSearcherRegistration(source, target, params)
+	if err != nil {
+		return nil, err
+	}
 
-	sum := 0.0
-	for i, sourcePoint := range source.Raw() {
-		closestPoint, dist := closestPoint(sourcePoint, target)
-		closest[i] = closestPoint
-		sum += dist
+	finalTransform, err := r.pointToPoint()
+	if err != nil {
+		return nil, err
 	}
 
-	sum /= float64(source.Len())
+	result := &Result{
+		FinalTransform:    finalTransform,
+		TransformedPoints: r.source.Points(),
+		ElapsedTime:       time.Since(startTime),
+		NumTargetPoints:   target.Len(),
+		NumSourcePoints:   source.Len(),
+		LocalOrigin:       r.origin,
+		Evaluation:        r.evaluation,
+	}
 
-	closestPoints := point.Points3D(closest)
-	return &closestPoints, sum
+	return result, nil
 }
 
-func closestPoint(p *point.Point3D, points *kdtree.Tree) (*point.Point3D, float64) {
-	val, dist := points.Nearest(p)
-	return val.(*point.Point3D), dist
-}
+func (r *registration) pointToPoint() (*transform.Matrix4, error) {
 
-func computeOptimalTransform(source *point.Points3D, closestTargetPoints *point.Points3D, maxCorrespondenceDistance float64) (*transform.Matrix4, error) {
-	centroidSource := computeCentroid(source)
-	centroidTarget := computeCentroid(closestTargetPoints)
+	// Initialise our final transform calculated.
+	finalTransform := transform.Matrix4Identity()
 
-	H := mat.NewDense(3, 3, nil)
+	r.evaluation.ConvergenceReason = MaxIterationsReached
+
+	for i := 0; i < r.params.MaxIterations; i++ {
+
+		// TODO: only transform points inside closest points as required.
+		closest, distances := r.closestPoints()
+
+		tform, err := r.computeOptimalTransform(closest, distances)
+		if err != nil {
+			if err := r.stop(i, err); err != nil {
+				return nil, err
+			}
+			break
+		}
 
-	numValid := 0
+		// Apply the transform and check if it is within the tolerance.
+		var done bool
+		if finalTransform, done = r.update(i, tform, finalTransform); done {
+			break
+		}
+	}
 
-	for i := range source.Raw() {
+	if err := r.evaluate(nil); err != nil {
+		return nil, err
+	}
 
-		src := source.Raw()[i]
-		tgt := closestTargetPoints.Raw()[i]
+	return r.finish(finalTransform), nil
+}
 
-		// Skip if the distance between the source and target points is too large.
-		if src.Distance(tgt) > maxCorrespondenceDistance {
-			continue
+// computeOptimalTransform finds the rigid transform best aligning each source point with the target point at
+// the same position in closest, skipping pairs further apart than the correspondence distance.
+func (r *registration) computeOptimalTransform(closest []int, distances []float64) (*transform.Matrix4, error) {
+	sourceIndices := make([]int, 0, len(closest))
+	targetIndices := make([]int, 0, len(closest))
+
+	for i, j := range closest {
+		if j >= 0 && r.accepts(distances[i]) {
+			sourceIndices = append(sourceIndices, i)
+			targetIndices = append(targetIndices, j)
 		}
+	}
 
-		numValid++
+	if len(sourceIndices) == 0 {
+		return nil, errNoCorrespondences
+	}
+	if len(sourceIndices) < 3 {
+		return nil, errDegenerate
+	}
+
+	centroidSource := point.Centroid(r.source, sourceIndices)
+	centroidTarget := point.Centroid(r.target, targetIndices)
+
+	H := mat.NewDense(3, 3, nil)
+
+	for k, i := range sourceIndices {
+		sx, sy, sz := r.source.At(i)
+		tx, ty, tz := r.target.At(targetIndices[k])
 
-		s := src.Subtract(centroidSource).ToArray()
-		t := tgt.Subtract(centroidTarget).ToArray()
+		s := []float64{sx - centroidSource.X, sy - centroidSource.Y, sz - centroidSource.Z}
+		t := []float64{tx - centroidTarget.X, ty - centroidTarget.Y, tz - centroidTarget.Z}
 
 		for j := 0; j < 3; j++ {
 			for k := 0; k < 3; k++ {
@@ -155,13 +256,9 @@ func computeOptimalTransform(source *point.Points3D, closestTargetPoints *point.
 		}
 	}
 
-	if numValid == 0 {
-		return nil, errors.New("no valid correspondences found")
-	}
-
 	var svd mat.SVD
 	if ok := svd.Factorize(H, mat.SVDThin); !ok {
-		return nil, errors.New("failed to factorize matrix")
+		return nil, errors.Wrap(errDegenerate, "failed to factorize matrix")
 	}
 
 	U := mat.NewDense(3, 3, nil)
@@ -206,15 +303,23 @@ func TransformPoints(points *point.Points3D, tform *transform.Matrix4) {
 		return
 	}
 
+	// Normals are only rotated, so remove the translation picked up when transforming them as positions.
+	origin := tform.MulVec3(&transform.Vector3{})
+
 	for i, p := range points.Raw() {
 
 		newVec3 := tform.MulVec3(&transform.Vector3{X: p.X, Y: p.Y, Z: p.Z})
 
-		points.Raw()[i] = &point.Point3D{
-			X: newVec3.X,
-			Y: newVec3.Y,
-			Z: newVec3.Z,
+		// Copy the point so that its attributes carry over.
+		transformed := *p
+		transformed.X, transformed.Y, transformed.Z = newVec3.X, newVec3.Y, newVec3.Z
+
+		if p.Nx != 0 || p.Ny != 0 || p.Nz != 0 {
+			normal := tform.MulVec3(&transform.Vector3{X: p.Nx, Y: p.Ny, Z: p.Nz})
+			transformed.Nx, transformed.Ny, transformed.Nz = normal.X-origin.X, normal.Y-origin.Y, normal.Z-origin.Z
 		}
+
+		points.Raw()[i] = &transformed
 	}
 }
 
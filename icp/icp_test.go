@@ -0,0 +1,424 @@
+package icp
+
+import (
+	"math"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// scene returns points sampled every 10 cm on a floor, three walls and four boxes, so that surfaces face every
+// axis and each degree of freedom is constrained.
+func scene() point.Points3D {
+	var points point.Points3D
+
+	// plane samples the rectangle spanned by u and v from origin.
+	plane := func(origin, u, v [3]float64) {
+		nu := int(math.Round(math.Sqrt(u[0]*u[0]+u[1]*u[1]+u[2]*u[2]) / 0.1))
+		nv := int(math.Round(math.Sqrt(v[0]*v[0]+v[1]*v[1]+v[2]*v[2]) / 0.1))
+		for i := 0; i <= nu; i++ {
+			for j := 0; j <= nv; j++ {
+				a, b := float64(i)/float64(nu), float64(j)/float64(nv)
+				points = append(points, &point.Point3D{
+					X: origin[0] + a*u[0] + b*v[0],
+					Y: origin[1] + a*u[1] + b*v[1],
+					Z: origin[2] + a*u[2] + b*v[2],
+				})
+			}
+		}
+	}
+
+	// box samples the sides and top of a box standing on the floor.
+	box := func(x, y, w, d, h float64) {
+		plane([3]float64{x, y, 0}, [3]float64{w, 0, 0}, [3]float64{0, 0, h})
+		plane([3]float64{x, y + d, 0}, [3]float64{w, 0, 0}, [3]float64{0, 0, h})
+		plane([3]float64{x, y, 0}, [3]float64{0, d, 0}, [3]float64{0, 0, h})
+		plane([3]float64{x + w, y, 0}, [3]float64{0, d, 0}, [3]float64{0, 0, h})
+		plane([3]float64{x, y, h}, [3]float64{w, 0, 0}, [3]float64{0, d, 0})
+	}
+
+	plane([3]float64{0, 0, 0}, [3]float64{10, 0, 0}, [3]float64{0, 6, 0})
+	plane([3]float64{0, 0, 0}, [3]float64{10, 0, 0}, [3]float64{0, 0, 2})
+	plane([3]float64{0, 6, 0}, [3]float64{10, 0, 0}, [3]float64{0, 0, 2})
+	plane([3]float64{5, 0, 0}, [3]float64{0, 2.5, 0}, [3]float64{0, 0, 2})
+	box(3.5, 3, 1, 1, 1)
+	box(4, 1, 0.4, 0.8, 1.2)
+	box(5.5, 3.5, 0.6, 1.2, 1.4)
+	box(6, 1, 0.5, 0.5, 0.6)
+
+	return points
+}
+
+// rigidTransform returns the transform rotating by yaw, pitch and roll in radians, then translating by t.
+func rigidTransform(yaw, pitch, roll float64, t [3]float64) *transform.Matrix4 {
+	sy, cy := math.Sincos(yaw)
+	sp, cp := math.Sincos(pitch)
+	sr, cr := math.Sincos(roll)
+
+	return transform.NewMatrix4FromElements([4][4]float64{
+		{cy * cp, cy*sp*sr - sy*cr, cy*sp*cr + sy*sr, t[0]},
+		{sy * cp, sy*sp*sr + cy*cr, sy*sp*cr - cy*sr, t[1]},
+		{-sp, cp * sr, cp * cr, t[2]},
+		{0, 0, 0, 1},
+	})
+}
+
+// inverse returns the inverse of a rigid transform.
+func inverse(tform *transform.Matrix4) *transform.Matrix4 {
+	m := tform.Elements()
+
+	var inv [4][4]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			inv[i][j] = m[j][i]
+		}
+		for j := 0; j < 3; j++ {
+			inv[i][3] -= m[j][i] * m[j][3]
+		}
+	}
+	inv[3][3] = 1
+
+	return transform.NewMatrix4FromElements(inv)
+}
+
+// moved returns copies of the points moved by tform.
+func moved(points point.Points3D, tform *transform.Matrix4) *point.Points3D {
+	copies := make(point.Points3D, len(points))
+	for i, p := range points {
+		m := tform.MulVec3(&transform.Vector3{X: p.X, Y: p.Y, Z: p.Z})
+		copies[i] = &point.Point3D{X: m.X, Y: m.Y, Z: m.Z}
+	}
+	return &copies
+}
+
+// crop returns copies of the points for which keep is true, moved by tform.
+func crop(points point.Points3D, tform *transform.Matrix4, keep func(p *point.Point3D) bool) *point.Points3D {
+	cropped := make(point.Points3D, 0, len(points))
+	for _, p := range points {
+		if !keep(p) {
+			continue
+		}
+		m := tform.MulVec3(&transform.Vector3{X: p.X, Y: p.Y, Z: p.Z})
+		cropped = append(cropped, &point.Point3D{X: m.X, Y: m.Y, Z: m.Z})
+	}
+	return &cropped
+}
+
+// partialOverlap returns a target holding the part of the scene with x below 7 m, and a source holding the
+// part with x above 3 m moved by the inverse of want, so that the scans only overlap between 3 and 7 m.
+func partialOverlap(want *transform.Matrix4) (source, target *point.Points3D) {
+	points := scene()
+
+	target = crop(points, transform.Matrix4Identity(), func(p *point.Point3D) bool { return p.X < 7 })
+	source = crop(points, inverse(want), func(p *point.Point3D) bool { return p.X > 3 })
+
+	return source, target
+}
+
+// checkTransform fails the test unless got is within translation metres and rotation radians of want.
+func checkTransform(t *testing.T, got, want *transform.Matrix4, translation, rotation float64) {
+	t.Helper()
+
+	g, w := got.Elements(), want.Elements()
+
+	var dt float64
+	for i := 0; i < 3; i++ {
+		dt += (g[i][3] - w[i][3]) * (g[i][3] - w[i][3])
+	}
+	if dt = math.Sqrt(dt); dt > translation {
+		t.Errorf("translation is %.4f m from the expected transform, want at most %v", dt, translation)
+	}
+
+	// The angle of the rotation between them follows from the trace of Rgot·Rwantᵀ.
+	var trace float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			trace += g[i][j] * w[i][j]
+		}
+	}
+	if angle := math.Acos(math.Max(-1, math.Min(1, (trace-1)/2))); angle > rotation {
+		t.Errorf("rotation is %.4f rad from the expected transform, want at most %v", angle, rotation)
+	}
+}
+
+// TestNonCommutingMotion recovers a rotation about a point away from the origin, whose rotation and translation
+// do not commute, so the updates of each iteration must be composed in the order they are applied.
+func TestNonCommutingMotion(t *testing.T) {
+	want := rigidTransform(0.15, 0.02, -0.03, [3]float64{0.4, -0.3, 0.1})
+
+	algorithms := []struct {
+		name     string
+		register func(source, target *point.Points3D, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPoint},
+		{"PointToPlane", PointToPlane},
+		{"ICPRefine", ICPRefine},
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			points := scene()
+			target := moved(points, transform.Matrix4Identity())
+			source := moved(points, inverse(want))
+
+			result, err := algorithm.register(source, target, DefaultParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransform(t, result.FinalTransform, want, 0.001, 0.001)
+		})
+	}
+}
+
+// TestPartialOverlap aligns scans sharing less than half their points. Without gating, the parts of the source
+// beyond the target pull it along the walls.
+func TestPartialOverlap(t *testing.T) {
+	want := rigidTransform(0.05, 0.01, -0.01, [3]float64{0.3, -0.2, 0.05})
+
+	algorithms := []struct {
+		name     string
+		register func(source, target *point.Points3D, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPoint},
+		{"PointToPlane", PointToPlane},
+	}
+
+	gating := []struct {
+		name   string
+		params func(p *Params)
+	}{
+		{"MaxCorrespondenceDistance", func(p *Params) { p.MaxCorrespondenceDistance = 0.3 }},
+		{"CorrespondenceSchedule", func(p *Params) { p.CorrespondenceSchedule = []float64{0.3, 0.15, 0.05} }},
+	}
+
+	for _, algorithm := range algorithms {
+		for _, g := range gating {
+			t.Run(algorithm.name+"/"+g.name, func(t *testing.T) {
+				source, target := partialOverlap(want)
+
+				params := *DefaultParams
+				g.params(&params)
+
+				result, err := algorithm.register(source, target, &params)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				checkTransform(t, result.FinalTransform, want, 0.02, 0.005)
+			})
+		}
+	}
+}
+
+// TestPointToPlaneSearcher registers against a map held by each searcher backend.
+func TestPointToPlaneSearcher(t *testing.T) {
+	want := rigidTransform(0.1, 0.01, -0.02, [3]float64{0.3, -0.2, 0.05})
+
+	searchers := []struct {
+		name  string
+		build func(target *point.Points3D) point.NeighborSearcher
+	}{
+		{"KDTree", func(target *point.Points3D) point.NeighborSearcher { return point.NewKDTree(target, nil) }},
+		{"VoxelMap", func(target *point.Points3D) point.NeighborSearcher { return point.NewVoxelMap(target, 0.5, nil) }},
+		{"Octree", func(target *point.Points3D) point.NeighborSearcher { return point.NewOctree(target, nil) }},
+		{"IKDTree", func(target *point.Points3D) point.NeighborSearcher { return point.NewIKDTree(target, 0, nil) }},
+	}
+
+	for _, s := range searchers {
+		t.Run(s.name, func(t *testing.T) {
+			points := scene()
+			target := moved(points, transform.Matrix4Identity())
+			source := moved(points, inverse(want))
+
+			result, err := PointToPlaneSearcher(source, s.build(target), DefaultParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransform(t, result.FinalTransform, want, 0.001, 0.001)
+		})
+	}
+}
+
+// TestInitialTransform recovers a large rotation that registration alone cannot, given a prior close to it.
+// The prior is folded into the result, so FinalTransform maps the original source onto the target.
+func TestInitialTransform(t *testing.T) {
+	want := rigidTransform(1.6, 0.02, -0.01, [3]float64{0.6, -0.4, 0.1})
+	prior := rigidTransform(1.5, 0, 0, [3]float64{0.4, -0.2, 0})
+
+	algorithms := []struct {
+		name     string
+		register func(source, target *point.Points3D, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPoint},
+		{"PointToPlane", PointToPlane},
+		{"ICPRefine", ICPRefine},
+	}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithm.name, func(t *testing.T) {
+			points := scene()
+			target := moved(points, transform.Matrix4Identity())
+			source := moved(points, inverse(want))
+
+			// Without the prior, registration settles far from the motion.
+			result, err := algorithm.register(source.Copy(), target, DefaultParams)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g, w := result.FinalTransform.Translation(), want.Translation(); math.Abs(g.X-w.X)+math.Abs(g.Y-w.Y) < 0.1 {
+				t.Fatalf("registered the motion without a prior, so the test does not exercise it")
+			}
+
+			params := *DefaultParams
+			params.InitialTransform = prior
+
+			result, err = algorithm.register(source.Copy(), target, &params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransform(t, result.FinalTransform, want, 0.001, 0.001)
+
+			// The folded transform takes each source point onto its counterpart in the target.
+			for i, p := range moved(*source, result.FinalTransform).Raw() {
+				if q := target.Raw()[i]; p.Euclidean(q) > 0.002 {
+					t.Fatalf("source point %d maps to %v, want %v", i, *p, *q)
+				}
+			}
+		})
+	}
+}
+
+// TestLocalOrigin registers a scene with georeferenced coordinates, millions of metres from the origin, where
+// the linearization loses its precision unless both clouds are shifted to a local origin.
+func TestLocalOrigin(t *testing.T) {
+	motion := rigidTransform(0.1, 0.01, -0.02, [3]float64{0.3, -0.2, 0.05})
+	offset := rigidTransform(0, 0, 0, [3]float64{5e5, 4e6, 0})
+
+	// The motion expressed in the georeferenced frame.
+	want := offset.Dot(motion).Dot(inverse(offset))
+
+	points := scene()
+	target := moved(*moved(points, transform.Matrix4Identity()), offset)
+	source := moved(*moved(points, inverse(motion)), offset)
+
+	params := *DefaultParams
+	params.OriginShiftThreshold = 0
+
+	result, err := PointToPlane(source.Copy(), target, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ConvergenceReason != Degenerate || result.LocalOrigin != nil {
+		t.Fatalf("got %v with local origin %v without shifting, want degenerate correspondences", result.ConvergenceReason, result.LocalOrigin)
+	}
+
+	params.OriginShiftThreshold = DefaultParams.OriginShiftThreshold
+
+	result, err = PointToPlane(source.Copy(), target, &params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ConvergenceReason != Converged {
+		t.Errorf("got %v, want converged", result.ConvergenceReason)
+	}
+	if o := result.LocalOrigin; o == nil || math.Abs(o.X-5e5) > 10 || math.Abs(o.Y-4e6) > 10 {
+		t.Fatalf("got local origin %v, want one near the scene", o)
+	}
+	// A rotation error of 1e-9 rad moves the translation of a transform about a point 4000 km away by 4 mm.
+	checkTransform(t, result.FinalTransform, want, 0.01, 0.001)
+
+	// The transformed source is returned in the original frame, on top of the target.
+	tree := point.NewKDTree(target, nil)
+	for i, p := range result.TransformedPoints.Raw() {
+		if _, d := tree.Nearest(p.X, p.Y, p.Z); d > 1e-6 {
+			t.Fatalf("transformed source point %d is %.4f m from the target", i, math.Sqrt(d))
+		}
+	}
+}
+
+// TestICPRefine registers part of a georeferenced scene against the whole of it. The transform composed from
+// both stages must match the motion, and the result must describe the source and target it was given.
+func TestICPRefine(t *testing.T) {
+	motion := rigidTransform(0.1, 0.01, -0.02, [3]float64{0.3, -0.2, 0.05})
+	offset := rigidTransform(0, 0, 0, [3]float64{5e5, 4e6, 0})
+	want := offset.Dot(motion).Dot(inverse(offset))
+
+	points := scene()
+	target := moved(*moved(points, transform.Matrix4Identity()), offset)
+	source := moved(*crop(points, inverse(motion), func(p *point.Point3D) bool { return p.X < 7 }), offset)
+
+	result, err := ICPRefine(source, target, DefaultParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkTransform(t, result.FinalTransform, want, 0.01, 0.001)
+
+	if result.NumSourcePoints != source.Len() || result.NumTargetPoints != target.Len() {
+		t.Errorf("got %d source and %d target points, want %d and %d", result.NumSourcePoints, result.NumTargetPoints, source.Len(), target.Len())
+	}
+	if o := result.LocalOrigin; o == nil || math.Abs(o.X-5e5) > 10 || math.Abs(o.Y-4e6) > 10 {
+		t.Errorf("got local origin %v, want one near the scene", o)
+	}
+
+	// The transformed source is the original source moved by the composed transform.
+	tree := point.NewKDTree(moved(*source, result.FinalTransform), nil)
+	for i, p := range result.TransformedPoints.Raw() {
+		if _, d := tree.Nearest(p.X, p.Y, p.Z); d > 1e-6 {
+			t.Fatalf("transformed source point %d is %.4f m from the source moved by the final transform", i, math.Sqrt(d))
+		}
+	}
+}
+
+// TestApproxSearch registers with approximate correspondences, which must still converge to the motion found
+// with exact ones.
+func TestApproxSearch(t *testing.T) {
+	want := rigidTransform(0.15, 0.02, -0.03, [3]float64{0.4, -0.3, 0.1})
+
+	algorithms := []struct {
+		name     string
+		register func(source, target *point.Points3D, params *Params) (*Result, error)
+	}{
+		{"PointToPoint", PointToPoint},
+		{"PointToPlane", PointToPlane},
+	}
+
+	search := []struct {
+		name   string
+		params func(p *Params)
+	}{
+		{"ApproxEpsilon", func(p *Params) { p.ApproxEpsilon = 0.5 }},
+		{"ApproxMaxLeaves", func(p *Params) { p.ApproxMaxLeaves = 32 }},
+		{"both", func(p *Params) { p.ApproxEpsilon, p.ApproxMaxLeaves = 0.5, 8 }},
+	}
+
+	for _, algorithm := range algorithms {
+		points := scene()
+		target := moved(points, transform.Matrix4Identity())
+		source := moved(points, inverse(want))
+
+		exact, err := algorithm.register(source.Copy(), target.Copy(), DefaultParams)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range search {
+			t.Run(algorithm.name+"/"+s.name, func(t *testing.T) {
+				params := *DefaultParams
+				s.params(&params)
+
+				result, err := algorithm.register(source.Copy(), target.Copy(), &params)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.ConvergenceReason != Converged {
+					t.Errorf("got %v, want converged", result.ConvergenceReason)
+				}
+
+				checkTransform(t, result.FinalTransform, want, 0.001, 0.001)
+				checkTransform(t, result.FinalTransform, exact.FinalTransform, 0.001, 0.001)
+			})
+		}
+	}
+}
@@ -1,10 +1,7 @@
 package icp
 
 import (
-	"fmt"
-
 	"github.com/flynnletford/icp-go/point"
-	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/gonum/spatial/kdtree"
 )
 
@@ -21,46 +18,43 @@ func ComputeNormals(tree *kdtree.Tree, points *point.Points3D, k int) error {
 			neighbors = append(neighbors, item.Comparable.(*point.Point3D))
 		}
 
-		// Compute covariance matrix
-		cov := mat.NewDense(3, 3, nil)
-		cx, cy, cz := pointsMean(neighbors)
-		for _, n := range neighbors {
-			dx, dy, dz := n.X-cx, n.Y-cy, n.Z-cz
-			cov.Set(0, 0, cov.At(0, 0)+dx*dx)
-			cov.Set(0, 1, cov.At(0, 1)+dx*dy)
-			cov.Set(0, 2, cov.At(0, 2)+dx*dz)
-			cov.Set(1, 1, cov.At(1, 1)+dy*dy)
-			cov.Set(1, 2, cov.At(1, 2)+dy*dz)
-			cov.Set(2, 2, cov.At(2, 2)+dz*dz)
+		wrapped := point.Points3D(neighbors)
+		nx, ny, nz, err := fitNormal(&wrapped, nil)
+		if err != nil {
+			return err
 		}
-		cov.Set(1, 0, cov.At(0, 1))
-		cov.Set(2, 0, cov.At(0, 2))
-		cov.Set(2, 1, cov.At(1, 2))
+		p.Nx, p.Ny, p.Nz = nx, ny, nz
+	}
 
-		// Compute SVD.
-		var svd mat.SVD
-		if ok := svd.Factorize(cov, mat.SVDFull); !ok {
-			return fmt.Errorf("failed to compute SVD")
-		}
-		U := mat.NewDense(3, 3, nil)
-		svd.UTo(U)
+	return nil
+}
 
-		// Normal is the eigenvector with smallest singular value (last column)
-		p.Nx = U.At(0, 2)
-		p.Ny = U.At(1, 2)
-		p.Nz = U.At(2, 2)
+// ComputeSurfaceNormals calculates the normals of the points of surface at the given indices using PCA on
+// their k nearest neighbors in searcher, which must hold points of surface.
+func ComputeSurfaceNormals(searcher point.NeighborSearcher, surface point.Surface, indices []int, k int) error {
+
+	for _, i := range indices {
+		x, y, z := surface.At(i)
+		neighbors, _ := searcher.Search(x, y, z, k+1, 0) // +1 to include the point itself
+
+		nx, ny, nz, err := fitNormal(surface, neighbors[1:]) // Skip first (itself)
+		if err != nil {
+			return err
+		}
+		surface.SetNormal(i, nx, ny, nz)
 	}
 
 	return nil
 }
 
-func pointsMean(points []*point.Point3D) (float64, float64, float64) {
-	var cx, cy, cz float64
-	n := float64(len(points))
-	for _, p := range points {
-		cx += p.X
-		cy += p.Y
-		cz += p.Z
+// fitNormal returns the normal of the plane best fitting the points of coords at the given indices, or every
+// point if indices is nil.
+func fitNormal(coords point.Coordinates, indices []int) (float64, float64, float64, error) {
+	axes, _, err := point.PrincipalAxes(coords, indices)
+	if err != nil {
+		return 0, 0, 0, err
 	}
-	return cx / n, cy / n, cz / n
+
+	// Normal is the axis with the smallest variance.
+	return axes[2].X, axes[2].Y, axes[2].Z, nil
 }
@@ -1,17 +1,46 @@
 package icp
 
+import "github.com/team-rocos/go-common/transform"
+
 type Params struct {
 	MaxIterations int           `json:"maxIterations"`
 	Tolerance     float64       `json:"tolerance"`
 	FilterParams  *FilterParams `json:"filterParams"`
 
-	// Source and target points will not be matched during correspondence finding if their distance exceeds this value.
+	// Source and target points will not be matched during correspondence finding if their distance exceeds this value,
+	// so that parts of the source with no overlap in the target do not pull on the solution. Zero matches every point.
 	MaxCorrespondenceDistance float64 `json:"maxCorrespondenceDistance"`
 
+	// Optional correspondence distances used in turn instead of MaxCorrespondenceDistance, moving to the next each
+	// time registration converges, e.g. 1, 0.5, 0.2 to align coarsely before refining. Iterations of every stage
+	// count towards MaxIterations.
+	CorrespondenceSchedule []float64 `json:"correspondenceSchedule"`
+
 	// Number of neighbors to consider when computing normals.
 	// Smaller values: 10-20 will result in maintaining sharp features. More prone to noise.
 	// Larger values: 30-50 will result in smoother surfaces. Less prone to noise at the cost of blurring features and computational load.
 	NumNeighborsNormals int `json:"numNeighborsNormals"`
+
+	// Use the normals already held by the target points, e.g. those sampled from a mesh, instead of estimating them.
+	UseTargetNormals bool `json:"useTargetNormals"`
+
+	// Registration is solved around the centroid of the target, or of the source when matching against a
+	// NeighborSearcher, when it lies further than this from the origin, e.g. for UTM or ECEF coordinates, so
+	// that the linearization keeps its precision. The transform returned is still expressed in the original
+	// frame. Zero disables the shift.
+	OriginShiftThreshold float64 `json:"originShiftThreshold"`
+
+	// Correspondences are found with an approximate nearest neighbor search when either of these is set,
+	// which is faster on dense clouds and still converges. A match may be up to 1 + ApproxEpsilon times
+	// further than the nearest target point, and at most ApproxMaxLeaves leaves of the tree over the target
+	// are checked for each source point. Searchers passed to the Searcher variants are always queried exactly.
+	// Zero searches exactly.
+	ApproxEpsilon   float64 `json:"approxEpsilon"`
+	ApproxMaxLeaves int     `json:"approxMaxLeaves"`
+
+	// Optional estimate of the transform from the source to the target, e.g. from odometry or GPS. It is applied to
+	// the source before matching, so that large motions converge, and is included in Result.FinalTransform.
+	InitialTransform *transform.Matrix4 `json:"initialTransform,omitempty"`
 }
 
 type FilterParams struct {
@@ -33,6 +62,7 @@ var DefaultParams *Params = &Params{
 	Tolerance:                 1e-4,
 	MaxCorrespondenceDistance: 2.0,
 	NumNeighborsNormals:       30, // 30 seems good.
+	OriginShiftThreshold:      1000,
 	FilterParams:              DefaultFilterParams,
 }
 
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/flynnletford/icp-go/point"
-	"github.com/pkg/errors"
 	"github.com/team-rocos/go-common/transform"
 	"gonum.org/v1/gonum/mat"
 )
@@ -15,73 +14,182 @@ func PointToPlane(source *point.Points3D, target *point.Points3D, params *Params
 
 	startTime := time.Now()
 
-	_, transformed := Filter(source, params)
-	tree, _ := Filter(target, params)
+	r := newRegistration(source, target, params)
 
-	if err := ComputeNormals(tree, target, params.NumNeighborsNormals); err != nil {
-		return nil, errors.Wrap(err, "failed to compute normals")
+	finalTransform, err := r.pointToPlane()
+	if err != nil {
+		return nil, err
 	}
 
-	// Visualize(target)
+	result := &Result{
+		FinalTransform:    finalTransform,
+		TransformedPoints: r.source.Points(),
+		ElapsedTime:       time.Since(startTime),
+		NumTargetPoints:   target.Len(),
+		NumSourcePoints:   source.Len(),
+		LocalOrigin:       r.origin,
+		Evaluation:        r.evaluation,
+	}
+
+	return result, nil
+}
+
+// PointToPlaneCloud performs point-to-plane ICP on clouds stored as contiguous arrays. The transformed
+// source is returned in Result.TransformedCloud.
+func PointToPlaneCloud(source *point.Cloud, target *point.Cloud, params *Params) (*Result, error) {
+
+	startTime := time.Now()
+
+	r := newRegistration(source, target, params)
+
+	finalTransform, err := r.pointToPlane()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		FinalTransform:   finalTransform,
+		TransformedCloud: r.source,
+		ElapsedTime:      time.Since(startTime),
+		NumTargetPoints:  target.Len(),
+		NumSourcePoints:  source.Len(),
+		LocalOrigin:      r.origin,
+		Evaluation:       r.evaluation,
+	}
+
+	return result, nil
+}
+
+// PointToPlaneCloud32 performs point-to-plane ICP on float32 clouds, accumulating the normal equations in
+// float64. Estimated normals are stored in the target as float32. The transformed source is returned in
+// Result.TransformedCloud.
+func PointToPlaneCloud32(source *point.Cloud32, target *point.Cloud32, params *Params) (*Result, error) {
+
+	startTime := time.Now()
+
+	r := newRegistration(source, target, params)
+
+	finalTransform, err := r.pointToPlane()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		FinalTransform:   finalTransform,
+		TransformedCloud: r.source,
+		ElapsedTime:      time.Since(startTime),
+		NumTargetPoints:  target.Len(),
+		NumSourcePoints:  source.Len(),
+		LocalOrigin:      r.origin,
+		Evaluation:       r.evaluation,
+	}
+
+	return result, nil
+}
+
+// PointToPlaneSearcher performs point-to-plane ICP against every point held by a searcher over the target, such as a
+// map maintained incrementally with a point.IKDTree, VoxelMap or Octree. The target is not downsampled,
+// and its coordinates must implement point.Surface. Normals are estimated for the target points matched
+// unless UseTargetNormals is set.
+func PointToPlaneSearcher(source *point.Points3D, target point.NeighborSearcher, params *Params) (*Result, error) {
+
+	startTime := time.Now()
+
+	r, err := newSearcherRegistration(source, target, params)
+	if err != nil {
+		return nil, err
+	}
+
+	finalTransform, err := r.pointToPlane()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		FinalTransform:    finalTransform,
+		TransformedPoints: r.source.Points(),
+		ElapsedTime:       time.Since(startTime),
+		NumTargetPoints:   target.Len(),
+		NumSourcePoints:   source.Len(),
+		LocalOrigin:       r.origin,
+		Evaluation:        r.evaluation,
+	}
+
+	return result, nil
+}
+
+func (r *registration) pointToPlane() (*transform.Matrix4, error) {
+
+	// Normals are estimated the first time a target point is matched.
+	if !r.params.UseTargetNormals {
+		r.estimated = make([]bool, r.target.Len())
+	}
+
+	r.evaluation.ConvergenceReason = MaxIterationsReached
 
 	// Initialise our final transform calculated.
 	finalTransform := transform.Matrix4Identity()
 
-	for iter := 0; iter < params.MaxIterations; iter++ {
+	for iter := 0; iter < r.params.MaxIterations; iter++ {
 		// Step 1: Find closest points in target.
-		correspondences := make([][2]*point.Point3D, 0, transformed.Len())
+		closest, distances := r.closestPoints()
+
+		// Step 2: Construct Ax = b system
+		var A [6][6]float64
+		var b [6]float64
+
+		numValid := 0
 
-		for _, p := range transformed.Raw() {
-			nearest, _ := tree.Nearest(p)
-			if nearest == nil {
+		for i, j := range closest {
+			if j < 0 || !r.accepts(distances[i]) {
 				continue
 			}
-			// if dist > params.MaxCorrespondenceDistance {
-			// 	continue
-			// }
+			numValid++
 
-			targetPoint := nearest.(*point.Point3D)
-			correspondences = append(correspondences, [2]*point.Point3D{p, targetPoint})
-		}
-
-		// Step 2: Construct Ax = b system
-		A := mat.NewDense(6, 6, nil)
-		b := mat.NewVecDense(6, nil)
+			nx, ny, nz, err := r.normal(j)
+			if err != nil {
+				return nil, err
+			}
 
-		for _, pair := range correspondences {
-			src, tgt := pair[0], pair[1]
+			sx, sy, sz := r.source.At(i)
+			tx, ty, tz := r.target.At(j)
 
 			// Compute residual = (R * src + t - tgt) ⋅ normal
-			r := mat.NewVecDense(3, []float64{src.X - tgt.X, src.Y - tgt.Y, src.Z - tgt.Z})
-			n := mat.NewVecDense(3, []float64{tgt.Nx, tgt.Ny, tgt.Nz})
-			residual := mat.Dot(r, n)
+			residual := (sx-tx)*nx + (sy-ty)*ny + (sz-tz)*nz
 
 			// Compute Jacobian
-			J := []float64{
-				src.Y*tgt.Nz - src.Z*tgt.Ny, // d(res)/d(rx)
-				src.Z*tgt.Nx - src.X*tgt.Nz, // d(res)/d(ry)
-				src.X*tgt.Ny - src.Y*tgt.Nx, // d(res)/d(rz)
-				tgt.Nx, tgt.Ny, tgt.Nz,      // d(res)/d(tx, ty, tz)
+			J := [6]float64{
+				sy*nz - sz*ny, // d(res)/d(rx)
+				sz*nx - sx*nz, // d(res)/d(ry)
+				sx*ny - sy*nx, // d(res)/d(rz)
+				nx, ny, nz,    // d(res)/d(tx, ty, tz)
 			}
 
 			// Update A and b
 			for i := 0; i < 6; i++ {
-				b.SetVec(i, b.AtVec(i)-residual*J[i])
+				b[i] -= residual * J[i]
 				for j := 0; j < 6; j++ {
-					A.Set(i, j, A.At(i, j)+J[i]*J[j])
+					A[i][j] += J[i] * J[j]
 				}
 			}
 		}
 
+		if numValid == 0 {
+			if err := r.stop(iter, errNoCorrespondences); err != nil {
+				return nil, err
+			}
+			break
+		}
+
 		// Step 3: Solve Ax = b using least squares
 		var x mat.VecDense
-		if err := x.SolveVec(A, b); err != nil {
-			return nil, errors.Wrap(err, "failed to solve linear system")
+		if numValid < 6 || x.SolveVec(mat.NewDense(6, 6, flatten(A)), mat.NewVecDense(6, b[:])) != nil {
+			r.evaluation.ConvergenceReason = Degenerate
+			break
 		}
 
 		// Step 4: Update transformation (small-angle approximation).
 		rotationUpdate := SmallAngleRotation(x.AtVec(0), x.AtVec(1), x.AtVec(2))
-		// translationUpdate := &point.Point3D{X: x.AtVec(3), Y: x.AtVec(4), Z: x.AtVec(5)}
 
 		elements := [4][4]float64{
 			{rotationUpdate.At(0, 0), rotationUpdate.At(0, 1), rotationUpdate.At(0, 2), x.AtVec(3)},
@@ -92,26 +200,40 @@ func PointToPlane(source *point.Points3D, target *point.Points3D, params *Params
 
 		tform := transform.NewMatrix4FromElements(elements)
 
-		TransformPoints(transformed, tform)
-
-		// Update our transform.
-		finalTransform = finalTransform.Dot(tform)
-
-		// Step 5: Check convergence
-		if isWithinThreshold(tform, params.Tolerance) {
+		// Step 5: Apply the transform and check convergence.
+		var done bool
+		if finalTransform, done = r.update(iter, tform, finalTransform); done {
 			break
 		}
 	}
 
-	result := &Result{
-		FinalTransform:    finalTransform,
-		TransformedPoints: transformed,
-		ElapsedTime:       time.Since(startTime),
-		NumTargetPoints:   target.Len(),
-		NumSourcePoints:   target.Len(),
+	if err := r.evaluate(r.planeResidual); err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	return r.finish(finalTransform), nil
+}
+
+// planeResidual returns the distance from source point i to the tangent plane of target point j.
+func (r *registration) planeResidual(i, j int) (float64, error) {
+	nx, ny, nz, err := r.normal(j)
+	if err != nil {
+		return 0, err
+	}
+
+	sx, sy, sz := r.source.At(i)
+	tx, ty, tz := r.target.At(j)
+
+	return (sx-tx)*nx + (sy-ty)*ny + (sz-tz)*nz, nil
+}
+
+// flatten returns the elements of a 6x6 matrix in row-major order.
+func flatten(m [6][6]float64) []float64 {
+	elements := make([]float64, 0, 36)
+	for _, row := range m {
+		elements = append(elements, row[:]...)
+	}
+	return elements
 }
 
 // SmallAngleRotation creates a small rotation matrix using Rodrigues' formula.
@@ -0,0 +1,267 @@
+package icp
+
+import (
+	"github.com/flynnletford/icp-go/point"
+	"github.com/pkg/errors"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// registration holds the state shared by the registration algorithms: a downsampled working copy of the source
+// that is transformed in place, and a searcher over the target. The source is always held in float64 and
+// target positions are read as float64, so every sum is accumulated in float64 whatever the precision the
+// target is stored with.
+type registration struct {
+	source   *point.Cloud
+	target   point.Surface
+	searcher point.NeighborSearcher
+
+	// Correspondence search, replacing the exact searcher.Nearest if set.
+	nearest func(x, y, z float64) (int, float64)
+
+	// Local origin subtracted from both clouds while solving, or nil if they are used as given.
+	origin *point.Point3D
+
+	// Position in Params.CorrespondenceSchedule.
+	stage int
+
+	// Target points whose normals have been estimated, or nil if the normals of the target are used.
+	estimated []bool
+
+	evaluation Evaluation
+
+	params *Params
+}
+
+// newRegistration matches the source against a tree over the downsampled points of the target.
+func newRegistration(source point.Coordinates, target point.Surface, params *Params) *registration {
+	targetIndices := VoxelIndices(target, params.FilterParams.VoxelSize)
+
+	r := &registration{
+		source: workingSource(source, params),
+		target: target,
+		params: params,
+	}
+
+	if len(targetIndices) > 0 {
+		r.shiftOrigin(point.Centroid(target, targetIndices))
+	}
+
+	tree := point.NewKDTree(r.target, targetIndices)
+	r.searcher = tree
+
+	if params.ApproxEpsilon > 0 || params.ApproxMaxLeaves > 0 {
+		r.nearest = func(x, y, z float64) (int, float64) {
+			return tree.NearestApprox(x, y, z, params.ApproxEpsilon, params.ApproxMaxLeaves)
+		}
+	}
+
+	return r
+}
+
+// newSearcherRegistration matches the source against every point held by a searcher over the target.
+func newSearcherRegistration(source point.Coordinates, target point.NeighborSearcher, params *Params) (*registration, error) {
+	surface, ok := target.Coordinates().(point.Surface)
+	if !ok {
+		return nil, errors.Errorf("target of type %T has no normals", target.Coordinates())
+	}
+
+	r := &registration{
+		source:   workingSource(source, params),
+		target:   surface,
+		searcher: target,
+		params:   params,
+	}
+
+	// The points held by the searcher are not known up front, so the source picks the origin.
+	if r.source.Len() > 0 {
+		r.shiftOrigin(point.Centroid(r.source, nil))
+	}
+	if r.origin != nil {
+		r.searcher = &shiftedSearcher{NeighborSearcher: target, surface: r.target, origin: r.origin}
+	}
+
+	return r, nil
+}
+
+// shiftOrigin moves both clouds to a local origin at centroid if it lies further than
+// Params.OriginShiftThreshold from the origin.
+func (r *registration) shiftOrigin(centroid *point.Point3D) {
+	if r.params.OriginShiftThreshold <= 0 || centroid.Length() <= r.params.OriginShiftThreshold {
+		return
+	}
+
+	r.origin = centroid
+	r.target = &shiftedSurface{Surface: r.target, origin: centroid}
+	for i := 0; i < r.source.Len(); i++ {
+		x, y, z := r.source.At(i)
+		r.source.Set(i, x-centroid.X, y-centroid.Y, z-centroid.Z)
+	}
+}
+
+// workingSource returns the downsampled working copy of the source, moved by the initial transform if there is one.
+func workingSource(source point.Coordinates, params *Params) *point.Cloud {
+	cloud := workingCopy(source, VoxelIndices(source, params.FilterParams.VoxelSize))
+
+	if params.InitialTransform != nil {
+		TransformCloud(cloud, params.InitialTransform)
+	}
+
+	return cloud
+}
+
+// finish moves the source back to the original frame and returns the transform from the original source to the
+// target: the transform found in the local frame, expressed in the original frame, after the initial transform.
+func (r *registration) finish(local *transform.Matrix4) *transform.Matrix4 {
+	final := local
+
+	if o := r.origin; o != nil {
+		for i := 0; i < r.source.Len(); i++ {
+			x, y, z := r.source.At(i)
+			r.source.Set(i, x+o.X, y+o.Y, z+o.Z)
+		}
+
+		toOriginal := transform.NewMatrix4FromElements([4][4]float64{{1, 0, 0, o.X}, {0, 1, 0, o.Y}, {0, 0, 1, o.Z}, {0, 0, 0, 1}})
+		toLocal := transform.NewMatrix4FromElements([4][4]float64{{1, 0, 0, -o.X}, {0, 1, 0, -o.Y}, {0, 0, 1, -o.Z}, {0, 0, 0, 1}})
+
+		final = toOriginal.Dot(local).Dot(toLocal)
+	}
+
+	if r.params.InitialTransform != nil {
+		final = final.Dot(r.params.InitialTransform)
+	}
+
+	return final
+}
+
+// shiftedSurface presents a surface relative to a local origin.
+type shiftedSurface struct {
+	point.Surface
+	origin *point.Point3D
+}
+
+func (s *shiftedSurface) At(i int) (float64, float64, float64) {
+	x, y, z := s.Surface.At(i)
+	return x - s.origin.X, y - s.origin.Y, z - s.origin.Z
+}
+
+// shiftedSearcher queries a searcher built in the original frame with positions relative to a local origin.
+type shiftedSearcher struct {
+	point.NeighborSearcher
+	surface point.Surface
+	origin  *point.Point3D
+}
+
+func (s *shiftedSearcher) Coordinates() point.Coordinates {
+	return s.surface
+}
+
+func (s *shiftedSearcher) Nearest(x, y, z float64) (int, float64) {
+	return s.NeighborSearcher.Nearest(x+s.origin.X, y+s.origin.Y, z+s.origin.Z)
+}
+
+func (s *shiftedSearcher) Search(x, y, z float64, k int, radius float64) ([]int, []float64) {
+	return s.NeighborSearcher.Search(x+s.origin.X, y+s.origin.Y, z+s.origin.Z, k, radius)
+}
+
+// workingCopy copies the points of source at the given indices into a cloud, keeping their attributes.
+func workingCopy(source point.Coordinates, indices []int) *point.Cloud {
+	switch s := source.(type) {
+	case *point.Cloud:
+		return s.Subset(indices)
+	case *point.Cloud32:
+		return s.Subset(indices).Cloud()
+	case *point.Points3D:
+		cloud := point.NewCloud(len(indices), s.Attributes())
+		for _, i := range indices {
+			cloud.Append((*s)[i])
+		}
+		return cloud
+	default:
+		cloud := point.NewCloud(len(indices), point.Attributes{})
+		for _, i := range indices {
+			x, y, z := s.At(i)
+			cloud.Append(&point.Point3D{X: x, Y: y, Z: z})
+		}
+		return cloud
+	}
+}
+
+// closestPoints returns the index of the target point closest to each source point and its squared distance.
+func (r *registration) closestPoints() ([]int, []float64) {
+	n := r.source.Len()
+	closest := make([]int, n)
+	distances := make([]float64, n)
+
+	nearest := r.nearest
+	if nearest == nil {
+		nearest = r.searcher.Nearest
+	}
+
+	for i := 0; i < n; i++ {
+		closest[i], distances[i] = nearest(r.source.At(i))
+	}
+
+	return closest, distances
+}
+
+// normal returns the normal of target point j, estimating it the first time it is needed unless the normals
+// held by the target are used.
+func (r *registration) normal(j int) (float64, float64, float64, error) {
+	if r.estimated != nil && !r.estimated[j] {
+		if err := ComputeSurfaceNormals(r.searcher, r.target, []int{j}, r.params.NumNeighborsNormals); err != nil {
+			return 0, 0, 0, errors.Wrap(err, "failed to compute normals")
+		}
+		r.estimated[j] = true
+	}
+
+	nx, ny, nz := r.target.Normal(j)
+	return nx, ny, nz, nil
+}
+
+// accepts reports whether a source and target point at the given squared distance form a correspondence at
+// the current stage.
+func (r *registration) accepts(distance float64) bool {
+	maxDistance := r.params.MaxCorrespondenceDistance
+	if len(r.params.CorrespondenceSchedule) > 0 {
+		maxDistance = r.params.CorrespondenceSchedule[r.stage]
+	}
+
+	return maxDistance <= 0 || distance <= maxDistance*maxDistance
+}
+
+// converged reports whether registration is complete after applying tform, moving on to the next stage of the
+// correspondence schedule if the current one has converged.
+func (r *registration) converged(tform *transform.Matrix4) bool {
+	if !isWithinThreshold(tform, r.params.Tolerance) {
+		return false
+	}
+	if r.stage+1 < len(r.params.CorrespondenceSchedule) {
+		r.stage++
+		return false
+	}
+	return true
+}
+
+// TransformCloud applies tform to the positions and normals of a cloud in place.
+func TransformCloud(cloud *point.Cloud, tform *transform.Matrix4) {
+
+	// If the transform is the identity matrix, return early.
+	if isIdentity(tform) {
+		return
+	}
+
+	// Normals are only rotated, so remove the translation picked up when transforming them as positions.
+	origin := tform.MulVec3(&transform.Vector3{})
+
+	for i := 0; i < cloud.Len(); i++ {
+		x, y, z := cloud.At(i)
+		transformed := tform.MulVec3(&transform.Vector3{X: x, Y: y, Z: z})
+		cloud.Set(i, transformed.X, transformed.Y, transformed.Z)
+
+		if cloud.Normals != nil {
+			nx, ny, nz := cloud.Normal(i)
+			normal := tform.MulVec3(&transform.Vector3{X: nx, Y: ny, Z: nz})
+			cloud.SetNormal(i, normal.X-origin.X, normal.Y-origin.Y, normal.Z-origin.Z)
+		}
+	}
+}
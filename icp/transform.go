@@ -67,9 +67,12 @@ func TransformPoint(p *point.Point3D, tform *mat.Dense) *point.Point3D {
 	y := tform.At(1, 0)*p.X + tform.At(1, 1)*p.Y + tform.At(1, 2)*p.Z + tform.At(1, 3)
 	z := tform.At(2, 0)*p.X + tform.At(2, 1)*p.Y + tform.At(2, 2)*p.Z + tform.At(2, 3)
 
-	return &point.Point3D{
-		X: x,
-		Y: y,
-		Z: z,
-	}
+	// Copy the point so that its attributes carry over, rotating the normal.
+	transformed := *p
+	transformed.X, transformed.Y, transformed.Z = x, y, z
+	transformed.Nx = tform.At(0, 0)*p.Nx + tform.At(0, 1)*p.Ny + tform.At(0, 2)*p.Nz
+	transformed.Ny = tform.At(1, 0)*p.Nx + tform.At(1, 1)*p.Ny + tform.At(1, 2)*p.Nz
+	transformed.Nz = tform.At(2, 0)*p.Nx + tform.At(2, 1)*p.Ny + tform.At(2, 2)*p.Nz
+
+	return &transformed
 }
@@ -12,29 +12,41 @@ type Voxel struct {
 	K int // Voxel index on the z axis.
 }
 
-// Voxelize converts a set of 3D points into a voxelized set while preserving original coordinates.
+// Voxelize converts a set of 3D points into a voxelized set, keeping the coordinates and attributes of the first point in each voxel.
 func Voxelize(points []*point.Point3D, voxelSize float64) *point.Points3D {
-	voxelMap := make(map[Voxel]*point.Point3D) // Stores the first point mapped to each voxel
+	wrapped := point.Points3D(points)
 
-	for i := range points {
+	indices := VoxelIndices(&wrapped, voxelSize)
+
+	// Convert back to a slice of points where we only have one point per voxel.
+	result := make([]*point.Point3D, len(indices))
+	for i, index := range indices {
+		result[i] = points[index]
+	}
+
+	val := point.Points3D(result)
+	return &val
+}
+
+// VoxelIndices returns the index of the first point in each occupied voxel, in the order the points appear.
+func VoxelIndices(coords point.Coordinates, voxelSize float64) []int {
+	occupied := make(map[Voxel]struct{})
+	indices := make([]int, 0)
+
+	for i := 0; i < coords.Len(); i++ {
+		x, y, z := coords.At(i)
 		v := Voxel{
-			I: int(math.Floor(points[i].X / voxelSize)),
-			J: int(math.Floor(points[i].Y / voxelSize)),
-			K: int(math.Floor(points[i].Z / voxelSize)),
+			I: int(math.Floor(x / voxelSize)),
+			J: int(math.Floor(y / voxelSize)),
+			K: int(math.Floor(z / voxelSize)),
 		}
 
 		// Store only the first encountered point per voxel.
-		if _, exists := voxelMap[v]; !exists {
-			voxelMap[v] = points[i]
+		if _, exists := occupied[v]; !exists {
+			occupied[v] = struct{}{}
+			indices = append(indices, i)
 		}
 	}
 
-	// Convert back to a slice of points where we only have one point per voxel.
-	result := make([]*point.Point3D, 0, len(voxelMap))
-	for _, p := range voxelMap {
-		result = append(result, p)
-	}
-
-	val := point.Points3D(result)
-	return &val
+	return indices
 }
@@ -0,0 +1,130 @@
+package kitti
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+	"github.com/team-rocos/go-common/transform"
+)
+
+// scan returns the Velodyne records of points, using their intensity as the reflectance.
+func scan(points []point.Point3D) []byte {
+	var b []byte
+	for _, p := range points {
+		for _, v := range []float64{p.X, p.Y, p.Z, p.Intensity} {
+			b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+		}
+	}
+	return b
+}
+
+func TestDecodeScan(t *testing.T) {
+	points := []point.Point3D{
+		{X: 10.5, Y: -2.25, Z: -1.75, Intensity: 0.25},
+		{X: 0.5, Y: 0.5, Z: 0, Intensity: 0},
+		{X: -60, Y: 30, Z: 2, Intensity: 1},
+	}
+
+	tests := []struct {
+		name string
+		opts *ReadOptions
+		want []point.Point3D
+	}{
+		{name: "every point", want: points},
+		{name: "cropped", opts: &ReadOptions{CropOptions: point.CropOptions{MinRange: 1, MaxRange: 50}}, want: points[:1]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeScan(bytes.NewReader(scan(points)), tt.opts)
+			if err != nil {
+				t.Fatalf("DecodeScan: %v", err)
+			}
+
+			if decoded.Len() != len(tt.want) {
+				t.Fatalf("got %d points, want %d", decoded.Len(), len(tt.want))
+			}
+			for i, p := range decoded.Raw() {
+				if *p != tt.want[i] {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, tt.want[i])
+				}
+			}
+		})
+	}
+
+	truncated := scan(points)[:2*scanRecordSize+5]
+	if _, err := DecodeScan(bytes.NewReader(truncated), nil); err == nil || !strings.Contains(err.Error(), "point 2") {
+		t.Errorf("got error %v, want the scan to end within point 2", err)
+	}
+}
+
+func TestEncodeDecodePoses(t *testing.T) {
+	yaw, pitch := 0.3, -0.1
+	cy, sy, cp, sp := math.Cos(yaw), math.Sin(yaw), math.Cos(pitch), math.Sin(pitch)
+
+	poses := []*transform.Matrix4{
+		transform.NewMatrix4FromElements([4][4]float64{
+			{1, 0, 0, 0},
+			{0, 1, 0, 0},
+			{0, 0, 1, 0},
+			{0, 0, 0, 1},
+		}),
+		// Yaw about z after pitch about y, with a translation far from the origin.
+		transform.NewMatrix4FromElements([4][4]float64{
+			{cy * cp, -sy, cy * sp, 1234.5678},
+			{sy * cp, cy, sy * sp, -0.000123},
+			{-sp, 0, cp, 42},
+			{0, 0, 0, 1},
+		}),
+	}
+
+	var buf bytes.Buffer
+	if err := EncodePoses(&buf, poses); err != nil {
+		t.Fatalf("EncodePoses: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != len(poses) || len(strings.Fields(lines[0])) != 12 {
+		t.Fatalf("got %q, want %d lines of 12 values", buf.String(), len(poses))
+	}
+
+	decoded, err := DecodePoses(&buf)
+	if err != nil {
+		t.Fatalf("DecodePoses: %v", err)
+	}
+	if len(decoded) != len(poses) {
+		t.Fatalf("got %d poses, want %d", len(decoded), len(poses))
+	}
+
+	for i := range poses {
+		got, want := topRows(decoded[i]), topRows(poses[i])
+		for j := range want {
+			if math.Abs(got[j]-want[j]) > 1e-9*math.Max(1, math.Abs(want[j])) {
+				t.Errorf("pose %d: got %v, want %v", i, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestDecodePosesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{name: "too few values", file: "1 0 0 0 0 1 0 0 0 0 1\n"},
+		{name: "non-numeric value", file: "1 0 0 0 0 1 0 0 0 0 1 zero\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodePoses(strings.NewReader(tt.file)); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package kitti
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/team-rocos/go-common/transform"
+)
+
+// Poses files hold one pose per line as the first three rows of a 4x4 homogeneous transform in row-major
+// order. Ground truth poses of the odometry benchmark are expressed in the frame of the left camera.
+
+// ReadPoses reads a poses file.
+func ReadPoses(filePath string) ([]*transform.Matrix4, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodePoses(file)
+}
+
+// DecodePoses reads a poses stream.
+func DecodePoses(r io.Reader) ([]*transform.Matrix4, error) {
+	scanner := bufio.NewScanner(r)
+
+	poses := make([]*transform.Matrix4, 0)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 12 {
+			return nil, fmt.Errorf("line %d has %d values, expected 12", line, len(fields))
+		}
+
+		elements := [4][4]float64{3: {0, 0, 0, 1}}
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			elements[i/4][i%4] = v
+		}
+
+		poses = append(poses, transform.NewMatrix4FromElements(elements))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return poses, nil
+}
+
+// WritePoses writes poses, e.g. the accumulated icp.Result.FinalTransform of each scan, to a poses file
+// replacing any existing file.
+func WritePoses(filePath string, poses []*transform.Matrix4) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := EncodePoses(file, poses); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// EncodePoses writes poses to w.
+func EncodePoses(w io.Writer, poses []*transform.Matrix4) error {
+	bw := bufio.NewWriter(w)
+
+	var buf []byte
+	for _, pose := range poses {
+		buf = buf[:0]
+		for i, v := range topRows(pose) {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = strconv.AppendFloat(buf, v, 'e', 9, 64)
+		}
+		buf = append(buf, '\n')
+
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// topRows returns the first three rows of the transform in row-major order.
+func topRows(tform *transform.Matrix4) [12]float64 {
+	t := tform.Translation()
+
+	// Column j of the rotation is the image of the jth unit vector without the translation.
+	var rows [12]float64
+	for j, axis := range []*transform.Vector3{{X: 1}, {Y: 1}, {Z: 1}} {
+		v := tform.MulVec3(axis)
+		rows[j] = v.X - t.X
+		rows[4+j] = v.Y - t.Y
+		rows[8+j] = v.Z - t.Z
+	}
+	rows[3], rows[7], rows[11] = t.X, t.Y, t.Z
+
+	return rows
+}
@@ -0,0 +1,92 @@
+package kitti
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// scanRecordSize is the size of a Velodyne point: x, y, z and reflectance as little-endian float32.
+const scanRecordSize = 16
+
+type ReadOptions struct {
+	// Cropping applied to the points once read. The zero value keeps every point.
+	point.CropOptions
+}
+
+// DefaultReadOptions returns every point in the scan unmodified.
+var DefaultReadOptions *ReadOptions = &ReadOptions{}
+
+// ReadScan reads a Velodyne .bin scan, storing the reflectance of each point as its intensity.
+func ReadScan(filePath string, opts *ReadOptions) (*point.Points3D, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeScan(file, opts)
+}
+
+// ReadScanCloud reads a Velodyne .bin scan into a point.Cloud.
+func ReadScanCloud(filePath string, opts *ReadOptions) (*point.Cloud, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeScanCloud(file, opts)
+}
+
+// DecodeScan reads a Velodyne scan stream.
+func DecodeScan(r io.Reader, opts *ReadOptions) (*point.Points3D, error) {
+	cloud, err := DecodeScanCloud(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return cloud.Points(), nil
+}
+
+// DecodeScanCloud reads a Velodyne scan stream into a point.Cloud, cropping it according to opts.
+func DecodeScanCloud(r io.Reader, opts *ReadOptions) (*point.Cloud, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	br := bufio.NewReader(r)
+
+	cloud := point.NewCloud(0, point.Attributes{Intensity: true})
+	var record [scanRecordSize]byte
+
+	for i := 0; ; i++ {
+		if _, err := io.ReadFull(br, record[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil, fmt.Errorf("scan ends within point %d", i)
+			}
+			return nil, err
+		}
+
+		p := point.Point3D{
+			X:         float64(math.Float32frombits(binary.LittleEndian.Uint32(record[0:]))),
+			Y:         float64(math.Float32frombits(binary.LittleEndian.Uint32(record[4:]))),
+			Z:         float64(math.Float32frombits(binary.LittleEndian.Uint32(record[8:]))),
+			Intensity: float64(math.Float32frombits(binary.LittleEndian.Uint32(record[12:]))),
+		}
+		if opts.Accept(&p) {
+			cloud.Append(&p)
+		}
+	}
+
+	return cloud, nil
+}
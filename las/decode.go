@@ -0,0 +1,143 @@
+package las
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Data is the decoded content of a LAS file.
+type Data struct {
+	Header *Header
+
+	// Points holds the scaled and offset coordinates together with intensity, color, GPS time and classification.
+	Points *point.Points3D
+
+	// Extra holds the remaining point record attributes keyed by channel name, one value per point.
+	Extra map[string][]float64
+}
+
+// Decode reads a LAS stream and crops its points according to opts.
+func Decode(r io.Reader, opts *ReadOptions) (*point.Points3D, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	data, err := DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Points.Crop(&opts.CropOptions), nil
+}
+
+// DecodeAll reads a complete LAS stream. Extended variable length records following the points are not read.
+func DecodeAll(r io.Reader) (*Data, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	// The point count is not trusted to size allocations: slices start with at most maxPrealloc entries and
+	// grow as points are actually read.
+	n := int(min(h.NumPoints, maxPrealloc))
+
+	channels := []string{ClassificationFlags, ReturnNumber, NumberOfReturns, ScanDirectionFlag, EdgeOfFlightLine, ScanAngle, UserData, PointSourceID}
+	if h.PointFormat >= 6 {
+		channels = append(channels, ScannerChannel)
+	}
+	if h.PointFormat == 8 {
+		channels = append(channels, NIR)
+	}
+
+	extra := make(map[string][]float64, len(channels))
+	for _, name := range channels {
+		extra[name] = make([]float64, 0, n)
+	}
+
+	points := make(point.Points3D, 0, n)
+	var storage []point.Point3D
+	record := make([]byte, h.PointRecordLength)
+
+	le := binary.LittleEndian
+
+	for i := 0; uint64(i) < h.NumPoints; i++ {
+		if _, err := io.ReadFull(br, record); err != nil {
+			return nil, fmt.Errorf("failed to read point %d: %w", i, err)
+		}
+
+		if len(storage) == 0 {
+			storage = make([]point.Point3D, min(h.NumPoints-uint64(i), maxPrealloc))
+		}
+		p := &storage[0]
+		storage = storage[1:]
+
+		for _, name := range channels {
+			extra[name] = append(extra[name], 0)
+		}
+		p.X = float64(int32(le.Uint32(record[0:])))*h.Scale[0] + h.Offset[0]
+		p.Y = float64(int32(le.Uint32(record[4:])))*h.Scale[1] + h.Offset[1]
+		p.Z = float64(int32(le.Uint32(record[8:])))*h.Scale[2] + h.Offset[2]
+		p.Intensity = float64(le.Uint16(record[12:]))
+
+		var rgbOffset int
+
+		if h.PointFormat < 6 {
+			returns := record[14]
+			extra[ReturnNumber][i] = float64(returns & 0x07)
+			extra[NumberOfReturns][i] = float64(returns >> 3 & 0x07)
+			extra[ScanDirectionFlag][i] = float64(returns >> 6 & 0x01)
+			extra[EdgeOfFlightLine][i] = float64(returns >> 7)
+			p.Label = int(record[15] & 0x1f)
+			extra[ClassificationFlags][i] = float64(record[15] >> 5)
+			extra[ScanAngle][i] = float64(int8(record[16]))
+			extra[UserData][i] = float64(record[17])
+			extra[PointSourceID][i] = float64(le.Uint16(record[18:]))
+
+			rgbOffset = 20
+			if hasGPSTime(h.PointFormat) {
+				p.Time = math.Float64frombits(le.Uint64(record[20:]))
+				rgbOffset = 28
+			}
+		} else {
+			extra[ReturnNumber][i] = float64(record[14] & 0x0f)
+			extra[NumberOfReturns][i] = float64(record[14] >> 4)
+			extra[ClassificationFlags][i] = float64(record[15] & 0x0f)
+			extra[ScannerChannel][i] = float64(record[15] >> 4 & 0x03)
+			extra[ScanDirectionFlag][i] = float64(record[15] >> 6 & 0x01)
+			extra[EdgeOfFlightLine][i] = float64(record[15] >> 7)
+			p.Label = int(record[16])
+			extra[UserData][i] = float64(record[17])
+			extra[ScanAngle][i] = float64(int16(le.Uint16(record[18:]))) * scanAngleUnit
+			extra[PointSourceID][i] = float64(le.Uint16(record[20:]))
+			p.Time = math.Float64frombits(le.Uint64(record[22:]))
+
+			rgbOffset = 30
+			if h.PointFormat == 8 {
+				extra[NIR][i] = float64(le.Uint16(record[36:]))
+			}
+		}
+
+		if hasRGB(h.PointFormat) {
+			// Colors are stored as 16 bit values.
+			p.R = uint8(le.Uint16(record[rgbOffset:]) >> 8)
+			p.G = uint8(le.Uint16(record[rgbOffset+2:]) >> 8)
+			p.B = uint8(le.Uint16(record[rgbOffset+4:]) >> 8)
+		}
+
+		points = append(points, p)
+	}
+
+	return &Data{Header: h, Points: &points, Extra: extra}, nil
+}
+
+const maxPrealloc = 1 << 16
+
+// scanAngleUnit is the resolution in degrees of the scan angle of formats 6-8.
+const scanAngleUnit = 0.006
@@ -0,0 +1,184 @@
+package las
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Encode writes points to w as a LAS stream.
+func Encode(w io.Writer, points *point.Points3D, opts *WriteOptions) error {
+	if opts == nil {
+		opts = DefaultWriteOptions
+	}
+
+	for name, values := range opts.Extra {
+		if len(values) != points.Len() {
+			return fmt.Errorf("extra channel %q has %d values for %d points", name, len(values), points.Len())
+		}
+	}
+
+	h, err := prepareHeader(points, opts)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if err := h.write(bw); err != nil {
+		return err
+	}
+
+	extra := func(name string, i int) float64 {
+		if values, ok := opts.Extra[name]; ok {
+			return values[i]
+		}
+		return 0
+	}
+
+	le := binary.LittleEndian
+	record := make([]byte, h.PointRecordLength)
+
+	for i, p := range points.Raw() {
+		clear(record)
+
+		for j, v := range [3]float64{p.X, p.Y, p.Z} {
+			stored := math.Round((v - h.Offset[j]) / h.Scale[j])
+			if stored < math.MinInt32 || stored > math.MaxInt32 {
+				return fmt.Errorf("point %d does not fit the scale and offset of the header", i)
+			}
+			le.PutUint32(record[4*j:], uint32(int32(stored)))
+		}
+		le.PutUint16(record[12:], uint16(math.Max(0, math.Min(math.MaxUint16, math.Round(p.Intensity)))))
+
+		var rgbOffset int
+
+		if h.PointFormat < 6 {
+			record[14] = byte(extra(ReturnNumber, i))&0x07 |
+				(byte(extra(NumberOfReturns, i))&0x07)<<3 |
+				(byte(extra(ScanDirectionFlag, i))&0x01)<<6 |
+				byte(extra(EdgeOfFlightLine, i))<<7
+			record[15] = byte(p.Label)&0x1f | byte(extra(ClassificationFlags, i))<<5
+			record[16] = byte(int8(extra(ScanAngle, i)))
+			record[17] = byte(extra(UserData, i))
+			le.PutUint16(record[18:], uint16(extra(PointSourceID, i)))
+
+			rgbOffset = 20
+			if hasGPSTime(h.PointFormat) {
+				le.PutUint64(record[20:], math.Float64bits(p.Time))
+				rgbOffset = 28
+			}
+		} else {
+			record[14] = byte(extra(ReturnNumber, i))&0x0f | byte(extra(NumberOfReturns, i))<<4
+			record[15] = byte(extra(ClassificationFlags, i))&0x0f |
+				(byte(extra(ScannerChannel, i))&0x03)<<4 |
+				(byte(extra(ScanDirectionFlag, i))&0x01)<<6 |
+				byte(extra(EdgeOfFlightLine, i))<<7
+			record[16] = byte(p.Label)
+			record[17] = byte(extra(UserData, i))
+			le.PutUint16(record[18:], uint16(int16(math.Round(extra(ScanAngle, i)/scanAngleUnit))))
+			le.PutUint16(record[20:], uint16(extra(PointSourceID, i)))
+			le.PutUint64(record[22:], math.Float64bits(p.Time))
+
+			rgbOffset = 30
+			if h.PointFormat == 8 {
+				le.PutUint16(record[36:], uint16(extra(NIR, i)))
+			}
+		}
+
+		if hasRGB(h.PointFormat) {
+			// Scale 8 bit colors to the full 16 bit range.
+			le.PutUint16(record[rgbOffset:], uint16(p.R)*257)
+			le.PutUint16(record[rgbOffset+2:], uint16(p.G)*257)
+			le.PutUint16(record[rgbOffset+4:], uint16(p.B)*257)
+		}
+
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// prepareHeader returns the header to write, based on the template in opts if there is one.
+func prepareHeader(points *point.Points3D, opts *WriteOptions) (*Header, error) {
+	h := &Header{
+		VersionMajor:       1,
+		VersionMinor:       2,
+		GeneratingSoftware: "icp-go",
+		Scale:              [3]float64{0.001, 0.001, 0.001},
+	}
+
+	if opts.Header != nil {
+		template := *opts.Header
+		h = &template
+	} else {
+		attributes := points.Attributes()
+
+		switch {
+		case attributes.Time && attributes.Color:
+			h.PointFormat = 3
+		case attributes.Color:
+			h.PointFormat = 2
+		case attributes.Time:
+			h.PointFormat = 1
+		}
+	}
+
+	length, err := pointFormatLength(h.PointFormat)
+	if err != nil {
+		return nil, err
+	}
+	h.PointRecordLength = uint16(length)
+
+	// Formats 6-8 were introduced in LAS 1.4.
+	h.VersionMajor = 1
+	if h.PointFormat >= 6 && h.VersionMinor < 4 {
+		h.VersionMinor = 4
+	}
+	if h.VersionMinor < 2 || h.VersionMinor > 4 {
+		return nil, fmt.Errorf("unsupported LAS version 1.%d", h.VersionMinor)
+	}
+	for i := range h.Scale {
+		if h.Scale[i] <= 0 {
+			return nil, fmt.Errorf("invalid scale %v", h.Scale)
+		}
+	}
+
+	h.NumPoints = uint64(points.Len())
+	h.NumPointsByReturn = [15]uint64{}
+	if returns, ok := opts.Extra[ReturnNumber]; ok {
+		for _, r := range returns {
+			if r >= 1 && r <= 15 {
+				h.NumPointsByReturn[int(r)-1]++
+			}
+		}
+	}
+
+	h.Min = [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	h.Max = [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range points.Raw() {
+		for j, v := range [3]float64{p.X, p.Y, p.Z} {
+			h.Min[j] = math.Min(h.Min[j], v)
+			h.Max[j] = math.Max(h.Max[j], v)
+		}
+	}
+	if points.Len() == 0 {
+		h.Min, h.Max = [3]float64{}, [3]float64{}
+	}
+
+	// Without a template, store coordinates relative to the minimum so that large georeferenced
+	// coordinates still fit in 32 bits.
+	if opts.Header == nil {
+		for j := range h.Offset {
+			h.Offset[j] = math.Floor(h.Min[j])
+		}
+	}
+
+	return h, nil
+}
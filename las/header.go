@@ -0,0 +1,296 @@
+package las
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	headerSize12 = 227
+	headerSize13 = 235
+	headerSize14 = 375
+
+	vlrHeaderSize = 54
+)
+
+// Header is the public header block of a LAS file together with its variable length records.
+type Header struct {
+	FileSourceID   uint16
+	GlobalEncoding uint16
+	ProjectID      [16]byte
+
+	VersionMajor uint8
+	VersionMinor uint8
+
+	SystemIdentifier   string
+	GeneratingSoftware string
+	CreationDay        uint16
+	CreationYear       uint16
+
+	// Point data record format: 0-3 or 6-8.
+	PointFormat uint8
+
+	// Size of a point record in bytes. Records longer than the format requires carry extra bytes which are
+	// skipped when reading and not written.
+	PointRecordLength uint16
+
+	NumPoints         uint64
+	NumPointsByReturn [15]uint64
+
+	// Stored integer coordinates are multiplied by Scale and added to Offset.
+	Scale  [3]float64
+	Offset [3]float64
+
+	Min [3]float64
+	Max [3]float64
+
+	VLRs []*VLR
+}
+
+// VLR is a variable length record, e.g. the projection of the file.
+type VLR struct {
+	UserID      string
+	RecordID    uint16
+	Description string
+	Data        []byte
+}
+
+// headerSize returns the size of the public header block for the version.
+func (h *Header) headerSize() int {
+	switch {
+	case h.VersionMinor >= 4:
+		return headerSize14
+	case h.VersionMinor == 3:
+		return headerSize13
+	default:
+		return headerSize12
+	}
+}
+
+// pointFormatLength returns the minimum record length of a point data format.
+func pointFormatLength(format uint8) (int, error) {
+	switch format {
+	case 0:
+		return 20, nil
+	case 1:
+		return 28, nil
+	case 2:
+		return 26, nil
+	case 3:
+		return 34, nil
+	case 6:
+		return 30, nil
+	case 7:
+		return 36, nil
+	case 8:
+		return 38, nil
+	default:
+		return 0, fmt.Errorf("unsupported point data format %d", format)
+	}
+}
+
+func hasGPSTime(format uint8) bool {
+	return format == 1 || format == 3 || format >= 6
+}
+
+func hasRGB(format uint8) bool {
+	return format == 2 || format == 3 || format == 7 || format == 8
+}
+
+// readHeader reads the public header block and the variable length records, leaving r positioned at the
+// start of the point data.
+func readHeader(r io.Reader) (*Header, error) {
+	fixed := make([]byte, headerSize12)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if string(fixed[0:4]) != "LASF" {
+		return nil, errors.New("incorrect header; missing LASF signature")
+	}
+
+	le := binary.LittleEndian
+
+	h := &Header{
+		FileSourceID:       le.Uint16(fixed[4:]),
+		GlobalEncoding:     le.Uint16(fixed[6:]),
+		VersionMajor:       fixed[24],
+		VersionMinor:       fixed[25],
+		SystemIdentifier:   cString(fixed[26:58]),
+		GeneratingSoftware: cString(fixed[58:90]),
+		CreationDay:        le.Uint16(fixed[90:]),
+		CreationYear:       le.Uint16(fixed[92:]),
+		PointFormat:        fixed[104] & 0x3f, // The upper bits flag LAZ compression.
+		PointRecordLength:  le.Uint16(fixed[105:]),
+		NumPoints:          uint64(le.Uint32(fixed[107:])),
+	}
+	copy(h.ProjectID[:], fixed[8:24])
+
+	if h.VersionMajor != 1 || h.VersionMinor < 2 || h.VersionMinor > 4 {
+		return nil, fmt.Errorf("unsupported LAS version %d.%d", h.VersionMajor, h.VersionMinor)
+	}
+	if fixed[104]&0xc0 != 0 {
+		return nil, errors.New("compressed LAZ files are not supported")
+	}
+
+	minLength, err := pointFormatLength(h.PointFormat)
+	if err != nil {
+		return nil, err
+	}
+	if int(h.PointRecordLength) < minLength {
+		return nil, fmt.Errorf("point record length %d is too short for format %d", h.PointRecordLength, h.PointFormat)
+	}
+
+	headerSize := int(le.Uint16(fixed[94:]))
+	pointDataOffset := int(le.Uint32(fixed[96:]))
+	numVLRs := int(le.Uint32(fixed[100:]))
+
+	for i := 0; i < 5; i++ {
+		h.NumPointsByReturn[i] = uint64(le.Uint32(fixed[111+4*i:]))
+	}
+	for i := 0; i < 3; i++ {
+		h.Scale[i] = math.Float64frombits(le.Uint64(fixed[131+8*i:]))
+		h.Offset[i] = math.Float64frombits(le.Uint64(fixed[155+8*i:]))
+		h.Max[i] = math.Float64frombits(le.Uint64(fixed[179+16*i:]))
+		h.Min[i] = math.Float64frombits(le.Uint64(fixed[187+16*i:]))
+	}
+
+	if headerSize < headerSize12 || pointDataOffset < headerSize {
+		return nil, fmt.Errorf("invalid header size %d or point data offset %d", headerSize, pointDataOffset)
+	}
+
+	rest := make([]byte, headerSize-headerSize12)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	// LAS 1.4 files carry 64 bit point counts which replace the legacy fields.
+	if h.VersionMinor >= 4 && len(rest) >= headerSize14-headerSize12 {
+		h.NumPoints = le.Uint64(rest[247-headerSize12:])
+		for i := 0; i < 15; i++ {
+			h.NumPointsByReturn[i] = le.Uint64(rest[255-headerSize12+8*i:])
+		}
+	}
+
+	// The offset is not trusted to size a buffer: records are read one at a time, each at most 64 KiB, and
+	// whatever is left before the point data is skipped.
+	remaining := pointDataOffset - headerSize
+	vlrHeader := make([]byte, vlrHeaderSize)
+	for i := 0; i < numVLRs; i++ {
+		if remaining < vlrHeaderSize {
+			return nil, fmt.Errorf("variable length record %d is truncated", i)
+		}
+		if _, err := io.ReadFull(r, vlrHeader); err != nil {
+			return nil, fmt.Errorf("failed to read variable length record %d: %w", i, err)
+		}
+		length := int(le.Uint16(vlrHeader[20:]))
+		remaining -= vlrHeaderSize
+		if remaining < length {
+			return nil, fmt.Errorf("variable length record %d is truncated", i)
+		}
+
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, fmt.Errorf("failed to read variable length record %d: %w", i, err)
+		}
+		remaining -= length
+
+		h.VLRs = append(h.VLRs, &VLR{
+			UserID:      cString(vlrHeader[2:18]),
+			RecordID:    le.Uint16(vlrHeader[18:]),
+			Description: cString(vlrHeader[22:54]),
+			Data:        data,
+		})
+	}
+
+	if _, err := io.CopyN(io.Discard, r, int64(remaining)); err != nil {
+		return nil, fmt.Errorf("failed to read variable length records: %w", err)
+	}
+
+	return h, nil
+}
+
+// write writes the public header block and the variable length records.
+func (h *Header) write(w io.Writer) error {
+	size := h.headerSize()
+	buf := make([]byte, size)
+	le := binary.LittleEndian
+
+	pointDataOffset := size
+	for _, v := range h.VLRs {
+		if len(v.Data) > math.MaxUint16 {
+			return fmt.Errorf("variable length record %q/%d is too large", v.UserID, v.RecordID)
+		}
+		pointDataOffset += vlrHeaderSize + len(v.Data)
+	}
+
+	copy(buf[0:], "LASF")
+	le.PutUint16(buf[4:], h.FileSourceID)
+	le.PutUint16(buf[6:], h.GlobalEncoding)
+	copy(buf[8:24], h.ProjectID[:])
+	buf[24] = h.VersionMajor
+	buf[25] = h.VersionMinor
+	copy(buf[26:58], h.SystemIdentifier)
+	copy(buf[58:90], h.GeneratingSoftware)
+	le.PutUint16(buf[90:], h.CreationDay)
+	le.PutUint16(buf[92:], h.CreationYear)
+	le.PutUint16(buf[94:], uint16(size))
+	le.PutUint32(buf[96:], uint32(pointDataOffset))
+	le.PutUint32(buf[100:], uint32(len(h.VLRs)))
+	buf[104] = h.PointFormat
+	le.PutUint16(buf[105:], h.PointRecordLength)
+
+	// Legacy counts are zero when they cannot represent the file, as required by LAS 1.4.
+	if h.NumPoints <= math.MaxUint32 && h.PointFormat < 6 {
+		le.PutUint32(buf[107:], uint32(h.NumPoints))
+		for i := 0; i < 5; i++ {
+			le.PutUint32(buf[111+4*i:], uint32(h.NumPointsByReturn[i]))
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		le.PutUint64(buf[131+8*i:], math.Float64bits(h.Scale[i]))
+		le.PutUint64(buf[155+8*i:], math.Float64bits(h.Offset[i]))
+		le.PutUint64(buf[179+16*i:], math.Float64bits(h.Max[i]))
+		le.PutUint64(buf[187+16*i:], math.Float64bits(h.Min[i]))
+	}
+
+	if size >= headerSize14 {
+		le.PutUint64(buf[247:], h.NumPoints)
+		for i := 0; i < 15; i++ {
+			le.PutUint64(buf[255+8*i:], h.NumPointsByReturn[i])
+		}
+	}
+
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	for _, v := range h.VLRs {
+		vlr := make([]byte, vlrHeaderSize)
+		copy(vlr[2:18], v.UserID)
+		le.PutUint16(vlr[18:], v.RecordID)
+		le.PutUint16(vlr[20:], uint16(len(v.Data)))
+		copy(vlr[22:54], v.Description)
+
+		if _, err := w.Write(vlr); err != nil {
+			return err
+		}
+		if _, err := w.Write(v.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cString returns the contents of a null padded string field.
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
@@ -0,0 +1,80 @@
+package las
+
+import (
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Names of the Extra channels holding point record attributes that have no point.Point3D field.
+// GPS time and classification are held by point.Point3D Time and Label.
+const (
+	ClassificationFlags = "classification_flags"
+	ReturnNumber        = "return_number"
+	NumberOfReturns     = "number_of_returns"
+	ScanDirectionFlag   = "scan_direction_flag"
+	EdgeOfFlightLine    = "edge_of_flight_line"
+	ScanAngle           = "scan_angle" // Degrees.
+	UserData            = "user_data"
+	PointSourceID       = "point_source_id"
+	ScannerChannel      = "scanner_channel" // Formats 6-8 only.
+	NIR                 = "nir"             // Format 8 only.
+)
+
+type ReadOptions struct {
+	// Cropping applied to the points once read. The zero value keeps every point.
+	point.CropOptions
+}
+
+// DefaultReadOptions returns every point in the file unmodified.
+var DefaultReadOptions *ReadOptions = &ReadOptions{}
+
+type WriteOptions struct {
+	// Header to base the written file on, e.g. Data.Header of a file read earlier. Version, point format,
+	// scale, offset and VLRs are kept; point counts and bounds are recomputed. Nil writes a LAS 1.2 file
+	// using format 0-3 depending on whether any point has a color or time, with millimetre scale.
+	Header *Header `json:"-"`
+
+	// Point record attributes keyed by the channel names above. Each slice must hold one value per point;
+	// missing channels are written as zero.
+	Extra map[string][]float64 `json:"-"`
+}
+
+var DefaultWriteOptions *WriteOptions = &WriteOptions{}
+
+// Read reads the points of a LAS file and crops them according to opts.
+func Read(filePath string, opts *ReadOptions) (*point.Points3D, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file, opts)
+}
+
+// ReadAll reads the header and every point record of a LAS file.
+func ReadAll(filePath string) (*Data, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeAll(file)
+}
+
+// Write writes points to a LAS file, replacing any existing file.
+func Write(filePath string, points *point.Points3D, opts *WriteOptions) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := Encode(file, points, opts); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,281 @@
+package las
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// surveyPoints returns georeferenced points on the millimetre grid with integer intensities and labels that
+// fit the 5 bit classification of formats 0-3.
+func surveyPoints(color, time bool) *point.Points3D {
+	points := point.Points3D{
+		{X: 500000.123, Y: 5400000.456, Z: 30.5, Intensity: 1000, Label: 2},
+		{X: 500010.5, Y: 5400001, Z: 31.25, Intensity: 0, Label: 6},
+		{X: 499999.999, Y: 5399999.001, Z: -2, Intensity: 65535, Label: 31},
+	}
+	for i, p := range points {
+		if color {
+			p.R, p.G, p.B = uint8(50*i+1), 128, 255
+		}
+		if time {
+			p.Time = 3.5e8 + 0.000125*float64(i)
+		}
+	}
+	return &points
+}
+
+// legacyExtra holds a value for every channel of formats 0-3, within the range of its bits.
+var legacyExtra = map[string][]float64{
+	ClassificationFlags: {0, 5, 7},
+	ReturnNumber:        {1, 2, 7},
+	NumberOfReturns:     {1, 3, 7},
+	ScanDirectionFlag:   {0, 1, 0},
+	EdgeOfFlightLine:    {1, 0, 1},
+	ScanAngle:           {-90, 0, 127},
+	UserData:            {0, 200, 255},
+	PointSourceID:       {1, 65535, 42},
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	extended := map[string][]float64{
+		ClassificationFlags: {0, 5, 15},
+		ReturnNumber:        {1, 9, 15},
+		NumberOfReturns:     {1, 12, 15},
+		ScanDirectionFlag:   {0, 1, 0},
+		EdgeOfFlightLine:    {1, 0, 1},
+		ScanAngle:           {-180, 0.006, 90},
+		UserData:            {0, 200, 255},
+		PointSourceID:       {1, 65535, 42},
+		ScannerChannel:      {0, 3, 2},
+	}
+	withNIR := map[string][]float64{NIR: {0, 1000, 65535}}
+	for name, values := range extended {
+		withNIR[name] = values
+	}
+
+	vlrs := []*VLR{
+		{UserID: "LASF_Projection", RecordID: 2112, Description: "OGC WKT", Data: []byte(`PROJCS["WGS 84 / UTM zone 55S"]`)},
+		{UserID: "icp-go", RecordID: 1, Data: []byte{}},
+	}
+
+	tests := []struct {
+		name        string
+		color, time bool
+		header      *Header
+		extra       map[string][]float64
+		format      uint8
+		version     uint8
+	}{
+		{name: "format 0", extra: legacyExtra, format: 0, version: 2},
+		{name: "format 1", time: true, extra: legacyExtra, format: 1, version: 2},
+		{name: "format 2", color: true, extra: legacyExtra, format: 2, version: 2},
+		{name: "format 3", color: true, time: true, extra: legacyExtra, format: 3, version: 2},
+		{
+			name:    "format 3 in LAS 1.3 with VLRs",
+			color:   true,
+			time:    true,
+			header:  &Header{VersionMinor: 3, PointFormat: 3, Scale: [3]float64{0.001, 0.001, 0.001}, Offset: [3]float64{500000, 5400000, 0}, VLRs: vlrs},
+			extra:   legacyExtra,
+			format:  3,
+			version: 3,
+		},
+		{
+			name:    "format 6",
+			time:    true,
+			header:  &Header{VersionMinor: 2, PointFormat: 6, Scale: [3]float64{0.001, 0.001, 0.001}, Offset: [3]float64{500000, 5400000, 0}},
+			extra:   extended,
+			format:  6,
+			version: 4,
+		},
+		{
+			name:    "format 7",
+			color:   true,
+			time:    true,
+			header:  &Header{VersionMinor: 4, PointFormat: 7, Scale: [3]float64{0.001, 0.001, 0.001}, Offset: [3]float64{500000, 5400000, 0}, VLRs: vlrs},
+			extra:   extended,
+			format:  7,
+			version: 4,
+		},
+		{
+			name:    "format 8",
+			color:   true,
+			time:    true,
+			header:  &Header{VersionMinor: 4, PointFormat: 8, Scale: [3]float64{0.001, 0.001, 0.001}, Offset: [3]float64{500000, 5400000, 0}},
+			extra:   withNIR,
+			format:  8,
+			version: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := surveyPoints(tt.color, tt.time)
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, points, &WriteOptions{Header: tt.header, Extra: tt.extra}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			data, err := DecodeAll(&buf)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			h := data.Header
+			if h.PointFormat != tt.format || h.VersionMinor != tt.version {
+				t.Errorf("got format %d in LAS 1.%d, want format %d in LAS 1.%d", h.PointFormat, h.VersionMinor, tt.format, tt.version)
+			}
+			if h.NumPoints != uint64(points.Len()) {
+				t.Errorf("got %d points in the header, want %d", h.NumPoints, points.Len())
+			}
+			var byReturn [15]uint64
+			for _, r := range tt.extra[ReturnNumber] {
+				// Files before LAS 1.4 only count the first five returns.
+				if tt.version == 4 || r <= 5 {
+					byReturn[int(r)-1]++
+				}
+			}
+			if h.NumPointsByReturn != byReturn {
+				t.Errorf("got %v points by return, want %v", h.NumPointsByReturn, byReturn)
+			}
+			if want := [3]float64{499999.999, 5399999.001, -2}; h.Min != want {
+				t.Errorf("got min %v, want %v", h.Min, want)
+			}
+			if want := [3]float64{500010.5, 5400001, 31.25}; h.Max != want {
+				t.Errorf("got max %v, want %v", h.Max, want)
+			}
+			if tt.header != nil && !reflect.DeepEqual(h.VLRs, tt.header.VLRs) {
+				t.Errorf("got VLRs %v, want %v", h.VLRs, tt.header.VLRs)
+			}
+
+			if data.Points.Len() != points.Len() {
+				t.Fatalf("got %d points, want %d", data.Points.Len(), points.Len())
+			}
+			for i, p := range data.Points.Raw() {
+				want := *points.Raw()[i]
+				got := *p
+				if math.Abs(got.X-want.X) > 1e-6 || math.Abs(got.Y-want.Y) > 1e-6 || math.Abs(got.Z-want.Z) > 1e-6 {
+					t.Errorf("point %d: got position (%v, %v, %v), want (%v, %v, %v)", i, got.X, got.Y, got.Z, want.X, want.Y, want.Z)
+				}
+				got.X, got.Y, got.Z = want.X, want.Y, want.Z
+				if got != want {
+					t.Errorf("point %d: got %+v, want %+v", i, got, want)
+				}
+			}
+
+			for name, want := range tt.extra {
+				got := data.Extra[name]
+				if len(got) != len(want) {
+					t.Errorf("%s: got %v, want %v", name, got, want)
+					continue
+				}
+				for i := range want {
+					if math.Abs(got[i]-want[i]) > 1e-9 {
+						t.Errorf("%s: got %v, want %v", name, got, want)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestQuantization(t *testing.T) {
+	// Positions between the steps of the scales below.
+	points := point.Points3D{
+		{X: 500000.1234, Y: 5400000.0004, Z: -2.0049},
+		{X: 500000.1236, Y: 5400000.0016, Z: 31.2505},
+		{X: 500123.9999, Y: 5400000.5, Z: 0.00049},
+	}
+
+	tests := []struct {
+		name   string
+		header *Header
+		offset [3]float64
+		want   [][3]float64
+	}{
+		{
+			// Without a template, millimetres are stored relative to the floor of the minimum.
+			name:   "default",
+			offset: [3]float64{500000, 5400000, -3},
+			want: [][3]float64{
+				{500000.123, 5400000, -2.005},
+				{500000.124, 5400000.002, 31.251},
+				{500124, 5400000.5, 0},
+			},
+		},
+		{
+			name:   "centimetres",
+			header: &Header{VersionMinor: 2, Scale: [3]float64{0.01, 0.01, 0.01}, Offset: [3]float64{500000, 5400000, 0}},
+			offset: [3]float64{500000, 5400000, 0},
+			want: [][3]float64{
+				{500000.12, 5400000, -2},
+				{500000.12, 5400000, 31.25},
+				{500124, 5400000.5, 0},
+			},
+		},
+		{
+			// Steps are counted from the offset, so an offset off the grid shifts every stored position.
+			name:   "offset between steps",
+			header: &Header{VersionMinor: 2, Scale: [3]float64{0.5, 0.5, 0.5}, Offset: [3]float64{500000.1, 5400000.1, 0.1}},
+			offset: [3]float64{500000.1, 5400000.1, 0.1},
+			want: [][3]float64{
+				{500000.1, 5400000.1, -1.9},
+				{500000.1, 5400000.1, 31.1},
+				{500124.1, 5400000.6, 0.1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, &points, &WriteOptions{Header: tt.header}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			data, err := DecodeAll(&buf)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+			if data.Header.Offset != tt.offset {
+				t.Errorf("got offset %v, want %v", data.Header.Offset, tt.offset)
+			}
+
+			for i, p := range data.Points.Raw() {
+				got := [3]float64{p.X, p.Y, p.Z}
+				for j := range got {
+					if math.Abs(got[j]-tt.want[i][j]) > 1e-9 {
+						t.Errorf("point %d: got %v, want %v", i, got, tt.want[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *WriteOptions
+	}{
+		{name: "extra channel of the wrong length", opts: &WriteOptions{Extra: map[string][]float64{UserData: {1}}}},
+		{name: "unsupported point format", opts: &WriteOptions{Header: &Header{VersionMinor: 2, PointFormat: 4, Scale: [3]float64{1, 1, 1}}}},
+		{name: "zero scale", opts: &WriteOptions{Header: &Header{VersionMinor: 2}}},
+		{name: "coordinates beyond the scale and offset", opts: &WriteOptions{Header: &Header{VersionMinor: 2, Scale: [3]float64{1e-6, 1e-6, 1e-6}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, surveyPoints(false, false), tt.opts); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
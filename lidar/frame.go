@@ -0,0 +1,150 @@
+package lidar
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Frame is the set of returns from one full rotation of a spinning lidar. Each point holds its acquisition
+// time in seconds since the Unix epoch and its ring, 0 being the lowest beam.
+type Frame struct {
+	Points *point.Points3D
+}
+
+func (f *Frame) add(p *point.Point3D, timestamp float64, ring int) {
+	p.Time, p.Ring = timestamp, ring
+	*f.Points = append(*f.Points, p)
+}
+
+func newFrame(capacity int) *Frame {
+	points := make(point.Points3D, 0, capacity)
+	return &Frame{Points: &points}
+}
+
+// frameQueue holds the frames a decoder has completed but not yet returned. A packet normally completes at
+// most one frame, but a sparse or corrupt stream may complete several.
+type frameQueue []*Frame
+
+func (q *frameQueue) push(f *Frame) {
+	*q = append(*q, f)
+}
+
+// pop returns the oldest frame in the queue, or nil if it is empty.
+func (q *frameQueue) pop() *Frame {
+	if len(*q) == 0 {
+		return nil
+	}
+	f := (*q)[0]
+	*q = (*q)[1:]
+	return f
+}
+
+// Decoder turns sensor packets into frames.
+type Decoder interface {
+	// Decode adds the returns of a packet to the current frame. It returns the previous frame once the
+	// packet starts a new rotation, and nil otherwise. Frames completed while an earlier one is still to be
+	// returned are returned by later calls.
+	Decode(pkt *Packet) (*Frame, error)
+
+	// Flush returns the completed frames not yet returned, one per call, then the partially accumulated
+	// frame, and nil once none remain.
+	Flush() *Frame
+}
+
+// FrameScanner streams the frames of a pcap capture.
+type FrameScanner struct {
+	reader  *PcapReader
+	decoder Decoder
+	port    uint16
+	frame   *Frame
+	err     error
+	done    bool
+}
+
+// NewFrameScanner decodes the packets sent to port, e.g. 2368 for Velodyne or 7502 for Ouster lidar data.
+// A port of zero decodes every UDP packet.
+func NewFrameScanner(r io.Reader, decoder Decoder, port uint16) (*FrameScanner, error) {
+	reader, err := NewPcapReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &FrameScanner{reader: reader, decoder: decoder, port: port}, nil
+}
+
+// Scan advances to the next complete frame. The partial frame at the end of the capture is returned last.
+func (s *FrameScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.done {
+		s.frame = s.decoder.Flush()
+		return s.frame != nil
+	}
+
+	for {
+		pkt, err := s.reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+				return false
+			}
+			s.done = true
+			s.frame = s.decoder.Flush()
+			return s.frame != nil
+		}
+
+		if s.port != 0 && pkt.DstPort != s.port {
+			continue
+		}
+
+		frame, err := s.decoder.Decode(pkt)
+		if err != nil {
+			s.err = err
+			return false
+		}
+		if frame != nil {
+			s.frame = frame
+			return true
+		}
+	}
+}
+
+// Frame returns the frame read by the last call to Scan.
+func (s *FrameScanner) Frame() *Frame {
+	return s.frame
+}
+
+// Err returns the first error encountered while scanning.
+func (s *FrameScanner) Err() error {
+	return s.err
+}
+
+// ReadFrames decodes every frame of a pcap file.
+func ReadFrames(filePath string, decoder Decoder, port uint16) ([]*Frame, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner, err := NewFrameScanner(file, decoder, port)
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]*Frame, 0)
+	for scanner.Scan() {
+		frames = append(frames, scanner.Frame())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("no lidar packets found")
+	}
+
+	return frames, nil
+}
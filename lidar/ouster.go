@@ -0,0 +1,185 @@
+package lidar
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+const (
+	ousterEncoderTicks   = 90112
+	ousterColumnHeader   = 16
+	ousterChannelSize    = 12
+	ousterColumnFooter   = 4
+	ousterValidColumn    = 0xffffffff
+	ousterDefaultColumns = 16
+)
+
+// OusterCalibration describes the beams of an Ouster sensor, as found in the sensor metadata.
+type OusterCalibration struct {
+	// Elevation and azimuth offset of each beam in degrees, indexed by channel.
+	BeamAltitudeAngles []float64 `json:"beam_altitude_angles"`
+	BeamAzimuthAngles  []float64 `json:"beam_azimuth_angles"`
+
+	// Distance from the lidar origin to the beam origins in millimetres.
+	LidarOriginToBeamOriginMM float64 `json:"lidar_origin_to_beam_origin_mm"`
+
+	// Number of measurement columns in a lidar packet. Zero uses the 16 columns of the legacy packet format.
+	ColumnsPerPacket int `json:"columns_per_packet"`
+}
+
+// ParseOusterMetadata reads the beam intrinsics from the JSON metadata written by the sensor or its SDK.
+func ParseOusterMetadata(data []byte) (*OusterCalibration, error) {
+	var metadata struct {
+		OusterCalibration
+		BeamIntrinsics *OusterCalibration `json:"beam_intrinsics"`
+		DataFormat     struct {
+			ColumnsPerPacket int `json:"columns_per_packet"`
+		} `json:"data_format"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	cal := &metadata.OusterCalibration
+	if metadata.BeamIntrinsics != nil {
+		cal = metadata.BeamIntrinsics
+	}
+	if metadata.DataFormat.ColumnsPerPacket > 0 {
+		cal.ColumnsPerPacket = metadata.DataFormat.ColumnsPerPacket
+	}
+
+	if len(cal.BeamAltitudeAngles) == 0 {
+		return nil, errors.New("metadata has no beam_altitude_angles")
+	}
+
+	return cal, nil
+}
+
+// ReadOusterMetadata reads the beam intrinsics from a sensor metadata file.
+func ReadOusterMetadata(filePath string) (*OusterCalibration, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOusterMetadata(data)
+}
+
+// OusterDecoder decodes Ouster lidar packets in the legacy format. Points are expressed in the lidar
+// coordinate frame in metres.
+type OusterDecoder struct {
+	cal     *OusterCalibration
+	columns int
+	rings   []int
+
+	sinAltitude, cosAltitude []float64
+	azimuth                  []float64
+
+	current   *Frame
+	completed frameQueue
+	frameID   uint16
+	hasFrame  bool
+}
+
+// NewOusterDecoder returns a decoder for the sensor described by cal.
+func NewOusterDecoder(cal *OusterCalibration) (*OusterDecoder, error) {
+	n := len(cal.BeamAltitudeAngles)
+	if n == 0 || len(cal.BeamAzimuthAngles) != n {
+		return nil, fmt.Errorf("calibration has %d altitude and %d azimuth angles", n, len(cal.BeamAzimuthAngles))
+	}
+
+	d := &OusterDecoder{
+		cal:         cal,
+		columns:     cal.ColumnsPerPacket,
+		rings:       ringsByElevation(cal.BeamAltitudeAngles),
+		sinAltitude: make([]float64, n),
+		cosAltitude: make([]float64, n),
+		azimuth:     make([]float64, n),
+		current:     newFrame(0),
+	}
+	if d.columns == 0 {
+		d.columns = ousterDefaultColumns
+	}
+	for i := range cal.BeamAltitudeAngles {
+		d.sinAltitude[i], d.cosAltitude[i] = math.Sincos(cal.BeamAltitudeAngles[i] * math.Pi / 180)
+		d.azimuth[i] = -cal.BeamAzimuthAngles[i] * math.Pi / 180
+	}
+
+	return d, nil
+}
+
+// Decode adds the returns of a lidar packet to the current frame.
+func (d *OusterDecoder) Decode(pkt *Packet) (*Frame, error) {
+	channels := len(d.cal.BeamAltitudeAngles)
+	columnSize := ousterColumnHeader + channels*ousterChannelSize + ousterColumnFooter
+
+	data := pkt.Payload
+	if len(data) != d.columns*columnSize {
+		return nil, fmt.Errorf("ouster packet has %d bytes, expected %d", len(data), d.columns*columnSize)
+	}
+
+	le := binary.LittleEndian
+	beamOffset := d.cal.LidarOriginToBeamOriginMM
+
+	for c := 0; c < d.columns; c++ {
+		column := data[c*columnSize : (c+1)*columnSize]
+
+		if le.Uint32(column[columnSize-ousterColumnFooter:]) != ousterValidColumn {
+			continue
+		}
+
+		timestamp := float64(le.Uint64(column[0:])) * 1e-9
+		frameID := le.Uint16(column[10:])
+		encoder := float64(le.Uint32(column[12:]))
+
+		if d.hasFrame && frameID != d.frameID && d.current.Points.Len() > 0 {
+			d.completed.push(d.current)
+			d.current = newFrame(d.current.Points.Len())
+		}
+		d.frameID, d.hasFrame = frameID, true
+
+		encoderAngle := 2 * math.Pi * (1 - encoder/ousterEncoderTicks)
+		sinEncoder, cosEncoder := math.Sincos(encoderAngle)
+
+		for ch := 0; ch < channels; ch++ {
+			channel := column[ousterColumnHeader+ch*ousterChannelSize:]
+
+			r := float64(le.Uint32(channel) & 0xfffff)
+			if r == 0 {
+				continue // No return.
+			}
+
+			sinAzimuth, cosAzimuth := math.Sincos(encoderAngle + d.azimuth[ch])
+			n := beamOffset
+			p := &point.Point3D{
+				X:         ((r-n)*cosAzimuth*d.cosAltitude[ch] + n*cosEncoder) / 1000,
+				Y:         ((r-n)*sinAzimuth*d.cosAltitude[ch] + n*sinEncoder) / 1000,
+				Z:         (r - n) * d.sinAltitude[ch] / 1000,
+				Intensity: float64(le.Uint16(channel[6:])), // Signal photons.
+			}
+
+			d.current.add(p, timestamp, d.rings[ch])
+		}
+	}
+
+	return d.completed.pop(), nil
+}
+
+// Flush returns the completed frames not yet returned, then the partially accumulated frame.
+func (d *OusterDecoder) Flush() *Frame {
+	if frame := d.completed.pop(); frame != nil {
+		return frame
+	}
+	if d.current.Points.Len() == 0 {
+		return nil
+	}
+	frame := d.current
+	d.current = newFrame(0)
+	d.hasFrame = false
+	return frame
+}
@@ -0,0 +1,126 @@
+package lidar
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+// ousterTestCalibration has a level beam and one tilted up by 10 degrees.
+var ousterTestCalibration = &OusterCalibration{
+	BeamAltitudeAngles:        []float64{10, 0},
+	BeamAzimuthAngles:         []float64{0, 0},
+	LidarOriginToBeamOriginMM: 15.8,
+	ColumnsPerPacket:          2,
+}
+
+// ousterPacket returns a packet whose columns start at the given encoder count, with a return at rangeMM on
+// every beam.
+func ousterPacket(frameID uint16, encoder uint32, timestamp uint64, rangeMM uint32) *Packet {
+	channels := len(ousterTestCalibration.BeamAltitudeAngles)
+	columnSize := ousterColumnHeader + channels*ousterChannelSize + ousterColumnFooter
+	data := make([]byte, ousterTestCalibration.ColumnsPerPacket*columnSize)
+
+	for c := 0; c < ousterTestCalibration.ColumnsPerPacket; c++ {
+		column := data[c*columnSize:]
+		binary.LittleEndian.PutUint64(column, timestamp+uint64(c)*1000)
+		binary.LittleEndian.PutUint16(column[10:], frameID)
+		binary.LittleEndian.PutUint32(column[12:], encoder+uint32(c)*88)
+		for ch := 0; ch < channels; ch++ {
+			channel := column[ousterColumnHeader+ch*ousterChannelSize:]
+			binary.LittleEndian.PutUint32(channel, rangeMM)
+			binary.LittleEndian.PutUint16(channel[6:], uint16(100+ch))
+		}
+		binary.LittleEndian.PutUint32(column[columnSize-ousterColumnFooter:], ousterValidColumn)
+	}
+
+	return &Packet{Timestamp: time.Unix(0, 0), Payload: data}
+}
+
+func TestOusterDecode(t *testing.T) {
+	d, err := NewOusterDecoder(ousterTestCalibration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A quarter of a turn points the encoder to the right of the sensor.
+	packets := []*Packet{
+		ousterPacket(1, ousterEncoderTicks/4, 5e9, 5000),
+		ousterPacket(1, ousterEncoderTicks/4+176, 5e9+2000, 5000),
+		ousterPacket(2, 0, 6e9, 5000),
+	}
+	completed, flushed := decodeAll(t, d, packets)
+
+	if len(completed) != 1 || len(flushed) != 1 {
+		t.Fatalf("got %d completed and %d flushed frames, want 1 and 1", len(completed), len(flushed))
+	}
+	if completed[0].Points.Len() != 8 || flushed[0].Points.Len() != 4 {
+		t.Errorf("got %d and %d points, want 8 and 4", completed[0].Points.Len(), flushed[0].Points.Len())
+	}
+
+	const n = 15.8
+	tests := []struct {
+		name   string
+		frame  *Frame
+		index  int
+		want   [3]float64
+		time   float64
+		ring   int
+		signal float64
+	}{
+		{
+			name:   "tilted beam facing right",
+			frame:  completed[0],
+			index:  0,
+			want:   [3]float64{0, -((5000-n)*math.Cos(10*math.Pi/180) + n) / 1000, (5000 - n) * math.Sin(10*math.Pi/180) / 1000},
+			time:   5,
+			ring:   1,
+			signal: 100,
+		},
+		{
+			name:   "level beam facing forward",
+			frame:  flushed[0],
+			index:  1,
+			want:   [3]float64{5, 0, 0},
+			time:   6,
+			ring:   0,
+			signal: 101,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.frame.Points.Raw()[tt.index]
+			for i, got := range [3]float64{p.X, p.Y, p.Z} {
+				if math.Abs(got-tt.want[i]) > 1e-9 {
+					t.Errorf("got position %v, %v, %v, want %v", p.X, p.Y, p.Z, tt.want)
+					break
+				}
+			}
+			if math.Abs(p.Time-tt.time) > 1e-9 || p.Ring != tt.ring || p.Intensity != tt.signal {
+				t.Errorf("got time %v, ring %d and signal %v, want %v, %d and %v", p.Time, p.Ring, p.Intensity, tt.time, tt.ring, tt.signal)
+			}
+		})
+	}
+}
+
+func TestOusterSkipsInvalidColumns(t *testing.T) {
+	d, err := NewOusterDecoder(ousterTestCalibration)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkt := ousterPacket(1, 0, 0, 5000)
+	columnSize := len(pkt.Payload) / ousterTestCalibration.ColumnsPerPacket
+	binary.LittleEndian.PutUint32(pkt.Payload[columnSize-ousterColumnFooter:], 0)
+
+	_, flushed := decodeAll(t, d, []*Packet{pkt})
+	if len(flushed) != 1 || flushed[0].Points.Len() != 2 {
+		t.Fatalf("got %d frames, want one with the 2 points of the valid column", len(flushed))
+	}
+
+	if _, err := d.Decode(&Packet{Payload: pkt.Payload[1:]}); err == nil {
+		t.Error("expected an error for a packet of the wrong size")
+	}
+}
@@ -0,0 +1,250 @@
+package lidar
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Packet is the payload of a UDP datagram captured in a pcap file.
+type Packet struct {
+	Timestamp time.Time
+	SrcPort   uint16
+	DstPort   uint16
+	Payload   []byte
+}
+
+// Link layer types of the pcap global header.
+const (
+	linkNull      = 0
+	linkEthernet  = 1
+	linkRaw       = 101
+	linkLinuxSLL  = 113
+	linkLinuxSLL2 = 276
+)
+
+// maxSnapLen bounds the captured length of a packet when the global header does not give a usable snapshot
+// length. It is the default of tcpdump and well above the size of any UDP datagram.
+const maxSnapLen = 262144
+
+// maxPendingFragments bounds the number of partially reassembled datagrams kept in memory.
+const maxPendingFragments = 64
+
+// PcapReader reads UDP packets from a classic libpcap capture. IPv4 fragments are reassembled, which is
+// required for Ouster lidar packets that exceed the Ethernet MTU. pcapng captures are not supported.
+type PcapReader struct {
+	r        *bufio.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType uint32
+	snapLen  uint32
+
+	pending map[fragmentKey]*fragments
+	fifo    []fragmentKey // Keys of pending in arrival order, oldest first.
+}
+
+type fragmentKey struct {
+	src, dst [4]byte
+	id       uint16
+}
+
+type fragments struct {
+	data     []byte
+	received int
+	total    int // -1 until the last fragment arrives.
+}
+
+// NewPcapReader reads the global header of a pcap capture.
+func NewPcapReader(r io.Reader) (*PcapReader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+
+	var header [24]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read pcap header: %w", err)
+	}
+
+	p := &PcapReader{r: br, pending: make(map[fragmentKey]*fragments)}
+
+	switch binary.LittleEndian.Uint32(header[0:]) {
+	case 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		p.order, p.nanos = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		p.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		p.order, p.nanos = binary.BigEndian, true
+	case 0x0a0d0d0a:
+		return nil, errors.New("pcapng captures are not supported, convert with editcap -F pcap")
+	default:
+		return nil, errors.New("incorrect header; not a pcap capture")
+	}
+
+	p.snapLen = p.order.Uint32(header[16:])
+	if p.snapLen == 0 || p.snapLen > maxSnapLen {
+		p.snapLen = maxSnapLen
+	}
+	p.linkType = p.order.Uint32(header[20:]) & 0x0fffffff
+
+	switch p.linkType {
+	case linkNull, linkEthernet, linkRaw, linkLinuxSLL, linkLinuxSLL2:
+	default:
+		return nil, fmt.Errorf("unsupported pcap link type %d", p.linkType)
+	}
+
+	return p, nil
+}
+
+// Next returns the next UDP packet, skipping any other traffic. It returns io.EOF at the end of the capture.
+func (p *PcapReader) Next() (*Packet, error) {
+	for {
+		var record [16]byte
+		if _, err := io.ReadFull(p.r, record[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, errors.New("capture ends within a record header")
+			}
+			return nil, err
+		}
+
+		seconds := int64(p.order.Uint32(record[0:]))
+		fraction := int64(p.order.Uint32(record[4:]))
+		if !p.nanos {
+			fraction *= 1000
+		}
+
+		captured := p.order.Uint32(record[8:])
+		if captured > p.snapLen {
+			return nil, fmt.Errorf("captured packet length %d exceeds the snapshot length %d", captured, p.snapLen)
+		}
+
+		frame := make([]byte, captured)
+		if _, err := io.ReadFull(p.r, frame); err != nil {
+			return nil, errors.New("capture ends within a packet")
+		}
+
+		ip, ok := p.ipPayload(frame)
+		if !ok {
+			continue
+		}
+
+		datagram, ok := p.reassemble(ip)
+		if !ok || len(datagram) < 8 {
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(datagram[4:]))
+		if length < 8 || length > len(datagram) {
+			length = len(datagram)
+		}
+
+		return &Packet{
+			Timestamp: time.Unix(seconds, fraction),
+			SrcPort:   binary.BigEndian.Uint16(datagram[0:]),
+			DstPort:   binary.BigEndian.Uint16(datagram[2:]),
+			Payload:   datagram[8:length],
+		}, nil
+	}
+}
+
+// ipPayload strips the link layer header, returning the IPv4 packet of a frame.
+func (p *PcapReader) ipPayload(frame []byte) ([]byte, bool) {
+	var etherType uint16
+	var offset int
+
+	switch p.linkType {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, false
+		}
+		etherType, offset = binary.BigEndian.Uint16(frame[12:]), 14
+		for etherType == 0x8100 && len(frame) >= offset+4 { // VLAN tags.
+			etherType, offset = binary.BigEndian.Uint16(frame[offset+2:]), offset+4
+		}
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return nil, false
+		}
+		etherType, offset = binary.BigEndian.Uint16(frame[14:]), 16
+	case linkLinuxSLL2:
+		if len(frame) < 20 {
+			return nil, false
+		}
+		etherType, offset = binary.BigEndian.Uint16(frame[0:]), 20
+	case linkNull:
+		if len(frame) < 4 {
+			return nil, false
+		}
+		etherType, offset = 0x0800, 4
+	default:
+		etherType = 0x0800
+	}
+
+	if etherType != 0x0800 || len(frame) <= offset || frame[offset]>>4 != 4 {
+		return nil, false
+	}
+
+	return frame[offset:], true
+}
+
+// reassemble returns the UDP datagram of an IPv4 packet, buffering fragments until the datagram is complete.
+func (p *PcapReader) reassemble(ip []byte) ([]byte, bool) {
+	if len(ip) < 20 {
+		return nil, false
+	}
+
+	headerLength := int(ip[0]&0x0f) * 4
+	totalLength := int(binary.BigEndian.Uint16(ip[2:]))
+	if ip[9] != 17 || headerLength < 20 || totalLength < headerLength || totalLength > len(ip) {
+		return nil, false
+	}
+
+	flags := binary.BigEndian.Uint16(ip[6:])
+	moreFragments := flags&0x2000 != 0
+	offset := int(flags&0x1fff) * 8
+	payload := ip[headerLength:totalLength]
+
+	if !moreFragments && offset == 0 {
+		return payload, true
+	}
+
+	key := fragmentKey{id: binary.BigEndian.Uint16(ip[4:])}
+	copy(key.src[:], ip[12:16])
+	copy(key.dst[:], ip[16:20])
+
+	f, ok := p.pending[key]
+	if !ok {
+		if len(p.fifo) >= maxPendingFragments {
+			delete(p.pending, p.fifo[0])
+			p.fifo = p.fifo[1:]
+		}
+		f = &fragments{total: -1}
+		p.pending[key] = f
+		p.fifo = append(p.fifo, key)
+	}
+
+	if end := offset + len(payload); end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	copy(f.data[offset:], payload)
+	f.received += len(payload)
+	if !moreFragments {
+		f.total = offset + len(payload)
+	}
+
+	if f.total < 0 || f.received < f.total {
+		return nil, false
+	}
+
+	delete(p.pending, key)
+	for i, k := range p.fifo {
+		if k == key {
+			p.fifo = append(p.fifo[:i], p.fifo[i+1:]...)
+			break
+		}
+	}
+
+	return f.data[:f.total], true
+}
@@ -0,0 +1,138 @@
+package lidar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pcapCapture builds a little-endian microsecond pcap capture of Ethernet frames.
+type pcapCapture struct {
+	bytes.Buffer
+}
+
+func newPcapCapture(snapLen uint32) *pcapCapture {
+	c := &pcapCapture{}
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(header[4:], 2)
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], snapLen)
+	binary.LittleEndian.PutUint32(header[20:], linkEthernet)
+	c.Write(header)
+	return c
+}
+
+// record adds a frame captured at the given time.
+func (c *pcapCapture) record(seconds, micros uint32, frame []byte) {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[0:], seconds)
+	binary.LittleEndian.PutUint32(header[4:], micros)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(frame)))
+	c.Write(header)
+	c.Write(frame)
+}
+
+// ipFrame returns an Ethernet frame holding an IPv4 packet of the given protocol. offset is the fragment
+// offset in bytes.
+func ipFrame(protocol byte, id uint16, offset int, more bool, payload []byte) []byte {
+	frame := make([]byte, 14+20+len(payload))
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+
+	ip := frame[14:]
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(payload)))
+	binary.BigEndian.PutUint16(ip[4:], id)
+	flags := uint16(offset / 8)
+	if more {
+		flags |= 0x2000
+	}
+	binary.BigEndian.PutUint16(ip[6:], flags)
+	ip[9] = protocol
+	copy(ip[12:], []byte{192, 168, 1, 201})
+	copy(ip[16:], []byte{255, 255, 255, 255})
+	copy(ip[20:], payload)
+
+	return frame
+}
+
+// udpDatagram returns a UDP header followed by data.
+func udpDatagram(src, dst uint16, data []byte) []byte {
+	datagram := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint16(datagram[0:], src)
+	binary.BigEndian.PutUint16(datagram[2:], dst)
+	binary.BigEndian.PutUint16(datagram[4:], uint16(len(datagram)))
+	copy(datagram[8:], data)
+	return datagram
+}
+
+func TestPcapReader(t *testing.T) {
+	small := []byte("velodyne")
+	large := make([]byte, 3000)
+	for i := range large {
+		large[i] = byte(i * 7)
+	}
+	fragmented := udpDatagram(7502, 7502, large)
+
+	c := newPcapCapture(65535)
+	c.record(10, 500, ipFrame(6, 1, 0, false, make([]byte, 40))) // TCP, skipped.
+	c.record(11, 250, ipFrame(17, 2, 0, false, udpDatagram(2368, 2368, small)))
+
+	// Fragments arrive out of order, interleaved with the start of a datagram that never completes.
+	c.record(12, 0, ipFrame(17, 3, 1480, true, fragmented[1480:2960]))
+	c.record(12, 1, ipFrame(17, 4, 0, true, udpDatagram(7502, 7502, make([]byte, 2000))[:1480]))
+	c.record(12, 2, ipFrame(17, 3, 0, true, fragmented[:1480]))
+	c.record(12, 3, ipFrame(17, 3, 2960, false, fragmented[2960:]))
+
+	r, err := NewPcapReader(&c.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		port    uint16
+		time    time.Time
+		payload []byte
+	}{
+		{port: 2368, time: time.Unix(11, 250000), payload: small},
+		{port: 7502, time: time.Unix(12, 3000), payload: large},
+	}
+	for i, tt := range tests {
+		pkt, err := r.Next()
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if pkt.SrcPort != tt.port || pkt.DstPort != tt.port {
+			t.Errorf("packet %d: got ports %d and %d, want %d", i, pkt.SrcPort, pkt.DstPort, tt.port)
+		}
+		if !pkt.Timestamp.Equal(tt.time) {
+			t.Errorf("packet %d: got time %v, want %v", i, pkt.Timestamp, tt.time)
+		}
+		if !bytes.Equal(pkt.Payload, tt.payload) {
+			t.Errorf("packet %d: got a payload of %d bytes that differs from the %d sent", i, len(pkt.Payload), len(tt.payload))
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("got %v at the end of the capture, want io.EOF", err)
+	}
+}
+
+func TestPcapReaderSnapLength(t *testing.T) {
+	c := newPcapCapture(1500)
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint32(header[8:], 0xffffffff)
+	c.Write(header)
+
+	r, err := NewPcapReader(&c.Buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "snapshot length") {
+		t.Errorf("got error %v, want one for a packet longer than the snapshot length", err)
+	}
+}
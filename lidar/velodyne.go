@@ -0,0 +1,264 @@
+package lidar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+const (
+	velodynePacketSize     = 1206
+	velodyneBlocks         = 12
+	velodyneBlockSize      = 100
+	velodyneChannels       = 32
+	velodyneBlockFlag      = 0xeeff
+	velodyneDualReturnMode = 0x39
+)
+
+// VelodyneCalibration describes the lasers of a Velodyne sensor.
+type VelodyneCalibration struct {
+	// Elevation of each laser in degrees, indexed by laser ID.
+	VerticalAngles []float64
+
+	// Vertical offset of each laser from the sensor origin in metres.
+	VerticalOffsets []float64
+
+	// Azimuth correction of each laser in degrees. Optional.
+	RotationCorrections []float64
+
+	// Size of a distance unit in metres.
+	DistanceResolution float64
+
+	// Time in microseconds between consecutive firings and between consecutive firing sequences.
+	FiringInterval   float64
+	SequenceInterval float64
+
+	// Number of lasers fired together, in laser ID order. Zero or one fires the lasers one at a time.
+	LasersPerFiring int
+}
+
+// VLP16 is the calibration of the Velodyne VLP-16 (Puck).
+var VLP16 *VelodyneCalibration = &VelodyneCalibration{
+	VerticalAngles: []float64{-15, 1, -13, 3, -11, 5, -9, 7, -7, 9, -5, 11, -3, 13, -1, 15},
+	VerticalOffsets: []float64{
+		0.0112, -0.0007, 0.0097, -0.0022, 0.0081, -0.0037, 0.0066, -0.0051,
+		0.0051, -0.0066, 0.0037, -0.0081, 0.0022, -0.0097, 0.0007, -0.0112,
+	},
+	DistanceResolution: 0.002,
+	FiringInterval:     2.304,
+	SequenceInterval:   55.296,
+}
+
+// HDL32E is the calibration of the Velodyne HDL-32E.
+var HDL32E *VelodyneCalibration = &VelodyneCalibration{
+	VerticalAngles: []float64{
+		-30.67, -9.33, -29.33, -8.00, -28.00, -6.67, -26.67, -5.33,
+		-25.33, -4.00, -24.00, -2.67, -22.67, -1.33, -21.33, 0.00,
+		-20.00, 1.33, -18.67, 2.67, -17.33, 4.00, -16.00, 5.33,
+		-14.67, 6.67, -13.33, 8.00, -12.00, 9.33, -10.67, 10.67,
+	},
+	DistanceResolution: 0.002,
+	FiringInterval:     1.152,
+	SequenceInterval:   46.08,
+}
+
+// VLP32C is the calibration of the Velodyne VLP-32C (Ultra Puck), which fires its lasers in pairs.
+var VLP32C *VelodyneCalibration = &VelodyneCalibration{
+	VerticalAngles: []float64{
+		-25, -1, -1.667, -15.639, -11.31, 0, -0.667, -8.843,
+		-7.254, 0.333, -0.333, -6.148, -5.333, 1.333, 0.667, -4,
+		-4.667, 1.667, 1, -3.667, -3.333, 3.333, 2.333, -2.667,
+		-3, 7, 4.667, -2.333, -2, 15, 10.333, -1.333,
+	},
+	RotationCorrections: []float64{
+		1.4, -4.2, 1.4, -1.4, 1.4, -1.4, 4.2, -1.4,
+		1.4, -4.2, 1.4, -1.4, 4.2, -1.4, 4.2, -1.4,
+		1.4, -4.2, 1.4, -4.2, 4.2, -1.4, 1.4, -1.4,
+		1.4, -1.4, 1.4, -4.2, 4.2, -1.4, 1.4, -1.4,
+	},
+	DistanceResolution: 0.004,
+	FiringInterval:     2.304,
+	SequenceInterval:   55.296,
+	LasersPerFiring:    2,
+}
+
+// VelodyneDecoder decodes Velodyne data packets. Points are expressed with x forward, y left and z up.
+type VelodyneDecoder struct {
+	cal   *VelodyneCalibration
+	rings []int
+
+	sinVertical, cosVertical []float64
+
+	current     *Frame
+	completed   frameQueue
+	lastAzimuth float64
+	hasAzimuth  bool
+}
+
+// NewVelodyneDecoder returns a decoder for a sensor with 16 or 32 lasers.
+func NewVelodyneDecoder(cal *VelodyneCalibration) (*VelodyneDecoder, error) {
+	n := len(cal.VerticalAngles)
+	if n != 16 && n != 32 {
+		return nil, fmt.Errorf("calibration has %d lasers, expected 16 or 32", n)
+	}
+	if cal.VerticalOffsets != nil && len(cal.VerticalOffsets) != n {
+		return nil, fmt.Errorf("calibration has %d vertical offsets for %d lasers", len(cal.VerticalOffsets), n)
+	}
+	if cal.RotationCorrections != nil && len(cal.RotationCorrections) != n {
+		return nil, fmt.Errorf("calibration has %d rotation corrections for %d lasers", len(cal.RotationCorrections), n)
+	}
+	if cal.DistanceResolution <= 0 {
+		return nil, fmt.Errorf("invalid distance resolution %v", cal.DistanceResolution)
+	}
+	if cal.LasersPerFiring < 0 || cal.LasersPerFiring > n {
+		return nil, fmt.Errorf("invalid number of lasers per firing %d", cal.LasersPerFiring)
+	}
+
+	d := &VelodyneDecoder{
+		cal:         cal,
+		rings:       ringsByElevation(cal.VerticalAngles),
+		sinVertical: make([]float64, n),
+		cosVertical: make([]float64, n),
+		current:     newFrame(0),
+	}
+	for i, angle := range cal.VerticalAngles {
+		d.sinVertical[i], d.cosVertical[i] = math.Sincos(angle * math.Pi / 180)
+	}
+
+	return d, nil
+}
+
+// Decode adds the returns of a data packet to the current frame.
+func (d *VelodyneDecoder) Decode(pkt *Packet) (*Frame, error) {
+	data := pkt.Payload
+	if len(data) != velodynePacketSize {
+		return nil, fmt.Errorf("velodyne packet has %d bytes, expected %d", len(data), velodynePacketSize)
+	}
+
+	lasers := len(d.cal.VerticalAngles)
+	sequences := velodyneChannels / lasers
+	blockDuration := float64(sequences) * d.cal.SequenceInterval
+	perFiring := max(d.cal.LasersPerFiring, 1)
+
+	step := 1
+	if data[1204] == velodyneDualReturnMode {
+		step = 2 // Both returns of a firing are stored in consecutive blocks.
+	}
+
+	packetTime := topOfHourTime(pkt.Timestamp, binary.LittleEndian.Uint32(data[1200:]))
+
+	for b := 0; b < velodyneBlocks; b++ {
+		block := data[b*velodyneBlockSize : (b+1)*velodyneBlockSize]
+		if binary.LittleEndian.Uint16(block) != velodyneBlockFlag {
+			return nil, fmt.Errorf("velodyne block %d has an invalid flag", b)
+		}
+
+		azimuth := float64(binary.LittleEndian.Uint16(block[2:])) / 100
+
+		// Azimuth covered by the block, used to interpolate the azimuth of each firing.
+		var gap float64
+		if b+step < velodyneBlocks {
+			gap = float64(binary.LittleEndian.Uint16(data[(b+step)*velodyneBlockSize+2:]))/100 - azimuth
+		} else {
+			gap = azimuth - float64(binary.LittleEndian.Uint16(data[(b-step)*velodyneBlockSize+2:]))/100
+		}
+		gap = math.Mod(gap+360, 360)
+
+		for c := 0; c < velodyneChannels; c++ {
+			laser := c % lasers
+			sequence := c / lasers
+			record := block[4+3*c:]
+
+			offset := float64(sequence)*d.cal.SequenceInterval + float64(laser/perFiring)*d.cal.FiringInterval
+			firingAzimuth := math.Mod(azimuth+gap*offset/blockDuration, 360)
+
+			// A new rotation starts when the azimuth wraps. It is checked once per firing sequence, and only on
+			// the first block of a dual return pair as the second repeats its azimuths.
+			if laser == 0 && b%step == 0 {
+				if d.hasAzimuth && firingAzimuth < d.lastAzimuth && d.current.Points.Len() > 0 {
+					d.completed.push(d.current)
+					d.current = newFrame(d.current.Points.Len())
+				}
+				d.lastAzimuth, d.hasAzimuth = firingAzimuth, true
+			}
+
+			distance := float64(binary.LittleEndian.Uint16(record)) * d.cal.DistanceResolution
+			if distance == 0 {
+				continue // No return.
+			}
+
+			correctedAzimuth := firingAzimuth
+			if d.cal.RotationCorrections != nil {
+				correctedAzimuth -= d.cal.RotationCorrections[laser]
+			}
+			sinAzimuth, cosAzimuth := math.Sincos(correctedAzimuth * math.Pi / 180)
+
+			// Velodyne azimuths run clockwise from the forward axis.
+			horizontal := distance * d.cosVertical[laser]
+			p := &point.Point3D{
+				X:         horizontal * cosAzimuth,
+				Y:         -horizontal * sinAzimuth,
+				Z:         distance * d.sinVertical[laser],
+				Intensity: float64(record[2]),
+			}
+			if d.cal.VerticalOffsets != nil {
+				p.Z += d.cal.VerticalOffsets[laser]
+			}
+
+			timestamp := packetTime + (float64(b/step)*blockDuration+offset)*1e-6
+			d.current.add(p, timestamp, d.rings[laser])
+		}
+	}
+
+	return d.completed.pop(), nil
+}
+
+// Flush returns the completed frames not yet returned, then the partially accumulated frame.
+func (d *VelodyneDecoder) Flush() *Frame {
+	if frame := d.completed.pop(); frame != nil {
+		return frame
+	}
+	if d.current.Points.Len() == 0 {
+		return nil
+	}
+	frame := d.current
+	d.current = newFrame(0)
+	d.hasAzimuth = false
+	return frame
+}
+
+// topOfHourTime combines the microseconds past the hour reported by the sensor with the capture time,
+// returning seconds since the Unix epoch.
+func topOfHourTime(captured time.Time, micros uint32) float64 {
+	hour := captured.Truncate(time.Hour)
+	t := hour.Add(time.Duration(micros) * time.Microsecond)
+
+	// The capture may happen just after the sensor's hour rolled over, or the other way round.
+	switch diff := t.Sub(captured); {
+	case diff > 30*time.Minute:
+		t = t.Add(-time.Hour)
+	case diff < -30*time.Minute:
+		t = t.Add(time.Hour)
+	}
+
+	return float64(t.UnixNano()) * 1e-9
+}
+
+// ringsByElevation returns the ring of each beam, numbering beams from the lowest elevation upwards.
+func ringsByElevation(elevations []float64) []int {
+	order := make([]int, len(elevations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return elevations[order[a]] < elevations[order[b]] })
+
+	rings := make([]int, len(elevations))
+	for ring, beam := range order {
+		rings[beam] = ring
+	}
+	return rings
+}
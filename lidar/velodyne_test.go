@@ -0,0 +1,244 @@
+package lidar
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+const velodyneSingleReturnMode = 0x37
+
+// velodynePacket returns a VLP-16 data packet whose blocks start at the given azimuths in degrees, with a
+// return at 5 m on every channel.
+func velodynePacket(azimuths [velodyneBlocks]float64, mode byte) *Packet {
+	data := make([]byte, velodynePacketSize)
+
+	for b, azimuth := range azimuths {
+		block := data[b*velodyneBlockSize:]
+		binary.LittleEndian.PutUint16(block, velodyneBlockFlag)
+		binary.LittleEndian.PutUint16(block[2:], uint16(math.Round(math.Mod(azimuth, 360)*100)))
+		for c := 0; c < velodyneChannels; c++ {
+			binary.LittleEndian.PutUint16(block[4+3*c:], 2500)
+			block[4+3*c+2] = byte(b)
+		}
+	}
+	data[1204] = mode
+	data[1205] = 0x22
+
+	return &Packet{Timestamp: time.Unix(0, 0), Payload: data}
+}
+
+// dualReturnPackets returns packets sweeping from start in dual return mode, where both blocks of each pair
+// carry the azimuth of the same firings.
+func dualReturnPackets(start float64, n int) []*Packet {
+	const pairStep = 0.4
+
+	packets := make([]*Packet, n)
+	for i := range packets {
+		var azimuths [velodyneBlocks]float64
+		for b := range azimuths {
+			azimuths[b] = start + float64(i*velodyneBlocks/2+b/2)*pairStep
+		}
+		packets[i] = velodynePacket(azimuths, velodyneDualReturnMode)
+	}
+	return packets
+}
+
+// decodeAll decodes packets, returning every frame completed followed by those left when flushing.
+func decodeAll(t *testing.T, d Decoder, packets []*Packet) (completed, flushed []*Frame) {
+	t.Helper()
+
+	for i, pkt := range packets {
+		frame, err := d.Decode(pkt)
+		if err != nil {
+			t.Fatalf("packet %d: %v", i, err)
+		}
+		if frame != nil {
+			completed = append(completed, frame)
+		}
+	}
+	for frame := d.Flush(); frame != nil; frame = d.Flush() {
+		flushed = append(flushed, frame)
+	}
+
+	return completed, flushed
+}
+
+func countPoints(frames []*Frame) int {
+	n := 0
+	for _, f := range frames {
+		n += f.Points.Len()
+	}
+	return n
+}
+
+func TestVelodyneDualReturnWithinRotation(t *testing.T) {
+	d, err := NewVelodyneDecoder(VLP16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 packets cover 48 degrees, so no rotation completes.
+	packets := dualReturnPackets(0, 20)
+	completed, flushed := decodeAll(t, d, packets)
+
+	if len(completed) != 0 {
+		t.Errorf("got %d frames completed within a single rotation, want 0", len(completed))
+	}
+	if len(flushed) != 1 {
+		t.Fatalf("got %d frames when flushing, want 1", len(flushed))
+	}
+	if want := len(packets) * velodyneBlocks * velodyneChannels; flushed[0].Points.Len() != want {
+		t.Errorf("got %d points, want %d", flushed[0].Points.Len(), want)
+	}
+}
+
+func TestVelodyneDualReturnRotation(t *testing.T) {
+	d, err := NewVelodyneDecoder(VLP16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 50 packets starting at 300 degrees cover 120 degrees, wrapping once.
+	packets := dualReturnPackets(300, 50)
+	completed, flushed := decodeAll(t, d, packets)
+
+	if len(completed) != 1 || len(flushed) != 1 {
+		t.Fatalf("got %d completed and %d flushed frames, want 1 and 1", len(completed), len(flushed))
+	}
+
+	// The first frame holds the firings up to 360 degrees: 25 packets of 2.4 degrees.
+	if want := 25 * velodyneBlocks * velodyneChannels; completed[0].Points.Len() != want {
+		t.Errorf("got %d points in the completed frame, want %d", completed[0].Points.Len(), want)
+	}
+	if got, want := countPoints(completed)+countPoints(flushed), len(packets)*velodyneBlocks*velodyneChannels; got != want {
+		t.Errorf("got %d points in total, want %d", got, want)
+	}
+}
+
+func TestVelodyneFramesCompletedByOnePacket(t *testing.T) {
+	d, err := NewVelodyneDecoder(VLP16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Azimuths jumping back every other block complete several frames within a single packet.
+	var azimuths [velodyneBlocks]float64
+	for b := range azimuths {
+		azimuths[b] = 10 + float64(b%2)*100
+	}
+	packets := []*Packet{velodynePacket(azimuths, velodyneSingleReturnMode)}
+
+	completed, flushed := decodeAll(t, d, packets)
+	frames := append(completed, flushed...)
+
+	if len(frames) != velodyneBlocks/2 {
+		t.Errorf("got %d frames, want %d", len(frames), velodyneBlocks/2)
+	}
+	if got, want := countPoints(frames), velodyneBlocks*velodyneChannels; got != want {
+		t.Errorf("got %d points in total, want %d", got, want)
+	}
+}
+
+func TestVelodyneFirstLaser(t *testing.T) {
+	d, err := NewVelodyneDecoder(VLP16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var azimuths [velodyneBlocks]float64
+	for b := range azimuths {
+		azimuths[b] = 90 + float64(b)*0.4
+	}
+	_, flushed := decodeAll(t, d, []*Packet{velodynePacket(azimuths, velodyneSingleReturnMode)})
+
+	// Laser 0 points 15 degrees down and fires first, facing right at an azimuth of 90 degrees.
+	p := flushed[0].Points.Raw()[0]
+	if math.Abs(p.X) > 1e-9 || math.Abs(p.Y+4.829629131) > 1e-9 || math.Abs(p.Z+1.282895226) > 1e-9 {
+		t.Errorf("got position %v, %v, %v, want 0, -4.8296, -1.2829", p.X, p.Y, p.Z)
+	}
+	if p.Time != 0 || p.Ring != 0 {
+		t.Errorf("got time %v and ring %d, want 0 and 0", p.Time, p.Ring)
+	}
+}
+
+func TestVelodynePointPositionAndTime(t *testing.T) {
+	const micros = 1500000 // Past the hour, stored in the packet.
+
+	tests := []struct {
+		name    string
+		cal     *VelodyneCalibration
+		block   int
+		channel int
+		ring    int
+
+		// Expected firing time within the block in microseconds.
+		offset float64
+	}{
+		{name: "VLP-16 first laser", cal: VLP16, block: 0, channel: 0, ring: 0, offset: 0},
+		{name: "VLP-16 second sequence", cal: VLP16, block: 2, channel: 19, ring: 9, offset: 55.296 + 3*2.304},
+		{name: "VLP-32C paired firing", cal: VLP32C, block: 1, channel: 5, ring: 20, offset: 2 * 2.304},
+		{name: "HDL-32E", cal: HDL32E, block: 3, channel: 5, ring: 18, offset: 5 * 1.152},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewVelodyneDecoder(tt.cal)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var azimuths [velodyneBlocks]float64
+			for b := range azimuths {
+				azimuths[b] = 90 + float64(b)*0.4
+			}
+			pkt := velodynePacket(azimuths, velodyneSingleReturnMode)
+			binary.LittleEndian.PutUint32(pkt.Payload[1200:], micros)
+
+			_, flushed := decodeAll(t, d, []*Packet{pkt})
+			if len(flushed) != 1 {
+				t.Fatalf("got %d frames, want 1", len(flushed))
+			}
+			p := flushed[0].Points.Raw()[tt.block*velodyneChannels+tt.channel]
+
+			lasers := len(tt.cal.VerticalAngles)
+			laser := tt.channel % lasers
+			blockDuration := float64(velodyneChannels/lasers) * tt.cal.SequenceInterval
+
+			azimuth := azimuths[tt.block] + 0.4*tt.offset/blockDuration
+			if tt.cal.RotationCorrections != nil {
+				azimuth -= tt.cal.RotationCorrections[laser]
+			}
+			azimuth *= math.Pi / 180
+			elevation := tt.cal.VerticalAngles[laser] * math.Pi / 180
+			distance := 2500 * tt.cal.DistanceResolution
+
+			want := [3]float64{
+				distance * math.Cos(elevation) * math.Cos(azimuth),
+				-distance * math.Cos(elevation) * math.Sin(azimuth),
+				distance * math.Sin(elevation),
+			}
+			if tt.cal.VerticalOffsets != nil {
+				want[2] += tt.cal.VerticalOffsets[laser]
+			}
+			for i, got := range [3]float64{p.X, p.Y, p.Z} {
+				if math.Abs(got-want[i]) > 1e-9 {
+					t.Errorf("got position %v, %v, %v, want %v", p.X, p.Y, p.Z, want)
+					break
+				}
+			}
+
+			wantTime := micros*1e-6 + (float64(tt.block)*blockDuration+tt.offset)*1e-6
+			if math.Abs(p.Time-wantTime) > 1e-9 {
+				t.Errorf("got time %.9f, want %.9f", p.Time, wantTime)
+			}
+			if p.Ring != tt.ring {
+				t.Errorf("got ring %d, want %d", p.Ring, tt.ring)
+			}
+			if p.Intensity != float64(tt.block) {
+				t.Errorf("got intensity %v, want %d", p.Intensity, tt.block)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Mesh is an indexed triangle mesh.
+type Mesh struct {
+	Vertices *point.Points3D
+
+	// Triangles holds the vertex indices of each face. Normals follow the right hand rule, so counter-clockwise
+	// faces seen from outside point outwards.
+	Triangles [][3]int
+}
+
+// Read reads an OBJ, STL or PLY mesh, choosing the format from the file extension.
+func Read(filePath string) (*Mesh, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(filePath)); ext {
+	case ".obj":
+		return DecodeOBJ(file)
+	case ".stl":
+		return DecodeSTL(file)
+	case ".ply":
+		return DecodePLY(file)
+	default:
+		return nil, fmt.Errorf("unsupported mesh format %q", ext)
+	}
+}
+
+// TriangleArea returns the area of triangle i.
+func (m *Mesh) TriangleArea(i int) float64 {
+	x, y, z := m.cross(i)
+	return 0.5 * math.Sqrt(x*x+y*y+z*z)
+}
+
+// TriangleNormal returns the unit normal of triangle i, or the zero vector if the triangle is degenerate.
+func (m *Mesh) TriangleNormal(i int) (float64, float64, float64) {
+	x, y, z := m.cross(i)
+	length := math.Sqrt(x*x + y*y + z*z)
+	if length == 0 {
+		return 0, 0, 0
+	}
+	return x / length, y / length, z / length
+}
+
+// Area returns the total surface area of the mesh.
+func (m *Mesh) Area() float64 {
+	area := 0.0
+	for i := range m.Triangles {
+		area += m.TriangleArea(i)
+	}
+	return area
+}
+
+// cross returns the cross product of the edges of triangle i leaving its first vertex.
+func (m *Mesh) cross(i int) (float64, float64, float64) {
+	vertices := *m.Vertices
+	t := m.Triangles[i]
+	a, b, c := vertices[t[0]], vertices[t[1]], vertices[t[2]]
+
+	ux, uy, uz := b.X-a.X, b.Y-a.Y, b.Z-a.Z
+	vx, vy, vz := c.X-a.X, c.Y-a.Y, c.Z-a.Z
+
+	return uy*vz - uz*vy, uz*vx - ux*vz, ux*vy - uy*vx
+}
+
+// addPolygon fan triangulates a convex polygon, checking its indices against the number of vertices.
+func (m *Mesh) addPolygon(indices []int) error {
+	if len(indices) < 3 {
+		return fmt.Errorf("face has %d vertices", len(indices))
+	}
+	for _, i := range indices {
+		if i < 0 || i >= m.Vertices.Len() {
+			return fmt.Errorf("face refers to vertex %d of %d", i, m.Vertices.Len())
+		}
+	}
+
+	for i := 1; i+1 < len(indices); i++ {
+		m.Triangles = append(m.Triangles, [3]int{indices[0], indices[i], indices[i+1]})
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// cubeOBJ is a unit cube with counter-clockwise quads seen from outside, referencing its vertices in each of
+// the forms OBJ allows.
+const cubeOBJ = `# unit cube
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+v 0 0 1 1 0 0
+v 1 0 1
+v 1 1 1
+v 0 1 1
+vt 0 0
+vn 0 0 1
+g cube
+usemtl grey
+f 1 4 3 2
+f 5/1 6/1 7/1 8/1
+f -8/1/1 -7/1/1 -3/1/1 -4/1/1
+f 4//1 8//1 7//1 3//1
+f 1 5 8 4
+f 2 3 7 6
+`
+
+func TestDecodeOBJ(t *testing.T) {
+	m, err := DecodeOBJ(strings.NewReader(cubeOBJ))
+	if err != nil {
+		t.Fatalf("DecodeOBJ: %v", err)
+	}
+
+	if m.Vertices.Len() != 8 || len(m.Triangles) != 12 {
+		t.Fatalf("got %d vertices and %d triangles, want 8 and 12", m.Vertices.Len(), len(m.Triangles))
+	}
+	if got, want := m.Triangles[:6], [][3]int{{0, 3, 2}, {0, 2, 1}, {4, 5, 6}, {4, 6, 7}, {0, 1, 5}, {0, 5, 4}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got triangles %v, want %v", got, want)
+	}
+	if p := m.Vertices.Raw()[4]; p.R != 255 || p.G != 0 || p.B != 0 {
+		t.Errorf("got vertex color %d %d %d, want 255 0 0", p.R, p.G, p.B)
+	}
+	if area := m.Area(); math.Abs(area-6) > 1e-12 {
+		t.Errorf("got area %v, want 6", area)
+	}
+
+	// Every face points outwards, away from the center of the cube.
+	for i, tri := range m.Triangles {
+		nx, ny, nz := m.TriangleNormal(i)
+		a := m.Vertices.Raw()[tri[0]]
+		if (a.X-0.5)*nx+(a.Y-0.5)*ny+(a.Z-0.5)*nz <= 0 {
+			t.Errorf("triangle %d has inward normal (%v, %v, %v)", i, nx, ny, nz)
+		}
+	}
+}
+
+func TestDecodeOBJErrors(t *testing.T) {
+	const vertices = "v 0 0 0\nv 1 0 0\nv 0 1 0\n"
+
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{name: "short vertex", file: "v 1 2\n", err: "line 1: vertex has 2 coordinates"},
+		{name: "invalid vertex value", file: "v 1 2 x\n", err: "invalid vertex value"},
+		{name: "zero index", file: vertices + "f 0 1 2\n", err: "line 4: invalid vertex reference"},
+		{name: "invalid index", file: vertices + "f 1 a/1 2\n", err: "invalid vertex reference"},
+		{name: "index past the vertices", file: vertices + "f 1 2 4\n", err: "face refers to vertex 3 of 3"},
+		{name: "negative index before the first vertex", file: vertices + "f -4 1 2\n", err: "face refers to vertex -1 of 3"},
+		{name: "face with two vertices", file: vertices + "f 1 2\n", err: "face has 2 vertices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeOBJ(strings.NewReader(tt.file)); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// square is two triangles sharing the edge from (1, 0, 0) to (0, 1, 0).
+var square = [][3][3]float64{
+	{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}},
+	{{1, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+}
+
+// asciiSTL returns an ASCII STL of facets.
+func asciiSTL(facets [][3][3]float64) string {
+	var b strings.Builder
+	b.WriteString("solid square\n")
+	for _, f := range facets {
+		b.WriteString("  facet normal 0 0 1\n    outer loop\n")
+		for _, c := range f {
+			b.WriteString("      vertex")
+			for _, v := range c {
+				b.WriteString(" " + strconv.FormatFloat(v, 'e', -1, 64))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("    endloop\n  endfacet\n")
+	}
+	b.WriteString("endsolid square\n")
+	return b.String()
+}
+
+// binarySTL returns a binary STL whose 80 byte header starts with "solid", as some exporters write.
+func binarySTL(facets [][3][3]float64) []byte {
+	header := make([]byte, 80)
+	copy(header, "solid square exported as binary")
+
+	b := binary.LittleEndian.AppendUint32(header, uint32(len(facets)))
+	for _, f := range facets {
+		b = append(b, make([]byte, 12)...) // Facet normal.
+		for _, c := range f {
+			for _, v := range c {
+				b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+			}
+		}
+		b = append(b, 0, 0) // Attribute byte count.
+	}
+	return b
+}
+
+func TestDecodeSTL(t *testing.T) {
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{name: "ascii", file: []byte(asciiSTL(square))},
+		{name: "binary starting with solid", file: binarySTL(square)},
+	}
+
+	wantVertices := point.Points3D{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}, {X: 1, Y: 1}}
+	wantTriangles := [][3]int{{0, 1, 2}, {1, 3, 2}}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := DecodeSTL(bytes.NewReader(tt.file))
+			if err != nil {
+				t.Fatalf("DecodeSTL: %v", err)
+			}
+
+			// The corners shared by both facets are merged.
+			if !reflect.DeepEqual(*m.Vertices, wantVertices) {
+				t.Errorf("got vertices %v, want %v", *m.Vertices, wantVertices)
+			}
+			if !reflect.DeepEqual(m.Triangles, wantTriangles) {
+				t.Errorf("got triangles %v, want %v", m.Triangles, wantTriangles)
+			}
+		})
+	}
+}
+
+func TestDecodeSTLErrors(t *testing.T) {
+	binaryCount := binarySTL(square)
+	binary.LittleEndian.PutUint32(binaryCount[80:], 3)
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{name: "not an STL", file: []byte("ply\nformat ascii 1.0\n"), err: "not an STL"},
+		{name: "binary with a triangle count beyond the file", file: binaryCount[5:], err: "not an STL"},
+		{name: "facet with two vertices", file: []byte("solid s\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\nendfacet\nendsolid s\n"), err: "fewer than 3 vertices"},
+		{name: "vertex with two coordinates", file: []byte("solid s\nvertex 0 0"), err: "fewer than 3 coordinates"},
+		{name: "invalid vertex value", file: []byte("solid s\nvertex 0 zero 0\n"), err: "invalid vertex value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeSTL(bytes.NewReader(tt.file)); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// plyMesh returns an ASCII PLY holding the vertices of a unit square and a pentagon above it, with the given
+// face element.
+func plyMesh(faces string) string {
+	return `ply
+format ascii 1.0
+element vertex 9
+property float x
+property float y
+property float z
+` + faces + `end_header
+0 0 0
+1 0 0
+1 1 0
+0 1 0
+0 0 1
+1 0 1
+1.5 0.5 1
+1 1 1
+0 1 1
+`
+}
+
+func TestDecodePLY(t *testing.T) {
+	tests := []struct {
+		name  string
+		faces string
+	}{
+		{name: "vertex_indices", faces: "element face 2\nproperty list uchar int vertex_indices\n"},
+		{name: "vertex_index with a scalar property", faces: "element face 2\nproperty uchar flags\nproperty list uint8 uint32 vertex_index\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "4 0 1 2 3\n5 4 5 6 7 8\n"
+			if strings.Contains(tt.faces, "flags") {
+				body = "1 4 0 1 2 3\n0 5 4 5 6 7 8\n"
+			}
+
+			m, err := DecodePLY(strings.NewReader(plyMesh(tt.faces) + body))
+			if err != nil {
+				t.Fatalf("DecodePLY: %v", err)
+			}
+
+			// The quad and the pentagon are fan triangulated from their first vertex.
+			want := [][3]int{{0, 1, 2}, {0, 2, 3}, {4, 5, 6}, {4, 6, 7}, {4, 7, 8}}
+			if !reflect.DeepEqual(m.Triangles, want) {
+				t.Errorf("got triangles %v, want %v", m.Triangles, want)
+			}
+			if area := m.Area(); math.Abs(area-2.25) > 1e-12 {
+				t.Errorf("got area %v, want 2.25", area)
+			}
+		})
+	}
+}
+
+func TestDecodePLYErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		err  string
+	}{
+		{name: "missing face element", file: plyMesh(""), err: "missing face element"},
+		{name: "face without a list", file: plyMesh("element face 1\nproperty int vertex_indices\n") + "0\n", err: "no vertex_indices list"},
+		{name: "face past the vertices", file: plyMesh("element face 1\nproperty list uchar int vertex_indices\n") + "3 0 1 9\n", err: "face refers to vertex 9 of 9"},
+		{name: "face with two vertices", file: plyMesh("element face 1\nproperty list uchar int vertex_indices\n") + "2 0 1\n", err: "face has 2 vertices"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodePLY(strings.NewReader(tt.file)); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string][]byte{
+		"cube.OBJ":   []byte(cubeOBJ),
+		"square.stl": binarySTL(square),
+		"mesh.ply":   []byte(plyMesh("element face 1\nproperty list uchar int vertex_indices\n") + "3 0 1 2\n"),
+		"mesh.off":   []byte("OFF\n"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		triangles int
+	}{
+		{name: "cube.OBJ", triangles: 12},
+		{name: "square.stl", triangles: 2},
+		{name: "mesh.ply", triangles: 1},
+	}
+	for _, tt := range tests {
+		m, err := Read(filepath.Join(dir, tt.name))
+		if err != nil {
+			t.Errorf("Read(%s): %v", tt.name, err)
+			continue
+		}
+		if len(m.Triangles) != tt.triangles {
+			t.Errorf("%s: got %d triangles, want %d", tt.name, len(m.Triangles), tt.triangles)
+		}
+	}
+
+	if _, err := Read(filepath.Join(dir, "mesh.off")); err == nil || !strings.Contains(err.Error(), "unsupported mesh format") {
+		t.Errorf("got error %v reading an OFF file, want an unsupported format", err)
+	}
+}
@@ -0,0 +1,103 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// ReadOBJ reads a Wavefront OBJ mesh.
+func ReadOBJ(filePath string) (*Mesh, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeOBJ(file)
+}
+
+// DecodeOBJ reads the vertices and faces of a Wavefront OBJ stream. Polygons are fan triangulated and vertex
+// colors following the coordinates are kept. Texture coordinates, normals, groups and materials are ignored.
+func DecodeOBJ(r io.Reader) (*Mesh, error) {
+	vertices := make(point.Points3D, 0)
+	m := &Mesh{Vertices: &vertices}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			p, err := parseOBJVertex(fields[1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			vertices = append(vertices, p)
+		case "f":
+			indices := make([]int, len(fields)-1)
+			for i, f := range fields[1:] {
+				// Faces reference vertices as v, v/vt, v//vn or v/vt/vn.
+				v, _, _ := strings.Cut(f, "/")
+				index, err := strconv.Atoi(v)
+				if err != nil || index == 0 {
+					return nil, fmt.Errorf("line %d: invalid vertex reference %q", lineNumber, f)
+				}
+				// Indices are one-based, negative indices count back from the latest vertex.
+				if index < 0 {
+					indices[i] = len(vertices) + index
+				} else {
+					indices[i] = index - 1
+				}
+			}
+			if err := m.addPolygon(indices); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parseOBJVertex parses "x y z [w]" or "x y z r g b" with colors in [0, 1].
+func parseOBJVertex(fields []string) (*point.Point3D, error) {
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("vertex has %d coordinates", len(fields))
+	}
+
+	values := make([]float64, len(fields))
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vertex value %q", f)
+		}
+		values[i] = v
+	}
+
+	p := &point.Point3D{X: values[0], Y: values[1], Z: values[2]}
+	if len(values) >= 6 {
+		p.R, p.G, p.B = toColor(values[3]), toColor(values[4]), toColor(values[5])
+	}
+	return p, nil
+}
+
+func toColor(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v*255))))
+}
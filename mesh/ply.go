@@ -0,0 +1,60 @@
+package mesh
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/flynnletford/icp-go/ply"
+)
+
+// ReadPLY reads the vertex and face elements of a PLY mesh.
+func ReadPLY(filePath string) (*Mesh, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodePLY(file)
+}
+
+// DecodePLY reads the vertex and face elements of a PLY stream.
+func DecodePLY(r io.Reader) (*Mesh, error) {
+	data, err := ply.DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return FromPLY(data)
+}
+
+// FromPLY builds a mesh from decoded PLY data. Polygonal faces are fan triangulated.
+func FromPLY(data *ply.Data) (*Mesh, error) {
+	m := &Mesh{Vertices: data.Points}
+
+	faces, ok := data.Elements["face"]
+	if !ok {
+		return nil, errors.New("missing face element")
+	}
+
+	property := faces.Element.Property("vertex_indices")
+	if property < 0 {
+		property = faces.Element.Property("vertex_index")
+	}
+	if property < 0 || !faces.Element.Properties[property].IsList {
+		return nil, errors.New("face element has no vertex_indices list")
+	}
+
+	for _, entry := range faces.Values {
+		indices := make([]int, len(entry[property]))
+		for i, v := range entry[property] {
+			indices[i] = int(v)
+		}
+		if err := m.addPolygon(indices); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
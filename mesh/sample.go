@@ -0,0 +1,212 @@
+package mesh
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// SampleMethod selects how points are distributed over the surface of a mesh.
+type SampleMethod int
+
+const (
+	// Uniform draws points independently with a density proportional to area.
+	Uniform SampleMethod = iota
+
+	// PoissonDisk spreads points evenly by eliminating the most crowded points of a denser uniform sample.
+	PoissonDisk
+)
+
+type SampleOptions struct {
+	Method SampleMethod `json:"method"`
+
+	// Seed of the random number generator, so that sampling is repeatable.
+	Seed int64 `json:"seed"`
+
+	// Number of uniform candidates drawn per output point for Poisson-disk sampling.
+	// Larger values give a more even spacing at the cost of computational load.
+	Oversample int `json:"oversample"`
+}
+
+var DefaultSampleOptions *SampleOptions = &SampleOptions{
+	Method:     Uniform,
+	Oversample: 5,
+}
+
+// Sample draws n points from the surface of the mesh. Each point carries the unit normal of the triangle it
+// lies on, so the result can be used as an icp.PointToPlane target without estimating normals.
+func (m *Mesh) Sample(n int, opts *SampleOptions) (*point.Points3D, error) {
+	if opts == nil {
+		opts = DefaultSampleOptions
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("cannot sample %d points", n)
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+
+	switch opts.Method {
+	case Uniform:
+		return m.sampleUniform(n, rng)
+	case PoissonDisk:
+		oversample := opts.Oversample
+		if oversample < 1 {
+			oversample = DefaultSampleOptions.Oversample
+		}
+
+		candidates, err := m.sampleUniform(n*oversample, rng)
+		if err != nil {
+			return nil, err
+		}
+		return eliminateSamples(candidates, n, m.Area()), nil
+	default:
+		return nil, errors.New("unknown sample method")
+	}
+}
+
+// sampleUniform picks triangles with a probability proportional to their area and points uniformly within them.
+func (m *Mesh) sampleUniform(n int, rng *rand.Rand) (*point.Points3D, error) {
+	cumulative := make([]float64, len(m.Triangles))
+	total := 0.0
+	for i := range m.Triangles {
+		total += m.TriangleArea(i)
+		cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, errors.New("mesh has no surface area")
+	}
+
+	vertices := *m.Vertices
+	points := make(point.Points3D, n)
+
+	for i := range points {
+		t := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+		if t == len(cumulative) {
+			t--
+		}
+		a, b, c := vertices[m.Triangles[t][0]], vertices[m.Triangles[t][1]], vertices[m.Triangles[t][2]]
+
+		// Barycentric coordinates that are uniform over the triangle.
+		r := math.Sqrt(rng.Float64())
+		v := rng.Float64()
+		u, w := 1-r, r*v
+		v = r * (1 - v)
+
+		p := &point.Point3D{
+			X: u*a.X + v*b.X + w*c.X,
+			Y: u*a.Y + v*b.Y + w*c.Y,
+			Z: u*a.Z + v*b.Z + w*c.Z,
+		}
+		p.Nx, p.Ny, p.Nz = m.TriangleNormal(t)
+
+		points[i] = p
+	}
+
+	return &points, nil
+}
+
+// eliminateSamples reduces candidates to n points by repeatedly removing the point whose neighbors are closest,
+// following Yuksel's weighted sample elimination for surfaces of the given area.
+func eliminateSamples(candidates *point.Points3D, n int, area float64) *point.Points3D {
+	if n >= candidates.Len() {
+		return candidates
+	}
+
+	const (
+		alpha = 8
+		beta  = 0.65
+		gamma = 1.5
+	)
+
+	// Maximum radius of n disks packed on the surface.
+	rMax := math.Sqrt(area / (2 * math.Sqrt(3) * float64(n)))
+	rMin := rMax * (1 - math.Pow(float64(n)/float64(candidates.Len()), gamma)) * beta
+
+	tree := point.NewKDTree(candidates, nil)
+
+	type neighbor struct {
+		index  int
+		weight float64
+	}
+
+	neighbors := make([][]neighbor, candidates.Len())
+	weights := make([]float64, candidates.Len())
+
+	for i, p := range candidates.Raw() {
+		indices, distances := tree.NearestRadius(p.X, p.Y, p.Z, 2*rMax)
+
+		for j, index := range indices {
+			if index == i {
+				continue
+			}
+			d := math.Max(math.Sqrt(distances[j]), rMin)
+			w := math.Pow(1-d/(2*rMax), alpha)
+
+			neighbors[i] = append(neighbors[i], neighbor{index: index, weight: w})
+			weights[i] += w
+		}
+	}
+
+	queue := &weightQueue{weights: weights, positions: make([]int, len(weights))}
+	for i := range weights {
+		queue.items = append(queue.items, i)
+		queue.positions[i] = i
+	}
+	heap.Init(queue)
+
+	removed := make([]bool, candidates.Len())
+	for remaining := candidates.Len(); remaining > n; remaining-- {
+		i := heap.Pop(queue).(int)
+		removed[i] = true
+
+		for _, nb := range neighbors[i] {
+			if removed[nb.index] {
+				continue
+			}
+			weights[nb.index] -= nb.weight
+			heap.Fix(queue, queue.positions[nb.index])
+		}
+	}
+
+	points := make(point.Points3D, 0, n)
+	for i, p := range candidates.Raw() {
+		if !removed[i] {
+			points = append(points, p)
+		}
+	}
+
+	return &points
+}
+
+// weightQueue is a max-heap of point indices ordered by weight that tracks where each index is held.
+type weightQueue struct {
+	items     []int
+	weights   []float64
+	positions []int
+}
+
+func (q *weightQueue) Len() int { return len(q.items) }
+
+func (q *weightQueue) Less(i, j int) bool { return q.weights[q.items[i]] > q.weights[q.items[j]] }
+
+func (q *weightQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+	q.positions[q.items[i]] = i
+	q.positions[q.items[j]] = j
+}
+
+func (q *weightQueue) Push(x any) {
+	q.positions[x.(int)] = len(q.items)
+	q.items = append(q.items, x.(int))
+}
+
+func (q *weightQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
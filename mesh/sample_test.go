@@ -0,0 +1,99 @@
+package mesh
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// minSpacing returns the smallest distance between any two points.
+func minSpacing(points *point.Points3D) float64 {
+	raw := points.Raw()
+	spacing := math.Inf(1)
+	for i, p := range raw {
+		for _, q := range raw[i+1:] {
+			spacing = math.Min(spacing, math.Sqrt((p.X-q.X)*(p.X-q.X)+(p.Y-q.Y)*(p.Y-q.Y)+(p.Z-q.Z)*(p.Z-q.Z)))
+		}
+	}
+	return spacing
+}
+
+func TestSample(t *testing.T) {
+	cube, err := DecodeOBJ(strings.NewReader(cubeOBJ))
+	if err != nil {
+		t.Fatalf("DecodeOBJ: %v", err)
+	}
+
+	const n = 300
+
+	spacing := map[SampleMethod]float64{}
+	for _, method := range []SampleMethod{Uniform, PoissonDisk} {
+		points, err := cube.Sample(n, &SampleOptions{Method: method, Seed: 1})
+		if err != nil {
+			t.Fatalf("method %d: Sample: %v", method, err)
+		}
+		if points.Len() != n {
+			t.Fatalf("method %d: got %d points, want %d", method, points.Len(), n)
+		}
+
+		// Each point carries the outward unit normal of the cube face it lies on.
+		for i, p := range points.Raw() {
+			if math.Abs(p.Nx*p.Nx+p.Ny*p.Ny+p.Nz*p.Nz-1) > 1e-12 {
+				t.Fatalf("method %d: point %d has normal (%v, %v, %v) of non-unit length", method, i, p.Nx, p.Ny, p.Nz)
+			}
+
+			onFace := false
+			for axis, c := range [][2]float64{{p.X, p.Nx}, {p.Y, p.Ny}, {p.Z, p.Nz}} {
+				if math.Abs(math.Abs(c[1])-1) < 1e-12 {
+					onFace = math.Abs(c[0]-(c[1]+1)/2) < 1e-12
+					if !onFace {
+						t.Errorf("method %d: point %d at %v on axis %d has normal %v", method, i, c[0], axis, c[1])
+					}
+				}
+			}
+			if !onFace {
+				t.Errorf("method %d: point %d has normal (%v, %v, %v) that is not an outward face normal", method, i, p.Nx, p.Ny, p.Nz)
+			}
+		}
+
+		spacing[method] = minSpacing(points)
+	}
+
+	if spacing[PoissonDisk] <= 2*spacing[Uniform] {
+		t.Errorf("got Poisson-disk spacing %v, want it well above the uniform spacing %v", spacing[PoissonDisk], spacing[Uniform])
+	}
+}
+
+func TestSampleErrors(t *testing.T) {
+	cube, err := DecodeOBJ(strings.NewReader(cubeOBJ))
+	if err != nil {
+		t.Fatalf("DecodeOBJ: %v", err)
+	}
+	flat, err := DecodeOBJ(strings.NewReader("v 0 0 0\nv 1 0 0\nv 2 0 0\nf 1 2 3\n"))
+	if err != nil {
+		t.Fatalf("DecodeOBJ: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		mesh *Mesh
+		n    int
+		opts *SampleOptions
+		err  string
+	}{
+		{name: "negative count", mesh: cube, n: -1, err: "cannot sample -1 points"},
+		{name: "unknown method", mesh: cube, n: 10, opts: &SampleOptions{Method: 2}, err: "unknown sample method"},
+		{name: "no surface area", mesh: flat, n: 10, err: "no surface area"},
+		{name: "no surface area with Poisson-disk", mesh: flat, n: 10, opts: &SampleOptions{Method: PoissonDisk}, err: "no surface area"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.mesh.Sample(tt.n, tt.opts); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// ReadSTL reads an ASCII or binary STL mesh.
+func ReadSTL(filePath string) (*Mesh, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeSTL(file)
+}
+
+// DecodeSTL reads an ASCII or binary STL stream. STL stores every triangle with its own corners, so identical
+// corners are merged into shared vertices. The facet normals in the file are ignored in favour of the winding.
+func DecodeSTL(r io.Reader) (*Mesh, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Binary files may also start with "solid", so the size implied by the triangle count decides.
+	if len(data) >= 84 && 84+50*int(binary.LittleEndian.Uint32(data[80:])) == len(data) {
+		return decodeBinarySTL(data)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("solid")) {
+		return decodeASCIISTL(data)
+	}
+
+	return nil, errors.New("incorrect header; not an STL file")
+}
+
+func decodeBinarySTL(data []byte) (*Mesh, error) {
+	count := int(binary.LittleEndian.Uint32(data[80:]))
+	b := newSTLBuilder(count)
+
+	for i := 0; i < count; i++ {
+		// Each triangle holds a normal, three corners and an attribute byte count.
+		facet := data[84+50*i+12:]
+
+		var corners [3][3]float64
+		for j := range corners {
+			for k := range corners[j] {
+				corners[j][k] = float64(math.Float32frombits(binary.LittleEndian.Uint32(facet[12*j+4*k:])))
+			}
+		}
+		b.add(corners)
+	}
+
+	return b.mesh(), nil
+}
+
+func decodeASCIISTL(data []byte) (*Mesh, error) {
+	fields := bytes.Fields(data)
+	b := newSTLBuilder(len(fields) / 21)
+
+	var corners [3][3]float64
+	n := 0
+	for i := 0; i < len(fields); i++ {
+		if string(fields[i]) != "vertex" {
+			continue
+		}
+		if i+3 >= len(fields) {
+			return nil, errors.New("vertex has fewer than 3 coordinates")
+		}
+
+		for k := 0; k < 3; k++ {
+			v, err := strconv.ParseFloat(string(fields[i+1+k]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid vertex value %q", fields[i+1+k])
+			}
+			corners[n][k] = v
+		}
+		i += 3
+
+		n++
+		if n == 3 {
+			b.add(corners)
+			n = 0
+		}
+	}
+	if n != 0 {
+		return nil, errors.New("facet has fewer than 3 vertices")
+	}
+
+	return b.mesh(), nil
+}
+
+// stlBuilder merges the corners of STL facets into an indexed mesh.
+type stlBuilder struct {
+	vertices  point.Points3D
+	triangles [][3]int
+	indices   map[[3]float64]int
+}
+
+func newSTLBuilder(count int) *stlBuilder {
+	return &stlBuilder{
+		vertices:  make(point.Points3D, 0, count/2),
+		triangles: make([][3]int, 0, count),
+		indices:   make(map[[3]float64]int, count/2),
+	}
+}
+
+func (b *stlBuilder) add(corners [3][3]float64) {
+	var t [3]int
+	for j, c := range corners {
+		index, ok := b.indices[c]
+		if !ok {
+			index = len(b.vertices)
+			b.indices[c] = index
+			b.vertices = append(b.vertices, &point.Point3D{X: c[0], Y: c[1], Z: c[2]})
+		}
+		t[j] = index
+	}
+	b.triangles = append(b.triangles, t)
+}
+
+func (b *stlBuilder) mesh() *Mesh {
+	return &Mesh{Vertices: &b.vertices, Triangles: b.triangles}
+}
@@ -0,0 +1,384 @@
+package pcd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Data is the decoded content of a PCD file.
+type Data struct {
+	Header *Header
+
+	// Points holds every point in file order. Organized clouds keep their invalid (NaN) points so that
+	// the point at row r and column c is at index r*Header.Width + c.
+	Points *point.Points3D
+
+	// Extra holds the values of fields that have no point.Point3D field, keyed by field name. Fields with
+	// a COUNT above one are split into name_0, name_1, etc. Each slice has one value per point.
+	Extra map[string][]float64
+}
+
+// target identifies where the values of a field are stored.
+type target int
+
+const (
+	targetExtra target = iota
+	targetSkip
+	targetX
+	targetY
+	targetZ
+	targetNx
+	targetNy
+	targetNz
+	targetIntensity
+	targetRGB
+	targetTime
+	targetRing
+	targetLabel
+)
+
+// targets maps the field names written by PCL onto point.Point3D fields.
+var targets = map[string]target{
+	"x":         targetX,
+	"y":         targetY,
+	"z":         targetZ,
+	"normal_x":  targetNx,
+	"normal_y":  targetNy,
+	"normal_z":  targetNz,
+	"intensity": targetIntensity,
+	"rgb":       targetRGB,
+	"rgba":      targetRGB,
+	"time":      targetTime,
+	"timestamp": targetTime,
+	"ring":      targetRing,
+	"label":     targetLabel,
+	"_":         targetSkip, // PCL padding.
+}
+
+// Decode reads a PCD stream, drops points with non-finite coordinates and crops the rest according to opts.
+func Decode(r io.Reader, opts *ReadOptions) (*point.Points3D, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	data, err := DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	finite := make([]*point.Point3D, 0, data.Points.Len())
+	for _, p := range data.Points.Raw() {
+		if isFinite(p) {
+			finite = append(finite, p)
+		}
+	}
+	points := point.Points3D(finite)
+
+	return points.Crop(&opts.CropOptions), nil
+}
+
+// DecodeCloud reads a PCD stream into a point.Cloud, dropping points with non-finite coordinates and cropping
+// the rest according to opts.
+func DecodeCloud(r io.Reader, opts *ReadOptions) (*point.Cloud, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	data, err := DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cloud := point.NewCloud(data.Points.Len(), data.Points.Attributes())
+	for _, p := range data.Points.Raw() {
+		if isFinite(p) && opts.Accept(p) {
+			cloud.Append(p)
+		}
+	}
+
+	return cloud, nil
+}
+
+// DecodeAll reads a complete PCD stream.
+func DecodeAll(r io.Reader) (*Data, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := newPointDecoder(h)
+	if err != nil {
+		return nil, err
+	}
+
+	switch h.Format {
+	case ASCII:
+		err = d.decodeASCII(br)
+	case Binary:
+		err = d.decodeBinary(br)
+	default:
+		err = d.decodeCompressed(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Data{Header: h, Points: d.points, Extra: d.extra}, nil
+}
+
+func isFinite(p *point.Point3D) bool {
+	return !math.IsNaN(p.X) && !math.IsNaN(p.Y) && !math.IsNaN(p.Z) &&
+		!math.IsInf(p.X, 0) && !math.IsInf(p.Y, 0) && !math.IsInf(p.Z, 0)
+}
+
+type pointDecoder struct {
+	header  *Header
+	targets []target
+
+	// extraNames[i][k] is the Extra key of value k of field i.
+	extraNames [][]string
+
+	points *point.Points3D
+	extra  map[string][]float64
+
+	// Points allocated but not yet read into.
+	storage []point.Point3D
+}
+
+// Counts read from a header are not trusted to size allocations: slices start with at most maxPrealloc
+// entries and grow as points are actually read.
+const maxPrealloc = 1 << 16
+
+func newPointDecoder(h *Header) (*pointDecoder, error) {
+	for _, name := range []string{"x", "y", "z"} {
+		if h.Field(name) < 0 {
+			return nil, fmt.Errorf("missing field %q", name)
+		}
+	}
+
+	d := &pointDecoder{
+		header:     h,
+		targets:    make([]target, len(h.Fields)),
+		extraNames: make([][]string, len(h.Fields)),
+		extra:      make(map[string][]float64),
+	}
+
+	points := make(point.Points3D, 0, min(h.Points, maxPrealloc))
+	d.points = &points
+
+	for i, f := range h.Fields {
+		t, ok := targets[f.Name]
+		if ok && (f.Count == 1 || t == targetSkip) {
+			d.targets[i] = t
+			continue
+		}
+
+		d.targets[i] = targetExtra
+		d.extraNames[i] = make([]string, f.Count)
+		for k := range d.extraNames[i] {
+			name := f.Name
+			if f.Count > 1 {
+				name = fmt.Sprintf("%s_%d", f.Name, k)
+			}
+			d.extraNames[i][k] = name
+			d.extra[name] = make([]float64, 0, min(h.Points, maxPrealloc))
+		}
+	}
+
+	return d, nil
+}
+
+// add appends a zero point for the next record to be decoded into. Points are allocated in blocks as they
+// are read, so a point count the body does not hold is never allocated up front.
+func (d *pointDecoder) add() {
+	if len(d.storage) == 0 {
+		d.storage = make([]point.Point3D, min(d.header.Points-d.points.Len(), maxPrealloc))
+	}
+	*d.points = append(*d.points, &d.storage[0])
+	d.storage = d.storage[1:]
+
+	for name, values := range d.extra {
+		d.extra[name] = append(values, 0)
+	}
+}
+
+func (d *pointDecoder) set(i int, field int, k int, v float64) {
+	p := (*d.points)[i]
+
+	switch d.targets[field] {
+	case targetX:
+		p.X = v
+	case targetY:
+		p.Y = v
+	case targetZ:
+		p.Z = v
+	case targetNx:
+		p.Nx = v
+	case targetNy:
+		p.Ny = v
+	case targetNz:
+		p.Nz = v
+	case targetIntensity:
+		p.Intensity = v
+	case targetTime:
+		p.Time = v
+	case targetRing:
+		p.Ring = int(v)
+	case targetLabel:
+		p.Label = int(v)
+	case targetExtra:
+		d.extra[d.extraNames[field][k]][i] = v
+	}
+}
+
+// setRGB unpacks a PCL 0xAARRGGBB color.
+func (d *pointDecoder) setRGB(i int, packed uint32) {
+	p := (*d.points)[i]
+	p.R = uint8(packed >> 16)
+	p.G = uint8(packed >> 8)
+	p.B = uint8(packed)
+}
+
+func (d *pointDecoder) decodeASCII(r *bufio.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	i := 0
+	for i < d.header.Points && scanner.Scan() {
+		tokens := strings.Fields(scanner.Text())
+		if len(tokens) == 0 {
+			continue
+		}
+		d.add()
+
+		t := 0
+		for j, f := range d.header.Fields {
+			if t+f.Count > len(tokens) {
+				return fmt.Errorf("point %d has %d values, expected more", i, len(tokens))
+			}
+
+			for k := 0; k < f.Count; k++ {
+				token := tokens[t]
+				t++
+
+				if d.targets[j] == targetSkip {
+					continue
+				}
+
+				v, err := strconv.ParseFloat(token, 64)
+				if err != nil {
+					return fmt.Errorf("point %d field %q: %w", i, f.Name, err)
+				}
+
+				if d.targets[j] == targetRGB {
+					if f.Type == 'F' {
+						d.setRGB(i, math.Float32bits(float32(v)))
+					} else {
+						d.setRGB(i, uint32(v))
+					}
+					continue
+				}
+
+				d.set(i, j, k, v)
+			}
+		}
+		i++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if i != d.header.Points {
+		return fmt.Errorf("expected %d points, found %d", d.header.Points, i)
+	}
+
+	return nil
+}
+
+func (d *pointDecoder) decodeBinary(r io.Reader) error {
+	size := d.header.pointSize()
+	buf := make([]byte, size)
+
+	for i := 0; i < d.header.Points; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return fmt.Errorf("failed to read point %d: %w", i, err)
+		}
+		d.add()
+		d.decodeRecord(i, buf)
+	}
+
+	return nil
+}
+
+func (d *pointDecoder) decodeCompressed(r io.Reader) error {
+	var sizes [8]byte
+	if _, err := io.ReadFull(r, sizes[:]); err != nil {
+		return fmt.Errorf("failed to read compressed sizes: %w", err)
+	}
+	compressedSize := binary.LittleEndian.Uint32(sizes[0:])
+	uncompressedSize := binary.LittleEndian.Uint32(sizes[4:])
+
+	pointSize := d.header.pointSize()
+	if int(uncompressedSize) != pointSize*d.header.Points {
+		return fmt.Errorf("uncompressed size %d does not match %d points of %d bytes", uncompressedSize, d.header.Points, pointSize)
+	}
+
+	// The buffer grows as data is read, so a size the stream does not hold is never allocated up front.
+	compressed, err := io.ReadAll(io.LimitReader(r, int64(compressedSize)))
+	if err != nil {
+		return fmt.Errorf("failed to read compressed data: %w", err)
+	}
+	if len(compressed) != int(compressedSize) {
+		return fmt.Errorf("failed to read compressed data: %w", io.ErrUnexpectedEOF)
+	}
+
+	fieldMajor, err := lzfDecompress(compressed, int(uncompressedSize))
+	if err != nil {
+		return err
+	}
+
+	// Compressed data stores all values of the first field, then all values of the second, and so on.
+	record := make([]byte, pointSize)
+	for i := 0; i < d.header.Points; i++ {
+		blockStart, offset := 0, 0
+		for _, f := range d.header.Fields {
+			n := f.bytes()
+			copy(record[offset:offset+n], fieldMajor[blockStart+i*n:])
+			blockStart += n * d.header.Points
+			offset += n
+		}
+		d.add()
+		d.decodeRecord(i, record)
+	}
+
+	return nil
+}
+
+// decodeRecord decodes the binary record of point i.
+func (d *pointDecoder) decodeRecord(i int, record []byte) {
+	offset := 0
+	for j, f := range d.header.Fields {
+		switch d.targets[j] {
+		case targetSkip:
+		case targetRGB:
+			if f.Size == 4 {
+				d.setRGB(i, binary.LittleEndian.Uint32(record[offset:]))
+			} else {
+				d.setRGB(i, uint32(f.decode(record[offset:])))
+			}
+		default:
+			for k := 0; k < f.Count; k++ {
+				d.set(i, j, k, f.decode(record[offset+k*f.Size:]))
+			}
+		}
+		offset += f.bytes()
+	}
+}
@@ -0,0 +1,205 @@
+package pcd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Encode writes points to w as a PCD stream. Normals, intensity and color are only written when at least one
+// point has them populated.
+func Encode(w io.Writer, points *point.Points3D, opts *WriteOptions) error {
+	if opts == nil {
+		opts = DefaultWriteOptions
+	}
+
+	s, err := pointSchema(points, opts.Extra)
+	if err != nil {
+		return err
+	}
+
+	h := &Header{
+		Fields:    s.fields,
+		Width:     points.Len(),
+		Height:    1,
+		Viewpoint: DefaultViewpoint,
+		Points:    points.Len(),
+		Format:    opts.Format,
+	}
+	if opts.Viewpoint != nil {
+		h.Viewpoint = *opts.Viewpoint
+	}
+	if opts.Width > 0 {
+		if points.Len()%opts.Width != 0 {
+			return fmt.Errorf("%d points cannot be organized into rows of %d", points.Len(), opts.Width)
+		}
+		h.Width = opts.Width
+		h.Height = points.Len() / opts.Width
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if err := h.write(bw); err != nil {
+		return err
+	}
+
+	switch h.Format {
+	case ASCII:
+		err = s.encodeASCII(bw, points)
+	case Binary:
+		err = s.encodeBinary(bw, points)
+	default:
+		err = s.encodeCompressed(bw, points)
+	}
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// fieldValue returns the value of a field for the ith point.
+type fieldValue func(i int, p *point.Point3D) float64
+
+type schema struct {
+	fields []*Field
+	values []fieldValue
+}
+
+func (s *schema) add(name string, size int, t byte, value fieldValue) {
+	s.fields = append(s.fields, &Field{Name: name, Size: size, Type: t, Count: 1})
+	s.values = append(s.values, value)
+}
+
+// pointSchema builds the fields for the populated attributes of points.
+func pointSchema(points *point.Points3D, extra map[string][]float64) (*schema, error) {
+	s := &schema{}
+
+	s.add("x", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.X })
+	s.add("y", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Y })
+	s.add("z", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Z })
+
+	attributes := points.Attributes()
+
+	if attributes.Normals {
+		s.add("normal_x", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Nx })
+		s.add("normal_y", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Ny })
+		s.add("normal_z", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Nz })
+	}
+	if attributes.Intensity {
+		s.add("intensity", 4, 'F', func(_ int, p *point.Point3D) float64 { return p.Intensity })
+	}
+	if attributes.Color {
+		// PCL packs colors into the bits of a float.
+		s.add("rgb", 4, 'F', func(_ int, p *point.Point3D) float64 {
+			return float64(math.Float32frombits(uint32(p.R)<<16 | uint32(p.G)<<8 | uint32(p.B)))
+		})
+	}
+	if attributes.Time {
+		s.add("time", 8, 'F', func(_ int, p *point.Point3D) float64 { return p.Time })
+	}
+	if attributes.Ring {
+		s.add("ring", 2, 'U', func(_ int, p *point.Point3D) float64 { return float64(p.Ring) })
+	}
+	if attributes.Label {
+		s.add("label", 4, 'I', func(_ int, p *point.Point3D) float64 { return float64(p.Label) })
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := extra[name]
+		if len(values) != points.Len() {
+			return nil, fmt.Errorf("extra field %q has %d values for %d points", name, len(values), points.Len())
+		}
+		if _, ok := targets[name]; ok {
+			return nil, fmt.Errorf("extra field %q clashes with a point attribute", name)
+		}
+		s.add(name, 4, 'F', func(i int, _ *point.Point3D) float64 { return values[i] })
+	}
+
+	return s, nil
+}
+
+func (s *schema) encodeASCII(w *bufio.Writer, points *point.Points3D) error {
+	var buf []byte
+	for i, p := range points.Raw() {
+		buf = buf[:0]
+		for j, f := range s.fields {
+			if j > 0 {
+				buf = append(buf, ' ')
+			}
+			bitSize := 64
+			if f.Type == 'F' && f.Size == 4 {
+				bitSize = 32
+			}
+			buf = strconv.AppendFloat(buf, s.values[j](i, p), 'g', -1, bitSize)
+		}
+		buf = append(buf, '\n')
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *schema) encodeBinary(w *bufio.Writer, points *point.Points3D) error {
+	size := 0
+	for _, f := range s.fields {
+		size += f.bytes()
+	}
+
+	record := make([]byte, size)
+	for i, p := range points.Raw() {
+		offset := 0
+		for j, f := range s.fields {
+			f.encode(record[offset:], s.values[j](i, p))
+			offset += f.bytes()
+		}
+		if _, err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *schema) encodeCompressed(w *bufio.Writer, points *point.Points3D) error {
+	n := points.Len()
+
+	size := 0
+	for _, f := range s.fields {
+		size += f.bytes()
+	}
+
+	// Store all values of the first field, then all values of the second, and so on.
+	fieldMajor := make([]byte, size*n)
+	blockStart := 0
+	for j, f := range s.fields {
+		for i, p := range points.Raw() {
+			f.encode(fieldMajor[blockStart+i*f.bytes():], s.values[j](i, p))
+		}
+		blockStart += f.bytes() * n
+	}
+
+	compressed := lzfCompress(fieldMajor)
+
+	var sizes [8]byte
+	binary.LittleEndian.PutUint32(sizes[0:], uint32(len(compressed)))
+	binary.LittleEndian.PutUint32(sizes[4:], uint32(len(fieldMajor)))
+
+	if _, err := w.Write(sizes[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(compressed)
+	return err
+}
@@ -0,0 +1,389 @@
+package pcd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format is the encoding of the DATA section of a PCD file.
+type Format int
+
+const (
+	ASCII Format = iota
+	Binary
+	BinaryCompressed
+)
+
+func (f Format) String() string {
+	switch f {
+	case ASCII:
+		return "ascii"
+	case Binary:
+		return "binary"
+	case BinaryCompressed:
+		return "binary_compressed"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+func parseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "ascii":
+		return ASCII, nil
+	case "binary":
+		return Binary, nil
+	case "binary_compressed":
+		return BinaryCompressed, nil
+	default:
+		return 0, fmt.Errorf("unknown pcd data format %q", s)
+	}
+}
+
+// Field describes one entry of the FIELDS line together with its SIZE, TYPE and COUNT.
+type Field struct {
+	Name string
+
+	// Size of a single value in bytes: 1, 2, 4 or 8.
+	Size int
+
+	// Type of the values: 'F' for floating point, 'I' for signed and 'U' for unsigned integers.
+	Type byte
+
+	// Number of values per point, e.g. 33 for an FPFH descriptor.
+	Count int
+}
+
+// maxPointSize bounds the bytes of a single point, and so the COUNT of any field. It is well above the largest
+// PCL descriptors, e.g. SHOT1344 with 1344 floats, and stops a corrupt COUNT from sizing allocations.
+const maxPointSize = 1 << 16
+
+// bytes returns the number of bytes the field occupies per point.
+func (f *Field) bytes() int {
+	return f.Size * f.Count
+}
+
+func (f *Field) validate() error {
+	switch f.Type {
+	case 'F':
+		if f.Size != 4 && f.Size != 8 {
+			return fmt.Errorf("field %q has unsupported float size %d", f.Name, f.Size)
+		}
+	case 'I', 'U':
+		if f.Size != 1 && f.Size != 2 && f.Size != 4 && f.Size != 8 {
+			return fmt.Errorf("field %q has unsupported integer size %d", f.Name, f.Size)
+		}
+	default:
+		return fmt.Errorf("field %q has unknown type %q", f.Name, f.Type)
+	}
+	if f.Count < 1 || f.Count > maxPointSize {
+		return fmt.Errorf("field %q has invalid count %d", f.Name, f.Count)
+	}
+	return nil
+}
+
+// decode converts the little-endian binary representation in buf to a float64.
+func (f *Field) decode(buf []byte) float64 {
+	switch f.Type {
+	case 'F':
+		if f.Size == 4 {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf)))
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf))
+	case 'I':
+		switch f.Size {
+		case 1:
+			return float64(int8(buf[0]))
+		case 2:
+			return float64(int16(binary.LittleEndian.Uint16(buf)))
+		case 4:
+			return float64(int32(binary.LittleEndian.Uint32(buf)))
+		default:
+			return float64(int64(binary.LittleEndian.Uint64(buf)))
+		}
+	default:
+		switch f.Size {
+		case 1:
+			return float64(buf[0])
+		case 2:
+			return float64(binary.LittleEndian.Uint16(buf))
+		case 4:
+			return float64(binary.LittleEndian.Uint32(buf))
+		default:
+			return float64(binary.LittleEndian.Uint64(buf))
+		}
+	}
+}
+
+// encode writes v to buf using the little-endian binary representation of the field.
+func (f *Field) encode(buf []byte, v float64) {
+	switch f.Type {
+	case 'F':
+		if f.Size == 4 {
+			binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(v)))
+		} else {
+			binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+		}
+	case 'I':
+		putInteger(buf, f.Size, uint64(int64(v)))
+	default:
+		putInteger(buf, f.Size, uint64(v))
+	}
+}
+
+func putInteger(buf []byte, size int, v uint64) {
+	switch size {
+	case 1:
+		buf[0] = byte(v)
+	case 2:
+		binary.LittleEndian.PutUint16(buf, uint16(v))
+	case 4:
+		binary.LittleEndian.PutUint32(buf, uint32(v))
+	default:
+		binary.LittleEndian.PutUint64(buf, v)
+	}
+}
+
+// Header is the parsed header of a PCD file.
+type Header struct {
+	Version string
+	Fields  []*Field
+
+	// Organized clouds have Height > 1 and store points row by row, Width points per row.
+	Width  int
+	Height int
+
+	// Sensor acquisition pose as tx ty tz qw qx qy qz.
+	Viewpoint [7]float64
+
+	Points int
+	Format Format
+}
+
+// DefaultViewpoint is the identity sensor pose.
+var DefaultViewpoint = [7]float64{0, 0, 0, 1, 0, 0, 0}
+
+// Field returns the index of the named field, or -1 if the header does not have it.
+func (h *Header) Field(name string) int {
+	for i, f := range h.Fields {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsOrganized reports whether the cloud is an image-like grid of points.
+func (h *Header) IsOrganized() bool {
+	return h.Height > 1
+}
+
+// pointSize returns the number of bytes a single point occupies in a binary body.
+func (h *Header) pointSize() int {
+	size := 0
+	for _, f := range h.Fields {
+		size += f.bytes()
+	}
+	return size
+}
+
+// readHeader parses the header of a PCD file, leaving r positioned at the start of the data.
+func readHeader(r *bufio.Reader) (*Header, error) {
+	h := &Header{Height: 1, Viewpoint: DefaultViewpoint, Points: -1}
+
+	var sizes, counts []int
+	var types []byte
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return nil, errors.New("incorrect header; missing DATA")
+			}
+			return nil, err
+		}
+
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		key, values := strings.ToUpper(fields[0]), fields[1:]
+
+		switch key {
+		case "VERSION":
+			if len(values) > 0 {
+				h.Version = values[0]
+			}
+		case "FIELDS", "COLUMNS":
+			h.Fields = make([]*Field, len(values))
+			for i, name := range values {
+				h.Fields[i] = &Field{Name: name, Count: 1}
+			}
+		case "SIZE":
+			if sizes, err = parseInts(values); err != nil {
+				return nil, fmt.Errorf("invalid SIZE: %w", err)
+			}
+		case "TYPE":
+			types = make([]byte, len(values))
+			for i, t := range values {
+				if len(t) != 1 {
+					return nil, fmt.Errorf("invalid TYPE %q", t)
+				}
+				types[i] = strings.ToUpper(t)[0]
+			}
+		case "COUNT":
+			if counts, err = parseInts(values); err != nil {
+				return nil, fmt.Errorf("invalid COUNT: %w", err)
+			}
+		case "WIDTH":
+			if h.Width, err = parseSingleInt(values); err != nil {
+				return nil, fmt.Errorf("invalid WIDTH: %w", err)
+			}
+		case "HEIGHT":
+			if h.Height, err = parseSingleInt(values); err != nil {
+				return nil, fmt.Errorf("invalid HEIGHT: %w", err)
+			}
+		case "VIEWPOINT":
+			if len(values) != 7 {
+				return nil, fmt.Errorf("VIEWPOINT needs 7 values, got %d", len(values))
+			}
+			for i, v := range values {
+				if h.Viewpoint[i], err = strconv.ParseFloat(v, 64); err != nil {
+					return nil, fmt.Errorf("invalid VIEWPOINT: %w", err)
+				}
+			}
+		case "POINTS":
+			if h.Points, err = parseSingleInt(values); err != nil {
+				return nil, fmt.Errorf("invalid POINTS: %w", err)
+			}
+		case "DATA":
+			if len(values) != 1 {
+				return nil, errors.New("malformed DATA line")
+			}
+			if h.Format, err = parseFormat(values[0]); err != nil {
+				return nil, err
+			}
+			if err := h.finish(sizes, types, counts); err != nil {
+				return nil, err
+			}
+			return h, nil
+		default:
+			return nil, fmt.Errorf("unknown header keyword %q", fields[0])
+		}
+	}
+}
+
+// finish combines the per-field header lines and validates the header.
+func (h *Header) finish(sizes []int, types []byte, counts []int) error {
+	if len(h.Fields) == 0 {
+		return errors.New("incorrect header; missing FIELDS")
+	}
+	if len(sizes) != len(h.Fields) || len(types) != len(h.Fields) {
+		return errors.New("SIZE and TYPE must have one entry per field")
+	}
+	if counts != nil && len(counts) != len(h.Fields) {
+		return errors.New("COUNT must have one entry per field")
+	}
+
+	for i, f := range h.Fields {
+		f.Size = sizes[i]
+		f.Type = types[i]
+		if counts != nil {
+			f.Count = counts[i]
+		}
+		if err := f.validate(); err != nil {
+			return err
+		}
+	}
+	if size := h.pointSize(); size > maxPointSize {
+		return fmt.Errorf("points of %d bytes are too large", size)
+	}
+
+	if h.Points < 0 {
+		h.Points = h.Width * h.Height
+	}
+	if h.Width == 0 && h.Height == 1 {
+		h.Width = h.Points
+	}
+	if h.Width*h.Height != h.Points {
+		return fmt.Errorf("WIDTH x HEIGHT (%d x %d) does not match POINTS %d", h.Width, h.Height, h.Points)
+	}
+	return nil
+}
+
+// write writes the header, including the DATA line.
+func (h *Header) write(w *bufio.Writer) error {
+	version := h.Version
+	if version == "" {
+		version = "0.7"
+	}
+
+	fmt.Fprintf(w, "# .PCD v%s - Point Cloud Data file format\n", version)
+	fmt.Fprintf(w, "VERSION %s\n", version)
+
+	w.WriteString("FIELDS")
+	for _, f := range h.Fields {
+		fmt.Fprintf(w, " %s", f.Name)
+	}
+	w.WriteString("\nSIZE")
+	for _, f := range h.Fields {
+		fmt.Fprintf(w, " %d", f.Size)
+	}
+	w.WriteString("\nTYPE")
+	for _, f := range h.Fields {
+		fmt.Fprintf(w, " %c", f.Type)
+	}
+	w.WriteString("\nCOUNT")
+	for _, f := range h.Fields {
+		fmt.Fprintf(w, " %d", f.Count)
+	}
+	w.WriteString("\n")
+
+	fmt.Fprintf(w, "WIDTH %d\nHEIGHT %d\n", h.Width, h.Height)
+
+	w.WriteString("VIEWPOINT")
+	for _, v := range h.Viewpoint {
+		fmt.Fprintf(w, " %s", strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	w.WriteString("\n")
+
+	fmt.Fprintf(w, "POINTS %d\n", h.Points)
+	_, err := fmt.Fprintf(w, "DATA %v\n", h.Format)
+	return err
+}
+
+func parseInts(values []string) ([]int, error) {
+	ints := make([]int, len(values))
+	for i, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, err
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+func parseSingleInt(values []string) (int, error) {
+	if len(values) != 1 {
+		return 0, errors.New("expected a single value")
+	}
+	n, err := strconv.Atoi(values[0])
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative value %d", n)
+	}
+	return n, nil
+}
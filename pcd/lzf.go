@@ -0,0 +1,124 @@
+package pcd
+
+import "errors"
+
+// LZF is the compression used by the binary_compressed DATA format.
+// See http://oldhome.schmorp.de/marc/liblzf.html for a description of the format.
+
+const (
+	lzfMaxLiteral = 32
+	lzfMaxOffset  = 1 << 13
+	lzfMaxMatch   = 264
+	lzfHashLog    = 14
+)
+
+var errLZFCorrupt = errors.New("corrupt lzf data")
+
+// lzfDecompress decompresses in into a buffer of exactly size bytes.
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	// The longest back reference takes three bytes, so no stream expands further than this.
+	if size > len(in)*lzfMaxMatch/3 {
+		return nil, errLZFCorrupt
+	}
+
+	out := make([]byte, 0, size)
+
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+
+		if ctrl < 32 {
+			// Literal run of ctrl + 1 bytes.
+			n := ctrl + 1
+			if ip+n > len(in) || len(out)+n > size {
+				return nil, errLZFCorrupt
+			}
+			out = append(out, in[ip:ip+n]...)
+			ip += n
+			continue
+		}
+
+		// Back reference.
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errLZFCorrupt
+			}
+			n += int(in[ip])
+			ip++
+		}
+		n += 2
+
+		if ip >= len(in) {
+			return nil, errLZFCorrupt
+		}
+		ref := len(out) - ((ctrl&0x1f)<<8 | int(in[ip])) - 1
+		ip++
+
+		if ref < 0 || len(out)+n > size {
+			return nil, errLZFCorrupt
+		}
+
+		// Byte by byte as the reference may overlap the output being written.
+		for i := 0; i < n; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+
+	if len(out) != size {
+		return nil, errLZFCorrupt
+	}
+
+	return out, nil
+}
+
+// lzfCompress compresses in using a single-entry hash table of previous 3 byte sequences.
+func lzfCompress(in []byte) []byte {
+	out := make([]byte, 0, len(in)+len(in)/lzfMaxLiteral+1)
+
+	var table [1 << lzfHashLog]int // Position + 1 of the last occurrence of each hash.
+
+	literalStart := 0
+	flushLiterals := func(end int) {
+		for literalStart < end {
+			n := min(end-literalStart, lzfMaxLiteral)
+			out = append(out, byte(n-1))
+			out = append(out, in[literalStart:literalStart+n]...)
+			literalStart += n
+		}
+	}
+
+	for i := 0; i+2 < len(in); {
+		seq := uint32(in[i])<<16 | uint32(in[i+1])<<8 | uint32(in[i+2])
+		h := (seq * 2654435761) >> (32 - lzfHashLog)
+
+		ref := table[h] - 1
+		table[h] = i + 1
+
+		offset := i - ref - 1
+		if ref < 0 || offset >= lzfMaxOffset || in[ref] != in[i] || in[ref+1] != in[i+1] || in[ref+2] != in[i+2] {
+			i++
+			continue
+		}
+
+		n := 3
+		for maxMatch := min(len(in)-i, lzfMaxMatch); n < maxMatch && in[ref+n] == in[i+n]; n++ {
+		}
+
+		flushLiterals(i)
+
+		encoded := n - 2
+		if encoded < 7 {
+			out = append(out, byte(encoded<<5|offset>>8), byte(offset))
+		} else {
+			out = append(out, byte(7<<5|offset>>8), byte(encoded-7), byte(offset))
+		}
+
+		i += n
+		literalStart = i
+	}
+
+	flushLiterals(len(in))
+
+	return out
+}
@@ -0,0 +1,82 @@
+package pcd
+
+import (
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+type ReadOptions struct {
+	// Cropping applied to the points once read. The zero value keeps every finite point.
+	point.CropOptions
+}
+
+// DefaultReadOptions returns every finite point in the file unmodified.
+var DefaultReadOptions *ReadOptions = &ReadOptions{}
+
+type WriteOptions struct {
+	// Encoding used for the DATA section of the written file.
+	Format Format `json:"format"`
+
+	// Number of points per row of an organized cloud. Zero writes an unorganized cloud with HEIGHT 1.
+	Width int `json:"width"`
+
+	// Sensor acquisition pose as tx ty tz qw qx qy qz. Nil writes the identity pose.
+	Viewpoint *[7]float64 `json:"viewpoint"`
+
+	// Additional per-point scalar fields to write, keyed by field name. Each slice must hold one value per point.
+	Extra map[string][]float64 `json:"-"`
+}
+
+var DefaultWriteOptions *WriteOptions = &WriteOptions{
+	Format: Binary,
+}
+
+// Read reads the finite points of a PCD file and crops them according to opts.
+func Read(filePath string, opts *ReadOptions) (*point.Points3D, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file, opts)
+}
+
+// ReadCloud reads the finite points of a PCD file into a point.Cloud and crops them according to opts.
+func ReadCloud(filePath string, opts *ReadOptions) (*point.Cloud, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeCloud(file, opts)
+}
+
+// ReadAll reads every point of a PCD file, including invalid points of organized clouds and fields without a
+// point.Point3D field.
+func ReadAll(filePath string) (*Data, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeAll(file)
+}
+
+// Write writes points to a PCD file, replacing any existing file.
+func Write(filePath string, points *point.Points3D, opts *WriteOptions) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := Encode(file, points, opts); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,289 @@
+package pcd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+var formats = []Format{ASCII, Binary, BinaryCompressed}
+
+// float32Points returns points with every attribute populated. Values are exact in float32 so that they survive
+// the 4 byte fields Encode writes, apart from times, which Encode writes as 8 byte fields.
+func float32Points() *point.Points3D {
+	points := point.Points3D{
+		{X: 1, Y: 2, Z: 3, Nx: 0, Ny: 0, Nz: 1, Intensity: 10, R: 255, G: 128, B: 1, Time: 1.5e9 + 0.25, Ring: 3, Label: -2},
+		{X: -1.5, Y: 0.25, Z: 8, Nx: 1, Ny: 0, Nz: 0, Intensity: 0.5, R: 0, G: 0, B: 7, Time: 1.5e9 + 0.5, Ring: 0, Label: 7},
+		{X: 0, Y: -4, Z: 0.125, Nx: 0, Ny: -1, Nz: 0, Intensity: 0, R: 12, G: 34, B: 56, Time: 1.5e9 + 0.75, Ring: 31, Label: 0},
+	}
+	return &points
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	extra := map[string][]float64{
+		"curvature": {0.5, 0.25, 0},
+		"reflector": {1, 0, 1},
+	}
+
+	for _, format := range formats {
+		t.Run(format.String(), func(t *testing.T) {
+			points := float32Points()
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, points, &WriteOptions{Format: format, Extra: extra}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			data, err := DecodeAll(&buf)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			if data.Header.Format != format {
+				t.Errorf("got format %v, want %v", data.Header.Format, format)
+			}
+			if data.Header.Width != points.Len() || data.Header.Height != 1 {
+				t.Errorf("got %dx%d cloud, want %dx1", data.Header.Width, data.Header.Height, points.Len())
+			}
+			for i, p := range data.Points.Raw() {
+				if want := points.Raw()[i]; *p != *want {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, *want)
+				}
+			}
+			if !reflect.DeepEqual(data.Extra, extra) {
+				t.Errorf("got extra %v, want %v", data.Extra, extra)
+			}
+		})
+	}
+}
+
+func TestEncodeOrganized(t *testing.T) {
+	points := float32Points()
+	*points = append(*points, &point.Point3D{X: 1, Y: 1, Z: 1})
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, points, &WriteOptions{Format: Binary, Width: 2}); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	data, err := DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+	if !data.Header.IsOrganized() || data.Header.Width != 2 || data.Header.Height != 2 {
+		t.Errorf("got %dx%d cloud, want 2x2", data.Header.Width, data.Header.Height)
+	}
+
+	if err := Encode(&buf, points, &WriteOptions{Format: Binary, Width: 3}); err == nil {
+		t.Errorf("got no error organizing 4 points into rows of 3")
+	}
+}
+
+// writeFile encodes records, one row of values per field value, under a header with the given fields.
+func writeFile(t *testing.T, format Format, fields []*Field, records [][]float64) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	h := &Header{Fields: fields, Width: len(records), Height: 1, Viewpoint: DefaultViewpoint, Points: len(records), Format: format}
+	if err := h.write(w); err != nil {
+		t.Fatalf("writing header: %v", err)
+	}
+
+	size := h.pointSize()
+	body := make([]byte, size*len(records))
+
+	switch format {
+	case ASCII:
+		for _, record := range records {
+			values := make([]string, len(record))
+			for k, v := range record {
+				values[k] = strconv.FormatFloat(v, 'g', -1, 64)
+			}
+			w.WriteString(strings.Join(values, " ") + "\n")
+		}
+	case Binary:
+		for i, record := range records {
+			offset, k := i*size, 0
+			for _, f := range fields {
+				for c := 0; c < f.Count; c++ {
+					f.encode(body[offset:], record[k])
+					offset += f.Size
+					k++
+				}
+			}
+		}
+		w.Write(body)
+	default:
+		blockStart, k := 0, 0
+		for _, f := range fields {
+			for i, record := range records {
+				for c := 0; c < f.Count; c++ {
+					f.encode(body[blockStart+i*f.bytes()+c*f.Size:], record[k+c])
+				}
+			}
+			blockStart += f.bytes() * len(records)
+			k += f.Count
+		}
+		compressed := lzfCompress(body)
+		w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(compressed))))
+		w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(body))))
+		w.Write(compressed)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatalf("flushing: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeCountAboveOneAndPackedRGB(t *testing.T) {
+	fields := []*Field{
+		{Name: "x", Size: 4, Type: 'F', Count: 1},
+		{Name: "y", Size: 4, Type: 'F', Count: 1},
+		{Name: "z", Size: 4, Type: 'F', Count: 1},
+		{Name: "_", Size: 1, Type: 'U', Count: 4},
+		{Name: "rgba", Size: 4, Type: 'U', Count: 1},
+		{Name: "histogram", Size: 4, Type: 'F', Count: 3},
+		{Name: "normal_x", Size: 4, Type: 'F', Count: 2}, // A COUNT above one is not a point attribute.
+	}
+	records := [][]float64{
+		{1, 2, 3, 0, 0, 0, 0, 0xff102030, 0.5, 0.25, 0.125, 4, 5},
+		{-1, -2, -3, 0, 0, 0, 0, 0x00ffffff, 1, 2, 3, 6, 7},
+	}
+
+	wantPoints := []point.Point3D{
+		{X: 1, Y: 2, Z: 3, R: 0x10, G: 0x20, B: 0x30},
+		{X: -1, Y: -2, Z: -3, R: 0xff, G: 0xff, B: 0xff},
+	}
+	wantExtra := map[string][]float64{
+		"histogram_0": {0.5, 1},
+		"histogram_1": {0.25, 2},
+		"histogram_2": {0.125, 3},
+		"normal_x_0":  {4, 6},
+		"normal_x_1":  {5, 7},
+	}
+
+	for _, format := range formats {
+		t.Run(format.String(), func(t *testing.T) {
+			data, err := DecodeAll(bytes.NewReader(writeFile(t, format, fields, records)))
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			if data.Points.Len() != len(wantPoints) {
+				t.Fatalf("got %d points, want %d", data.Points.Len(), len(wantPoints))
+			}
+			for i, p := range data.Points.Raw() {
+				if *p != wantPoints[i] {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, wantPoints[i])
+				}
+			}
+			if !reflect.DeepEqual(data.Extra, wantExtra) {
+				t.Errorf("got extra %v, want %v", data.Extra, wantExtra)
+			}
+		})
+	}
+}
+
+func TestDecodeCountAboveOneReEncodes(t *testing.T) {
+	fields := []*Field{
+		{Name: "x", Size: 4, Type: 'F', Count: 1},
+		{Name: "y", Size: 4, Type: 'F', Count: 1},
+		{Name: "z", Size: 4, Type: 'F', Count: 1},
+		{Name: "rgb", Size: 4, Type: 'F', Count: 1},
+		{Name: "fpfh", Size: 4, Type: 'F', Count: 2},
+	}
+	var rgb [4]byte
+	binary.LittleEndian.PutUint32(rgb[:], 0x00804020)
+	packed := (&Field{Size: 4, Type: 'F', Count: 1}).decode(rgb[:])
+
+	data, err := DecodeAll(bytes.NewReader(writeFile(t, Binary, fields, [][]float64{{1, 2, 3, packed, 0.5, 1.5}})))
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	for _, format := range formats {
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, data.Points, &WriteOptions{Format: format, Extra: data.Extra}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			again, err := DecodeAll(&buf)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			want := point.Point3D{X: 1, Y: 2, Z: 3, R: 0x80, G: 0x40, B: 0x20}
+			if p := again.Points.Raw()[0]; *p != want {
+				t.Errorf("got %+v, want %+v", *p, want)
+			}
+			if !reflect.DeepEqual(again.Extra, data.Extra) {
+				t.Errorf("got extra %v, want %v", again.Extra, data.Extra)
+			}
+		})
+	}
+}
+
+func TestLZFRoundTrip(t *testing.T) {
+	random := make([]byte, 20000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	// Repeats further apart than the largest back reference offset.
+	distant := append(append([]byte{}, random[:lzfMaxOffset+100]...), random[:500]...)
+
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{name: "empty", in: nil},
+		{name: "short", in: []byte("ab")},
+		{name: "random", in: random},
+		{name: "run longer than a match", in: bytes.Repeat([]byte{7}, 3*lzfMaxMatch+5)},
+		{name: "repeated phrase", in: bytes.Repeat([]byte("point cloud "), 1000)},
+		{name: "distant repeat", in: distant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed := lzfCompress(tt.in)
+			out, err := lzfDecompress(compressed, len(tt.in))
+			if err != nil {
+				t.Fatalf("lzfDecompress: %v", err)
+			}
+			if !bytes.Equal(out, tt.in) {
+				t.Errorf("round trip of %d bytes differs", len(tt.in))
+			}
+		})
+	}
+}
+
+func TestLZFCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		size int
+	}{
+		{name: "literal past the input", in: []byte{4, 'a', 'b'}, size: 5},
+		{name: "reference before the output", in: []byte{0, 'a', 1 << 5, 5}, size: 4},
+		{name: "output shorter than size", in: []byte{1, 'a', 'b'}, size: 3},
+		{name: "size beyond any expansion", in: []byte{0, 'a'}, size: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := lzfDecompress(tt.in, tt.size); err != errLZFCorrupt {
+				t.Errorf("got error %v, want %v", err, errLZFCorrupt)
+			}
+		})
+	}
+}
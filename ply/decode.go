@@ -0,0 +1,518 @@
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Data is the decoded content of a PLY file.
+type Data struct {
+	Header *Header
+
+	// Points holds the vertex element mapped onto point.Point3D.
+	Points *point.Points3D
+
+	// Extra holds the values of vertex properties that have no point.Point3D field, keyed by property name.
+	// Each slice has one value per vertex.
+	Extra map[string][]float64
+
+	// Elements holds the entries of every non-vertex element, keyed by element name.
+	Elements map[string]*ElementData
+}
+
+// ElementData holds the decoded entries of a non-vertex element.
+// Values[i][j] holds property j of entry i: a single value for scalar properties and one value per item for lists.
+type ElementData struct {
+	Element *Element
+	Values  [][][]float64
+}
+
+// vertexField identifies the point.Point3D field a vertex property maps onto.
+type vertexField int
+
+const (
+	fieldNone vertexField = iota
+	fieldX
+	fieldY
+	fieldZ
+	fieldNx
+	fieldNy
+	fieldNz
+	fieldIntensity
+	fieldRed
+	fieldGreen
+	fieldBlue
+	fieldTime
+	fieldRing
+	fieldLabel
+)
+
+// vertexFields maps the property names written by common tools onto point.Point3D fields.
+var vertexFields = map[string]vertexField{
+	"x":              fieldX,
+	"y":              fieldY,
+	"z":              fieldZ,
+	"nx":             fieldNx,
+	"ny":             fieldNy,
+	"nz":             fieldNz,
+	"normal_x":       fieldNx,
+	"normal_y":       fieldNy,
+	"normal_z":       fieldNz,
+	"intensity":      fieldIntensity,
+	"red":            fieldRed,
+	"green":          fieldGreen,
+	"blue":           fieldBlue,
+	"diffuse_red":    fieldRed,
+	"diffuse_green":  fieldGreen,
+	"diffuse_blue":   fieldBlue,
+	"time":           fieldTime,
+	"timestamp":      fieldTime,
+	"gps_time":       fieldTime,
+	"ring":           fieldRing,
+	"label":          fieldLabel,
+	"class":          fieldLabel,
+	"classification": fieldLabel,
+}
+
+// Decode reads the vertices of a PLY stream and crops them according to opts.
+func Decode(r io.Reader, opts *ReadOptions) (*point.Points3D, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	data, err := DecodeAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Points.Crop(&opts.CropOptions), nil
+}
+
+// DecodeCloud streams the vertices of a PLY stream into a point.Cloud, cropping them according to opts.
+// Unlike Decode, no point is allocated individually.
+func DecodeCloud(r io.Reader, opts *ReadOptions) (*point.Cloud, error) {
+	var cloud *point.Cloud
+
+	err := streamVertices(r, opts, func(count int, attributes point.Attributes) {
+		cloud = point.NewCloud(count, attributes)
+	}, func(p *point.Point3D) {
+		cloud.Append(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cloud, nil
+}
+
+// DecodeCloud32 streams the vertices of a PLY stream into a float32 point.Cloud32, cropping them according to
+// opts.
+func DecodeCloud32(r io.Reader, opts *ReadOptions) (*point.Cloud32, error) {
+	var cloud *point.Cloud32
+
+	err := streamVertices(r, opts, func(count int, attributes point.Attributes) {
+		cloud = point.NewCloud32(count, attributes)
+	}, func(p *point.Point3D) {
+		cloud.Append(p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return cloud, nil
+}
+
+// streamVertices decodes each vertex into the same point, passing those kept by the crop options to add.
+// start is called once the header is read with the capacity to reserve and the attributes the properties
+// populate.
+func streamVertices(r io.Reader, opts *ReadOptions, start func(count int, attributes point.Attributes), add func(p *point.Point3D)) error {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	s, err := NewScanner(r)
+	if err != nil {
+		return err
+	}
+
+	start(preallocSize(s.vertex.element.Count), s.vertex.attributes())
+
+	var p point.Point3D
+	for i := 0; i < s.vertex.element.Count; i++ {
+		p = point.Point3D{}
+		if err := s.vertex.decode(&p, nil); err != nil {
+			return fmt.Errorf("failed to read vertex %d: %w", i, err)
+		}
+		if opts.Accept(&p) {
+			add(&p)
+		}
+	}
+
+	return nil
+}
+
+// DecodeAll reads every element of a PLY stream.
+func DecodeAll(r io.Reader) (*Data, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Data{
+		Header:   h,
+		Extra:    make(map[string][]float64),
+		Elements: make(map[string]*ElementData),
+	}
+
+	body := newValueReader(br, h.Format)
+
+	for _, e := range h.Elements {
+		if e.Name == "vertex" {
+			points, err := decodeVertices(body, e, data.Extra)
+			if err != nil {
+				return nil, err
+			}
+			data.Points = points
+			continue
+		}
+
+		elementData, err := decodeElement(body, e)
+		if err != nil {
+			return nil, err
+		}
+		data.Elements[e.Name] = elementData
+	}
+
+	if data.Points == nil {
+		return nil, errors.New("missing vertex element")
+	}
+
+	return data, nil
+}
+
+// Scanner streams the vertices of a PLY file one point at a time without holding the whole cloud in memory.
+// Non-vertex elements preceding the vertices are skipped; anything after them is never read.
+type Scanner struct {
+	header  *Header
+	vertex  *vertexDecoder
+	read    int
+	current *point.Point3D
+	err     error
+}
+
+// NewScanner reads the header from r and prepares to stream its vertices.
+func NewScanner(r io.Reader) (*Scanner, error) {
+	br := bufio.NewReader(r)
+
+	h, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	body := newValueReader(br, h.Format)
+
+	for _, e := range h.Elements {
+		if e.Name != "vertex" {
+			if _, err := decodeElement(body, e); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		vertex, err := newVertexDecoder(body, e)
+		if err != nil {
+			return nil, err
+		}
+		return &Scanner{header: h, vertex: vertex}, nil
+	}
+
+	return nil, errors.New("missing vertex element")
+}
+
+// Header returns the parsed header of the stream.
+func (s *Scanner) Header() *Header {
+	return s.header
+}
+
+// Scan advances to the next point, returning false once every vertex has been read or an error occurs.
+func (s *Scanner) Scan() bool {
+	if s.err != nil || s.read >= s.vertex.element.Count {
+		return false
+	}
+
+	p := &point.Point3D{}
+	if err := s.vertex.decode(p, nil); err != nil {
+		s.err = fmt.Errorf("failed to read vertex %d: %w", s.read, err)
+		return false
+	}
+
+	s.read++
+	s.current = p
+	return true
+}
+
+// Point returns the point read by the last call to Scan. Each call to Scan allocates a new point.
+func (s *Scanner) Point() *point.Point3D {
+	return s.current
+}
+
+// Err returns the first error encountered while scanning.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// vertexDecoder decodes vertex entries onto point.Point3D fields.
+type vertexDecoder struct {
+	body    valueReader
+	element *Element
+	fields  []vertexField
+}
+
+func newVertexDecoder(body valueReader, e *Element) (*vertexDecoder, error) {
+	for _, name := range []string{"x", "y", "z"} {
+		if e.Property(name) < 0 {
+			return nil, fmt.Errorf("vertex element is missing property %q", name)
+		}
+	}
+
+	fields := make([]vertexField, len(e.Properties))
+	for i, p := range e.Properties {
+		if !p.IsList {
+			fields[i] = vertexFields[p.Name]
+		}
+	}
+
+	return &vertexDecoder{body: body, element: e, fields: fields}, nil
+}
+
+// attributes reports the point.Point3D attributes the vertex properties populate.
+func (d *vertexDecoder) attributes() point.Attributes {
+	var a point.Attributes
+	for _, f := range d.fields {
+		switch f {
+		case fieldNx, fieldNy, fieldNz:
+			a.Normals = true
+		case fieldIntensity:
+			a.Intensity = true
+		case fieldRed, fieldGreen, fieldBlue:
+			a.Color = true
+		case fieldTime:
+			a.Time = true
+		case fieldRing:
+			a.Ring = true
+		case fieldLabel:
+			a.Label = true
+		}
+	}
+	return a
+}
+
+// decode reads the next vertex into p. Scalar properties without a point.Point3D field are appended to extra
+// when it is not nil.
+func (d *vertexDecoder) decode(p *point.Point3D, extra map[string][]float64) error {
+	for i, prop := range d.element.Properties {
+		if prop.IsList {
+			if _, err := readList(d.body, prop); err != nil {
+				return err
+			}
+			continue
+		}
+
+		v, err := d.body.readValue(prop.Type)
+		if err != nil {
+			return err
+		}
+
+		if d.fields[i] == fieldNone {
+			if extra != nil {
+				extra[prop.Name] = append(extra[prop.Name], v)
+			}
+			continue
+		}
+		setVertexField(p, d.fields[i], v, prop.Type)
+	}
+	return nil
+}
+
+func decodeVertices(body valueReader, e *Element, extra map[string][]float64) (*point.Points3D, error) {
+	d, err := newVertexDecoder(body, e)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, p := range e.Properties {
+		if !p.IsList && d.fields[i] == fieldNone {
+			extra[p.Name] = make([]float64, 0, preallocSize(e.Count))
+		}
+	}
+
+	// Points are allocated in blocks as they are read, so a vertex count the body does not hold is never
+	// allocated up front.
+	points := make(point.Points3D, 0, preallocSize(e.Count))
+	var storage []point.Point3D
+
+	for i := 0; i < e.Count; i++ {
+		if len(storage) == 0 {
+			storage = make([]point.Point3D, preallocSize(e.Count-i))
+		}
+		p := &storage[0]
+		storage = storage[1:]
+
+		if err := d.decode(p, extra); err != nil {
+			return nil, fmt.Errorf("failed to read vertex %d: %w", i, err)
+		}
+		points = append(points, p)
+	}
+
+	return &points, nil
+}
+
+func setVertexField(p *point.Point3D, field vertexField, v float64, t DataType) {
+	switch field {
+	case fieldX:
+		p.X = v
+	case fieldY:
+		p.Y = v
+	case fieldZ:
+		p.Z = v
+	case fieldNx:
+		p.Nx = v
+	case fieldNy:
+		p.Ny = v
+	case fieldNz:
+		p.Nz = v
+	case fieldIntensity:
+		p.Intensity = v
+	case fieldRed:
+		p.R = toColor(v, t)
+	case fieldGreen:
+		p.G = toColor(v, t)
+	case fieldBlue:
+		p.B = toColor(v, t)
+	case fieldTime:
+		p.Time = v
+	case fieldRing:
+		p.Ring = int(v)
+	case fieldLabel:
+		p.Label = int(v)
+	}
+}
+
+// toColor converts a color channel to 8 bits. Floating point channels are expected in [0, 1].
+func toColor(v float64, t DataType) uint8 {
+	if !t.isInteger() {
+		v *= 255
+	}
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+// Counts read from a header or list are not trusted to size allocations: slices start with at most
+// maxPrealloc entries and grow as values are actually read.
+const maxPrealloc = 1 << 16
+
+func preallocSize(count int) int {
+	return min(count, maxPrealloc)
+}
+
+func decodeElement(body valueReader, e *Element) (*ElementData, error) {
+	data := &ElementData{Element: e, Values: make([][][]float64, 0, preallocSize(e.Count))}
+
+	for i := 0; i < e.Count; i++ {
+		entry := make([][]float64, len(e.Properties))
+		for j, prop := range e.Properties {
+			if prop.IsList {
+				values, err := readList(body, prop)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read %s %d: %w", e.Name, i, err)
+				}
+				entry[j] = values
+				continue
+			}
+
+			v, err := body.readValue(prop.Type)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s %d: %w", e.Name, i, err)
+			}
+			entry[j] = []float64{v}
+		}
+		data.Values = append(data.Values, entry)
+	}
+
+	return data, nil
+}
+
+func readList(body valueReader, prop *Property) ([]float64, error) {
+	n, err := body.readValue(prop.CountType)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("negative list length for property %q", prop.Name)
+	}
+
+	values := make([]float64, 0, preallocSize(int(n)))
+	for i := 0; i < int(n); i++ {
+		v, err := body.readValue(prop.Type)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// valueReader reads scalar values from a PLY body.
+type valueReader interface {
+	readValue(t DataType) (float64, error)
+}
+
+func newValueReader(r *bufio.Reader, format Format) valueReader {
+	if format == ASCII {
+		scanner := bufio.NewScanner(r)
+		scanner.Split(bufio.ScanWords)
+		return &asciiReader{scanner: scanner}
+	}
+	return &binaryReader{r: r, order: format.byteOrder()}
+}
+
+type asciiReader struct {
+	scanner *bufio.Scanner
+}
+
+func (a *asciiReader) readValue(t DataType) (float64, error) {
+	if !a.scanner.Scan() {
+		if err := a.scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	return strconv.ParseFloat(a.scanner.Text(), 64)
+}
+
+type binaryReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (b *binaryReader) readValue(t DataType) (float64, error) {
+	buf := b.buf[:t.size()]
+
+	if _, err := io.ReadFull(b.r, buf); err != nil {
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+
+	return t.decode(buf, b.order), nil
+}
@@ -0,0 +1,214 @@
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Encode writes points to w as a PLY stream. Normals, intensity and color are only written when at least one
+// point has them populated.
+func Encode(w io.Writer, points *point.Points3D, opts *WriteOptions) error {
+	if opts == nil {
+		opts = DefaultWriteOptions
+	}
+
+	vertex, err := vertexSchema(points, opts.Extra)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+
+	h := &Header{Format: opts.Format, Elements: []*Element{vertex.element}}
+	if err := writeHeader(bw, h); err != nil {
+		return err
+	}
+
+	body := newValueWriter(bw, opts.Format)
+
+	for i, p := range points.Raw() {
+		for j, prop := range vertex.element.Properties {
+			if err := body.writeValue(prop.Type, vertex.values[j](i, p)); err != nil {
+				return err
+			}
+		}
+		if err := body.endEntry(); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// vertexValue returns the value of a vertex property for the ith point.
+type vertexValue func(i int, p *point.Point3D) float64
+
+type schema struct {
+	element *Element
+	values  []vertexValue
+}
+
+func (s *schema) add(name string, t DataType, value vertexValue) {
+	s.element.Properties = append(s.element.Properties, &Property{Name: name, Type: t})
+	s.values = append(s.values, value)
+}
+
+// vertexSchema builds the vertex element for the populated attributes of points.
+func vertexSchema(points *point.Points3D, extra map[string][]float64) (*schema, error) {
+	s := &schema{element: &Element{Name: "vertex", Count: points.Len()}}
+
+	// Positions are written in double precision so that georeferenced coordinates survive a round trip.
+	s.add("x", Float64, func(_ int, p *point.Point3D) float64 { return p.X })
+	s.add("y", Float64, func(_ int, p *point.Point3D) float64 { return p.Y })
+	s.add("z", Float64, func(_ int, p *point.Point3D) float64 { return p.Z })
+
+	attributes := points.Attributes()
+
+	if attributes.Normals {
+		s.add("nx", Float32, func(_ int, p *point.Point3D) float64 { return p.Nx })
+		s.add("ny", Float32, func(_ int, p *point.Point3D) float64 { return p.Ny })
+		s.add("nz", Float32, func(_ int, p *point.Point3D) float64 { return p.Nz })
+	}
+	if attributes.Intensity {
+		s.add("intensity", Float32, func(_ int, p *point.Point3D) float64 { return p.Intensity })
+	}
+	if attributes.Color {
+		s.add("red", Uint8, func(_ int, p *point.Point3D) float64 { return float64(p.R) })
+		s.add("green", Uint8, func(_ int, p *point.Point3D) float64 { return float64(p.G) })
+		s.add("blue", Uint8, func(_ int, p *point.Point3D) float64 { return float64(p.B) })
+	}
+	if attributes.Time {
+		s.add("time", Float64, func(_ int, p *point.Point3D) float64 { return p.Time })
+	}
+	if attributes.Ring {
+		s.add("ring", Uint16, func(_ int, p *point.Point3D) float64 { return float64(p.Ring) })
+	}
+	if attributes.Label {
+		s.add("label", Int32, func(_ int, p *point.Point3D) float64 { return float64(p.Label) })
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := extra[name]
+		if len(values) != points.Len() {
+			return nil, fmt.Errorf("extra property %q has %d values for %d points", name, len(values), points.Len())
+		}
+		if s.element.Property(name) >= 0 {
+			return nil, fmt.Errorf("extra property %q clashes with a point attribute", name)
+		}
+		s.add(name, Float32, func(i int, _ *point.Point3D) float64 { return values[i] })
+	}
+
+	return s, nil
+}
+
+// writeHeader writes the header of h, including the end_header line.
+func writeHeader(w *bufio.Writer, h *Header) error {
+	fmt.Fprintf(w, "ply\nformat %v 1.0\n", h.Format)
+
+	for _, c := range h.Comments {
+		fmt.Fprintf(w, "comment %s\n", c)
+	}
+
+	for _, e := range h.Elements {
+		fmt.Fprintf(w, "element %s %d\n", e.Name, e.Count)
+		for _, p := range e.Properties {
+			if p.IsList {
+				fmt.Fprintf(w, "property list %v %v %s\n", p.CountType, p.Type, p.Name)
+			} else {
+				fmt.Fprintf(w, "property %v %s\n", p.Type, p.Name)
+			}
+		}
+	}
+
+	_, err := w.WriteString("end_header\n")
+	return err
+}
+
+// valueWriter writes scalar values to a PLY body.
+type valueWriter interface {
+	writeValue(t DataType, v float64) error
+
+	// endEntry terminates the current element entry.
+	endEntry() error
+}
+
+func newValueWriter(w *bufio.Writer, format Format) valueWriter {
+	if format == ASCII {
+		return &asciiWriter{w: w}
+	}
+	return &binaryWriter{w: w, order: format.byteOrder()}
+}
+
+type asciiWriter struct {
+	w       *bufio.Writer
+	started bool
+	buf     []byte
+}
+
+func (a *asciiWriter) writeValue(t DataType, v float64) error {
+	if a.started {
+		if err := a.w.WriteByte(' '); err != nil {
+			return err
+		}
+	}
+	a.started = true
+
+	// Values are printed exactly as held, whatever the precision the property is declared with.
+	switch t {
+	case Float32, Float64:
+		a.buf = strconv.AppendFloat(a.buf[:0], v, 'g', -1, 64)
+	default:
+		a.buf = strconv.AppendInt(a.buf[:0], int64(v), 10)
+	}
+
+	_, err := a.w.Write(a.buf)
+	return err
+}
+
+func (a *asciiWriter) endEntry() error {
+	a.started = false
+	return a.w.WriteByte('\n')
+}
+
+type binaryWriter struct {
+	w     *bufio.Writer
+	order binary.ByteOrder
+	buf   [8]byte
+}
+
+func (b *binaryWriter) writeValue(t DataType, v float64) error {
+	buf := b.buf[:t.size()]
+
+	switch t {
+	case Int8, Uint8:
+		buf[0] = byte(int64(v))
+	case Int16, Uint16:
+		b.order.PutUint16(buf, uint16(int64(v)))
+	case Int32, Uint32:
+		b.order.PutUint32(buf, uint32(int64(v)))
+	case Float32:
+		b.order.PutUint32(buf, math.Float32bits(float32(v)))
+	default:
+		b.order.PutUint64(buf, math.Float64bits(v))
+	}
+
+	_, err := b.w.Write(buf)
+	return err
+}
+
+func (b *binaryWriter) endEntry() error {
+	return nil
+}
@@ -0,0 +1,297 @@
+package ply
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format is the encoding used for the body of a PLY file.
+type Format int
+
+const (
+	ASCII Format = iota
+	BinaryLittleEndian
+	BinaryBigEndian
+)
+
+func (f Format) String() string {
+	switch f {
+	case ASCII:
+		return "ascii"
+	case BinaryLittleEndian:
+		return "binary_little_endian"
+	case BinaryBigEndian:
+		return "binary_big_endian"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+func parseFormat(s string) (Format, error) {
+	switch s {
+	case "ascii":
+		return ASCII, nil
+	case "binary_little_endian":
+		return BinaryLittleEndian, nil
+	case "binary_big_endian":
+		return BinaryBigEndian, nil
+	default:
+		return 0, fmt.Errorf("unknown ply format %q", s)
+	}
+}
+
+// byteOrder returns the byte order of a binary format.
+func (f Format) byteOrder() binary.ByteOrder {
+	if f == BinaryBigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// DataType is the scalar type of a PLY property.
+type DataType int
+
+const (
+	Int8 DataType = iota
+	Uint8
+	Int16
+	Uint16
+	Int32
+	Uint32
+	Float32
+	Float64
+)
+
+func (t DataType) String() string {
+	switch t {
+	case Int8:
+		return "char"
+	case Uint8:
+		return "uchar"
+	case Int16:
+		return "short"
+	case Uint16:
+		return "ushort"
+	case Int32:
+		return "int"
+	case Uint32:
+		return "uint"
+	case Float32:
+		return "float"
+	case Float64:
+		return "double"
+	default:
+		return fmt.Sprintf("DataType(%d)", int(t))
+	}
+}
+
+// isInteger reports whether the type holds integral values.
+func (t DataType) isInteger() bool {
+	return t != Float32 && t != Float64
+}
+
+func parseDataType(s string) (DataType, error) {
+	switch s {
+	case "char", "int8":
+		return Int8, nil
+	case "uchar", "uint8":
+		return Uint8, nil
+	case "short", "int16":
+		return Int16, nil
+	case "ushort", "uint16":
+		return Uint16, nil
+	case "int", "int32":
+		return Int32, nil
+	case "uint", "uint32":
+		return Uint32, nil
+	case "float", "float32":
+		return Float32, nil
+	case "double", "float64":
+		return Float64, nil
+	default:
+		return 0, fmt.Errorf("unknown ply property type %q", s)
+	}
+}
+
+// size returns the number of bytes the type occupies in a binary body.
+func (t DataType) size() int {
+	switch t {
+	case Int8, Uint8:
+		return 1
+	case Int16, Uint16:
+		return 2
+	case Int32, Uint32, Float32:
+		return 4
+	default:
+		return 8
+	}
+}
+
+// decode converts the binary representation in buf to a float64.
+func (t DataType) decode(buf []byte, order binary.ByteOrder) float64 {
+	switch t {
+	case Int8:
+		return float64(int8(buf[0]))
+	case Uint8:
+		return float64(buf[0])
+	case Int16:
+		return float64(int16(order.Uint16(buf)))
+	case Uint16:
+		return float64(order.Uint16(buf))
+	case Int32:
+		return float64(int32(order.Uint32(buf)))
+	case Uint32:
+		return float64(order.Uint32(buf))
+	case Float32:
+		return float64(math.Float32frombits(order.Uint32(buf)))
+	default:
+		return math.Float64frombits(order.Uint64(buf))
+	}
+}
+
+// Property describes a single property of a PLY element.
+type Property struct {
+	Name string
+	Type DataType
+
+	// List properties hold a variable number of Type values prefixed by a CountType length.
+	IsList    bool
+	CountType DataType
+}
+
+// Element describes a group of entries sharing the same properties, e.g. vertex or face.
+type Element struct {
+	Name       string
+	Count      int
+	Properties []*Property
+}
+
+// Property returns the index of the named property, or -1 if the element does not have it.
+func (e *Element) Property(name string) int {
+	for i, p := range e.Properties {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Header is the parsed schema of a PLY file.
+type Header struct {
+	Format   Format
+	Comments []string
+	Elements []*Element
+}
+
+// Element returns the named element, or nil if the header does not declare it.
+func (h *Header) Element(name string) *Element {
+	for _, e := range h.Elements {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// readHeader parses the header of a PLY file, leaving r positioned at the start of the body.
+func readHeader(r *bufio.Reader) (*Header, error) {
+	h := &Header{}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(line) != "ply" {
+		return nil, errors.New("incorrect header; missing ply heading")
+	}
+
+	formatFound := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("incorrect header; missing end_header")
+			}
+			return nil, err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "format":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("malformed format line %q", line)
+			}
+			if h.Format, err = parseFormat(fields[1]); err != nil {
+				return nil, err
+			}
+			formatFound = true
+		case "element":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("malformed element line %q", line)
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("invalid element count in %q", line)
+			}
+			h.Elements = append(h.Elements, &Element{Name: fields[1], Count: count})
+		case "property":
+			if len(h.Elements) == 0 {
+				return nil, fmt.Errorf("property declared before any element: %q", line)
+			}
+			p, err := parseProperty(fields)
+			if err != nil {
+				return nil, fmt.Errorf("%w in %q", err, line)
+			}
+			e := h.Elements[len(h.Elements)-1]
+			e.Properties = append(e.Properties, p)
+		case "comment":
+			h.Comments = append(h.Comments, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "comment")))
+		case "obj_info":
+			continue
+		case "end_header":
+			if !formatFound {
+				return nil, errors.New("incorrect header; missing format")
+			}
+			return h, nil
+		default:
+			return nil, fmt.Errorf("unknown header keyword %q", fields[0])
+		}
+	}
+}
+
+// parseProperty parses the fields of either "property <type> <name>" or "property list <count type> <type> <name>".
+func parseProperty(fields []string) (*Property, error) {
+	if len(fields) == 5 && fields[1] == "list" {
+		countType, err := parseDataType(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		if !countType.isInteger() {
+			return nil, fmt.Errorf("list count type %v is not an integer", countType)
+		}
+		t, err := parseDataType(fields[3])
+		if err != nil {
+			return nil, err
+		}
+		return &Property{Name: fields[4], Type: t, IsList: true, CountType: countType}, nil
+	}
+
+	if len(fields) != 3 {
+		return nil, errors.New("malformed property")
+	}
+	t, err := parseDataType(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Property{Name: fields[2], Type: t}, nil
+}
@@ -1,128 +1,88 @@
 package ply
 
 import (
-	"bufio"
-	"errors"
-	"fmt"
 	"os"
-	"strings"
 
 	"github.com/flynnletford/icp-go/point"
 )
 
-func Read(filePath string, twoD bool) (*point.Points3D, error) {
+type ReadOptions struct {
+	// Cropping applied to the vertices once read. The zero value keeps every point.
+	point.CropOptions
+}
+
+// DefaultReadOptions returns every point in the file unmodified.
+var DefaultReadOptions *ReadOptions = &ReadOptions{}
+
+type WriteOptions struct {
+	// Encoding used for the body of the written file.
+	Format Format `json:"format"`
+
+	// Additional per-vertex scalar properties to write, e.g. per-point alignment residuals, keyed by property name.
+	// Each slice must hold one value per point.
+	Extra map[string][]float64 `json:"-"`
+}
+
+var DefaultWriteOptions *WriteOptions = &WriteOptions{
+	Format: ASCII,
+}
+
+// Read reads the vertices of a PLY file and crops them according to opts.
+func Read(filePath string, opts *ReadOptions) (*point.Points3D, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	points := make([]*point.Point3D, 0)
-
-	scanner := bufio.NewScanner(file)
-
-	i := -1
-	headerDone := false
-	for scanner.Scan() {
-		i++
-		line := scanner.Text()
-
-		if i == 0 {
-			if !strings.Contains(line, "ply") {
-				return nil, errors.New("incorrect header; missing ply heading")
-			}
-			continue
-		}
-		if !headerDone {
-			if strings.Contains(line, "end_header") {
-				headerDone = true
-				continue
-			} else {
-				continue
-			}
-		}
-
-		if len(line) == 0 {
-			continue
-		}
-
-		var x, y, z float64
-		if _, err := fmt.Fscanf(strings.NewReader(line), "%v %v %v", &x, &y, &z); err != nil {
-			return nil, err
-		}
-
-		if twoD {
-			z = 0
-		}
-
-		point := &point.Point3D{
-			X: x,
-			Y: y,
-			Z: z,
-		}
-
-		if point.Length() < 1.5 {
-			continue // Avoid robot body.
-		}
-
-		points = append(points, point)
-	}
-	if err := scanner.Err(); err != nil {
+	return Decode(file, opts)
+}
+
+// ReadCloud reads the vertices of a PLY file into a point.Cloud and crops them according to opts.
+func ReadCloud(filePath string, opts *ReadOptions) (*point.Cloud, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	filteredPoints := point.Points3D(points)
-
-	return &filteredPoints, nil
+	return DecodeCloud(file, opts)
 }
 
-func Write(filePath string, points *point.Points3D) error {
-	os.Remove(filePath)
-
-	newFile, err := os.Create(filePath)
+// ReadCloud32 reads the vertices of a PLY file into a float32 point.Cloud32 and crops them according to opts.
+func ReadCloud32(filePath string, opts *ReadOptions) (*point.Cloud32, error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer file.Close()
 
-	if err := writePlyHeader(newFile, points.Len()); err != nil {
-		return err
-	}
+	return DecodeCloud32(file, opts)
+}
 
-	for _, p := range points.Raw() {
-		if err := writeXYZToFile(newFile, p.X, p.Y, p.Z); err != nil {
-			return err
-		}
+// ReadAll reads every element of a PLY file, exposing the header, vertex properties without a point.Point3D
+// field and any non-vertex elements such as faces.
+func ReadAll(filePath string) (*Data, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	return nil
-}
-
-const plyHeaderFormat string = `ply
-format ascii 1.0
-element vertex %v
-property float x
-property float y
-property float z
-end_header
-`
-
-func plyHeader(numVertices int) string {
-	return fmt.Sprintf(plyHeaderFormat, numVertices)
+	return DecodeAll(file)
 }
 
-func writePlyHeader(file *os.File, numPoints int) error {
-
-	if _, err := file.WriteString(plyHeader(numPoints)); err != nil {
+// Write writes points to a PLY file, replacing any existing file.
+func Write(filePath string, points *point.Points3D, opts *WriteOptions) error {
+	file, err := os.Create(filePath)
+	if err != nil {
 		return err
 	}
 
-	return nil
-}
-
-func writeXYZToFile(f *os.File, x float64, y float64, z float64) error {
-	if _, err := f.WriteString(fmt.Sprintf("%v %v %v\n", x, y, z)); err != nil {
+	if err := Encode(file, points, opts); err != nil {
+		file.Close()
 		return err
 	}
 
-	return nil
+	return file.Close()
 }
@@ -0,0 +1,243 @@
+package ply
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+var formats = []Format{ASCII, BinaryLittleEndian, BinaryBigEndian}
+
+// doubleVertices returns vertices with every attribute populated. Positions are georeferenced to check they are
+// kept in double precision, the other values are exact in the types Encode writes.
+func doubleVertices() *point.Points3D {
+	points := point.Points3D{
+		{X: 500000.123456, Y: 5400000.654321, Z: 30.5, Nx: 0, Ny: 0, Nz: 1, Intensity: 10, R: 255, G: 128, B: 1, Time: 1.5e9 + 0.123456, Ring: 3, Label: -2},
+		{X: -1.5, Y: 0.25, Z: 8, Nx: 1, Ny: 0, Nz: 0, Intensity: 0.5, R: 0, G: 0, B: 7, Time: 1.5e9 + 0.5, Ring: 0, Label: 7},
+		{X: 0, Y: -4, Z: 0.125, Nx: 0, Ny: -1, Nz: 0, Intensity: 0, R: 12, G: 34, B: 56, Time: 1.5e9 + 0.75, Ring: 65535, Label: 0},
+	}
+	return &points
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	extra := map[string][]float64{
+		"residual":  {0.5, 0.25, 0},
+		"scalar_id": {1, 0, 1},
+	}
+
+	for _, format := range formats {
+		t.Run(format.String(), func(t *testing.T) {
+			points := doubleVertices()
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, points, &WriteOptions{Format: format, Extra: extra}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			encoded := buf.Bytes()
+
+			data, err := DecodeAll(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+			if data.Header.Format != format {
+				t.Errorf("got format %v, want %v", data.Header.Format, format)
+			}
+			if data.Points.Len() != points.Len() {
+				t.Fatalf("got %d points, want %d", data.Points.Len(), points.Len())
+			}
+			for i, p := range data.Points.Raw() {
+				if want := points.Raw()[i]; *p != *want {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, *want)
+				}
+			}
+			if !reflect.DeepEqual(data.Extra, extra) {
+				t.Errorf("got extra %v, want %v", data.Extra, extra)
+			}
+
+			cloud, err := DecodeCloud(bytes.NewReader(encoded), nil)
+			if err != nil {
+				t.Fatalf("DecodeCloud: %v", err)
+			}
+			if !reflect.DeepEqual(cloud.Points(), points) {
+				t.Errorf("DecodeCloud got %v, want %v", cloud.Points(), points)
+			}
+
+			scanner, err := NewScanner(bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("NewScanner: %v", err)
+			}
+			n := 0
+			for ; scanner.Scan(); n++ {
+				if want := points.Raw()[n]; *scanner.Point() != *want {
+					t.Errorf("scanned point %d: got %+v, want %+v", n, *scanner.Point(), *want)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if n != points.Len() {
+				t.Errorf("scanned %d points, want %d", n, points.Len())
+			}
+		})
+	}
+}
+
+func TestDecodeFacesAndAliases(t *testing.T) {
+	const file = `ply
+format ascii 1.0
+comment written by hand
+element vertex 3
+property float x
+property float y
+property float z
+property uchar diffuse_red
+property uchar diffuse_green
+property uchar diffuse_blue
+property int classification
+element face 1
+property list uchar int vertex_indices
+end_header
+0 0 0 1 2 3 4
+1 0 0 5 6 7 4
+0 1 0 8 9 10 2
+3 0 1 2
+`
+
+	data, err := DecodeAll(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	want := []point.Point3D{
+		{R: 1, G: 2, B: 3, Label: 4},
+		{X: 1, R: 5, G: 6, B: 7, Label: 4},
+		{Y: 1, R: 8, G: 9, B: 10, Label: 2},
+	}
+	for i, p := range data.Points.Raw() {
+		if *p != want[i] {
+			t.Errorf("point %d: got %+v, want %+v", i, *p, want[i])
+		}
+	}
+
+	faces := data.Elements["face"]
+	if faces == nil {
+		t.Fatalf("got no face element")
+	}
+	if got, want := faces.Values, [][][]float64{{{0, 1, 2}}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got faces %v, want %v", got, want)
+	}
+	if got, want := data.Header.Comments, []string{"written by hand"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got comments %q, want %q", got, want)
+	}
+}
+
+// listPLY returns a binary PLY whose vertices carry a list property between their scalar ones, followed by faces
+// with lists of different lengths and count types.
+func listPLY(format Format) []byte {
+	var order binary.AppendByteOrder = binary.LittleEndian
+	if format == BinaryBigEndian {
+		order = binary.BigEndian
+	}
+
+	file := []byte(`ply
+format ` + format.String() + ` 1.0
+element vertex 2
+property double x
+property double y
+property list uchar float texcoord
+property double z
+property ushort quality
+element face 2
+property list uint int vertex_indices
+property uchar flags
+end_header
+`)
+
+	vertices := []struct {
+		x, y, z  float64
+		texcoord []float32
+		quality  uint16
+	}{
+		{x: 500000.25, y: 5400000.5, z: 1, texcoord: []float32{0.5, 0.25}, quality: 7},
+		{x: -1, y: 2, z: -3, quality: 65535},
+	}
+	for _, v := range vertices {
+		file = order.AppendUint64(file, math.Float64bits(v.x))
+		file = order.AppendUint64(file, math.Float64bits(v.y))
+		file = append(file, uint8(len(v.texcoord)))
+		for _, c := range v.texcoord {
+			file = order.AppendUint32(file, math.Float32bits(c))
+		}
+		file = order.AppendUint64(file, math.Float64bits(v.z))
+		file = order.AppendUint16(file, v.quality)
+	}
+
+	for i, face := range [][]int32{{0, 1, 0}, {1, 0, 1, -1}} {
+		file = order.AppendUint32(file, uint32(len(face)))
+		for _, index := range face {
+			file = order.AppendUint32(file, uint32(index))
+		}
+		file = append(file, uint8(i+1))
+	}
+
+	return file
+}
+
+func TestDecodeBinaryLists(t *testing.T) {
+	for _, format := range []Format{BinaryLittleEndian, BinaryBigEndian} {
+		t.Run(format.String(), func(t *testing.T) {
+			data, err := DecodeAll(bytes.NewReader(listPLY(format)))
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			// The texture coordinates are skipped, whatever their length, leaving the properties after them aligned.
+			want := point.Points3D{{X: 500000.25, Y: 5400000.5, Z: 1}, {X: -1, Y: 2, Z: -3}}
+			if !reflect.DeepEqual(*data.Points, want) {
+				t.Errorf("got %v, want %v", *data.Points, want)
+			}
+			if got, want := data.Extra, map[string][]float64{"quality": {7, 65535}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got extra %v, want %v", got, want)
+			}
+
+			faces := data.Elements["face"]
+			if faces == nil {
+				t.Fatalf("got no face element")
+			}
+			if got, want := faces.Values, [][][]float64{{{0, 1, 0}, {1}}, {{1, 0, 1, -1}, {2}}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("got faces %v, want %v", got, want)
+			}
+		})
+	}
+
+	// A list longer than the body is an error rather than an allocation of its stated length.
+	truncated := listPLY(BinaryLittleEndian)
+	binary.LittleEndian.PutUint32(truncated[len(truncated)-21:], 1<<30)
+	if _, err := DecodeAll(bytes.NewReader(truncated)); err == nil || !strings.Contains(err.Error(), "face 1") {
+		t.Errorf("got error %v, want the file to end within face 1", err)
+	}
+}
+
+func TestEncodeExtraErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra map[string][]float64
+	}{
+		{name: "wrong length", extra: map[string][]float64{"residual": {1}}},
+		{name: "clashes with an attribute", extra: map[string][]float64{"red": {1, 2, 3}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Encode(&buf, doubleVertices(), &WriteOptions{Extra: tt.extra}); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package point
+
+// Attributes records which optional attributes of Point3D are populated.
+type Attributes struct {
+	Normals   bool
+	Intensity bool
+	Color     bool
+	Time      bool
+	Ring      bool
+	Label     bool
+}
+
+// Attributes reports the optional attributes that are non-zero for at least one point. Writers use it to
+// emit only the fields a cloud actually carries.
+func (p *Points3D) Attributes() Attributes {
+	var a Attributes
+
+	for _, point := range p.Raw() {
+		a.Normals = a.Normals || point.Nx != 0 || point.Ny != 0 || point.Nz != 0
+		a.Intensity = a.Intensity || point.Intensity != 0
+		a.Color = a.Color || point.R != 0 || point.G != 0 || point.B != 0
+		a.Time = a.Time || point.Time != 0
+		a.Ring = a.Ring || point.Ring != 0
+		a.Label = a.Label || point.Label != 0
+	}
+
+	return a
+}
@@ -0,0 +1,226 @@
+package point
+
+// Coordinates gives indexed access to the positions of a cloud whatever its memory layout, so that spatial
+// indexes and registration work on Points3D and Cloud alike.
+type Coordinates interface {
+	Len() int
+	At(i int) (x, y, z float64)
+}
+
+// Surface is a cloud whose points carry normals, such as the target of point-to-plane registration.
+type Surface interface {
+	Coordinates
+	Normal(i int) (nx, ny, nz float64)
+	SetNormal(i int, nx, ny, nz float64)
+}
+
+var (
+	_ Surface = &Points3D{}
+	_ Surface = &Cloud{}
+)
+
+// At returns the position of the ith point.
+func (p *Points3D) At(i int) (float64, float64, float64) {
+	point := (*p)[i]
+	return point.X, point.Y, point.Z
+}
+
+// Normal returns the normal of the ith point.
+func (p *Points3D) Normal(i int) (float64, float64, float64) {
+	point := (*p)[i]
+	return point.Nx, point.Ny, point.Nz
+}
+
+// SetNormal sets the normal of the ith point.
+func (p *Points3D) SetNormal(i int, nx, ny, nz float64) {
+	point := (*p)[i]
+	point.Nx, point.Ny, point.Nz = nx, ny, nz
+}
+
+// Cloud stores points in contiguous arrays rather than as individually allocated Point3D values, which
+// keeps large clouds compact and cache friendly. Optional attributes are either nil or hold an entry per
+// point, three for normals and colors.
+type Cloud struct {
+	// XYZ holds the position of point i at 3i, 3i+1 and 3i+2.
+	XYZ []float64
+
+	Normals   []float64
+	Intensity []float64
+	RGB       []uint8
+	Time      []float64
+	Ring      []int
+	Label     []int
+}
+
+// NewCloud returns an empty cloud with room for capacity points and arrays for the given attributes.
+func NewCloud(capacity int, attributes Attributes) *Cloud {
+	c := &Cloud{XYZ: make([]float64, 0, 3*capacity)}
+
+	if attributes.Normals {
+		c.Normals = make([]float64, 0, 3*capacity)
+	}
+	if attributes.Intensity {
+		c.Intensity = make([]float64, 0, capacity)
+	}
+	if attributes.Color {
+		c.RGB = make([]uint8, 0, 3*capacity)
+	}
+	if attributes.Time {
+		c.Time = make([]float64, 0, capacity)
+	}
+	if attributes.Ring {
+		c.Ring = make([]int, 0, capacity)
+	}
+	if attributes.Label {
+		c.Label = make([]int, 0, capacity)
+	}
+
+	return c
+}
+
+// FromPoints copies points into a cloud, keeping the attributes populated by any point.
+func FromPoints(points *Points3D) *Cloud {
+	c := NewCloud(points.Len(), points.Attributes())
+	for _, p := range points.Raw() {
+		c.Append(p)
+	}
+	return c
+}
+
+// Points converts the cloud to Points3D, backing every point with a single allocation.
+func (c *Cloud) Points() *Points3D {
+	n := c.Len()
+	storage := make([]Point3D, n)
+	points := make(Points3D, n)
+
+	for i := range points {
+		c.Point(i, &storage[i])
+		points[i] = &storage[i]
+	}
+
+	return &points
+}
+
+// Len returns the number of points in the cloud.
+func (c *Cloud) Len() int {
+	return len(c.XYZ) / 3
+}
+
+// At returns the position of the ith point.
+func (c *Cloud) At(i int) (float64, float64, float64) {
+	return c.XYZ[3*i], c.XYZ[3*i+1], c.XYZ[3*i+2]
+}
+
+// Set sets the position of the ith point.
+func (c *Cloud) Set(i int, x, y, z float64) {
+	c.XYZ[3*i], c.XYZ[3*i+1], c.XYZ[3*i+2] = x, y, z
+}
+
+// Normal returns the normal of the ith point, or the zero vector if the cloud has no normals.
+func (c *Cloud) Normal(i int) (float64, float64, float64) {
+	if c.Normals == nil {
+		return 0, 0, 0
+	}
+	return c.Normals[3*i], c.Normals[3*i+1], c.Normals[3*i+2]
+}
+
+// SetNormal sets the normal of the ith point, adding normals to the cloud if it has none.
+func (c *Cloud) SetNormal(i int, nx, ny, nz float64) {
+	if c.Normals == nil {
+		c.Normals = make([]float64, len(c.XYZ))
+	}
+	c.Normals[3*i], c.Normals[3*i+1], c.Normals[3*i+2] = nx, ny, nz
+}
+
+// Attributes reports which optional attribute arrays the cloud holds.
+func (c *Cloud) Attributes() Attributes {
+	return Attributes{
+		Normals:   c.Normals != nil,
+		Intensity: c.Intensity != nil,
+		Color:     c.RGB != nil,
+		Time:      c.Time != nil,
+		Ring:      c.Ring != nil,
+		Label:     c.Label != nil,
+	}
+}
+
+// Append adds a point to the cloud. Attributes the cloud has no array for are dropped.
+func (c *Cloud) Append(p *Point3D) {
+	c.XYZ = append(c.XYZ, p.X, p.Y, p.Z)
+
+	if c.Normals != nil {
+		c.Normals = append(c.Normals, p.Nx, p.Ny, p.Nz)
+	}
+	if c.Intensity != nil {
+		c.Intensity = append(c.Intensity, p.Intensity)
+	}
+	if c.RGB != nil {
+		c.RGB = append(c.RGB, p.R, p.G, p.B)
+	}
+	if c.Time != nil {
+		c.Time = append(c.Time, p.Time)
+	}
+	if c.Ring != nil {
+		c.Ring = append(c.Ring, p.Ring)
+	}
+	if c.Label != nil {
+		c.Label = append(c.Label, p.Label)
+	}
+}
+
+// Point fills p with the position and attributes of the ith point.
+func (c *Cloud) Point(i int, p *Point3D) {
+	*p = Point3D{}
+	p.X, p.Y, p.Z = c.At(i)
+	p.Nx, p.Ny, p.Nz = c.Normal(i)
+
+	if c.Intensity != nil {
+		p.Intensity = c.Intensity[i]
+	}
+	if c.RGB != nil {
+		p.R, p.G, p.B = c.RGB[3*i], c.RGB[3*i+1], c.RGB[3*i+2]
+	}
+	if c.Time != nil {
+		p.Time = c.Time[i]
+	}
+	if c.Ring != nil {
+		p.Ring = c.Ring[i]
+	}
+	if c.Label != nil {
+		p.Label = c.Label[i]
+	}
+}
+
+// Subset returns a new cloud holding the points at the given indices.
+func (c *Cloud) Subset(indices []int) *Cloud {
+	subset := NewCloud(len(indices), c.Attributes())
+
+	var p Point3D
+	for _, i := range indices {
+		c.Point(i, &p)
+		subset.Append(&p)
+	}
+
+	return subset
+}
+
+// Copy returns a deep copy of the cloud.
+func (c *Cloud) Copy() *Cloud {
+	return &Cloud{
+		XYZ:       clone(c.XYZ),
+		Normals:   clone(c.Normals),
+		Intensity: clone(c.Intensity),
+		RGB:       clone(c.RGB),
+		Time:      clone(c.Time),
+		Ring:      clone(c.Ring),
+		Label:     clone(c.Label),
+	}
+}
+
+// clone copies a slice, keeping nil slices nil.
+func clone[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
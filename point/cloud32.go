@@ -0,0 +1,209 @@
+package point
+
+var _ Surface = &Cloud32{}
+
+// Cloud32 is a Cloud storing positions, normals and intensities as float32, halving the memory used by large
+// maps. Positions are returned as float64 so that computations on the cloud keep full precision; only the
+// stored values are rounded. Time stays float64 since float32 cannot resolve Unix or GPS timestamps.
+type Cloud32 struct {
+	// XYZ holds the position of point i at 3i, 3i+1 and 3i+2.
+	XYZ []float32
+
+	Normals   []float32
+	Intensity []float32
+	RGB       []uint8
+	Time      []float64
+	Ring      []uint16
+	Label     []int32
+}
+
+// NewCloud32 returns an empty cloud with room for capacity points and arrays for the given attributes.
+func NewCloud32(capacity int, attributes Attributes) *Cloud32 {
+	c := &Cloud32{XYZ: make([]float32, 0, 3*capacity)}
+
+	if attributes.Normals {
+		c.Normals = make([]float32, 0, 3*capacity)
+	}
+	if attributes.Intensity {
+		c.Intensity = make([]float32, 0, capacity)
+	}
+	if attributes.Color {
+		c.RGB = make([]uint8, 0, 3*capacity)
+	}
+	if attributes.Time {
+		c.Time = make([]float64, 0, capacity)
+	}
+	if attributes.Ring {
+		c.Ring = make([]uint16, 0, capacity)
+	}
+	if attributes.Label {
+		c.Label = make([]int32, 0, capacity)
+	}
+
+	return c
+}
+
+// FromPoints32 copies points into a float32 cloud, keeping the attributes populated by any point.
+func FromPoints32(points *Points3D) *Cloud32 {
+	c := NewCloud32(points.Len(), points.Attributes())
+	for _, p := range points.Raw() {
+		c.Append(p)
+	}
+	return c
+}
+
+// Cloud32 converts the cloud to float32.
+func (c *Cloud) Cloud32() *Cloud32 {
+	converted := NewCloud32(c.Len(), c.Attributes())
+
+	var p Point3D
+	for i := 0; i < c.Len(); i++ {
+		c.Point(i, &p)
+		converted.Append(&p)
+	}
+
+	return converted
+}
+
+// Cloud converts the cloud to float64.
+func (c *Cloud32) Cloud() *Cloud {
+	converted := NewCloud(c.Len(), c.Attributes())
+
+	var p Point3D
+	for i := 0; i < c.Len(); i++ {
+		c.Point(i, &p)
+		converted.Append(&p)
+	}
+
+	return converted
+}
+
+// Points converts the cloud to Points3D, backing every point with a single allocation.
+func (c *Cloud32) Points() *Points3D {
+	n := c.Len()
+	storage := make([]Point3D, n)
+	points := make(Points3D, n)
+
+	for i := range points {
+		c.Point(i, &storage[i])
+		points[i] = &storage[i]
+	}
+
+	return &points
+}
+
+// Len returns the number of points in the cloud.
+func (c *Cloud32) Len() int {
+	return len(c.XYZ) / 3
+}
+
+// At returns the position of the ith point.
+func (c *Cloud32) At(i int) (float64, float64, float64) {
+	return float64(c.XYZ[3*i]), float64(c.XYZ[3*i+1]), float64(c.XYZ[3*i+2])
+}
+
+// Set sets the position of the ith point.
+func (c *Cloud32) Set(i int, x, y, z float64) {
+	c.XYZ[3*i], c.XYZ[3*i+1], c.XYZ[3*i+2] = float32(x), float32(y), float32(z)
+}
+
+// Normal returns the normal of the ith point, or the zero vector if the cloud has no normals.
+func (c *Cloud32) Normal(i int) (float64, float64, float64) {
+	if c.Normals == nil {
+		return 0, 0, 0
+	}
+	return float64(c.Normals[3*i]), float64(c.Normals[3*i+1]), float64(c.Normals[3*i+2])
+}
+
+// SetNormal sets the normal of the ith point, adding normals to the cloud if it has none.
+func (c *Cloud32) SetNormal(i int, nx, ny, nz float64) {
+	if c.Normals == nil {
+		c.Normals = make([]float32, len(c.XYZ))
+	}
+	c.Normals[3*i], c.Normals[3*i+1], c.Normals[3*i+2] = float32(nx), float32(ny), float32(nz)
+}
+
+// Attributes reports which optional attribute arrays the cloud holds.
+func (c *Cloud32) Attributes() Attributes {
+	return Attributes{
+		Normals:   c.Normals != nil,
+		Intensity: c.Intensity != nil,
+		Color:     c.RGB != nil,
+		Time:      c.Time != nil,
+		Ring:      c.Ring != nil,
+		Label:     c.Label != nil,
+	}
+}
+
+// Append adds a point to the cloud. Attributes the cloud has no array for are dropped.
+func (c *Cloud32) Append(p *Point3D) {
+	c.XYZ = append(c.XYZ, float32(p.X), float32(p.Y), float32(p.Z))
+
+	if c.Normals != nil {
+		c.Normals = append(c.Normals, float32(p.Nx), float32(p.Ny), float32(p.Nz))
+	}
+	if c.Intensity != nil {
+		c.Intensity = append(c.Intensity, float32(p.Intensity))
+	}
+	if c.RGB != nil {
+		c.RGB = append(c.RGB, p.R, p.G, p.B)
+	}
+	if c.Time != nil {
+		c.Time = append(c.Time, p.Time)
+	}
+	if c.Ring != nil {
+		c.Ring = append(c.Ring, uint16(p.Ring))
+	}
+	if c.Label != nil {
+		c.Label = append(c.Label, int32(p.Label))
+	}
+}
+
+// Point fills p with the position and attributes of the ith point.
+func (c *Cloud32) Point(i int, p *Point3D) {
+	*p = Point3D{}
+	p.X, p.Y, p.Z = c.At(i)
+	p.Nx, p.Ny, p.Nz = c.Normal(i)
+
+	if c.Intensity != nil {
+		p.Intensity = float64(c.Intensity[i])
+	}
+	if c.RGB != nil {
+		p.R, p.G, p.B = c.RGB[3*i], c.RGB[3*i+1], c.RGB[3*i+2]
+	}
+	if c.Time != nil {
+		p.Time = c.Time[i]
+	}
+	if c.Ring != nil {
+		p.Ring = int(c.Ring[i])
+	}
+	if c.Label != nil {
+		p.Label = int(c.Label[i])
+	}
+}
+
+// Subset returns a new cloud holding the points at the given indices.
+func (c *Cloud32) Subset(indices []int) *Cloud32 {
+	subset := NewCloud32(len(indices), c.Attributes())
+
+	var p Point3D
+	for _, i := range indices {
+		c.Point(i, &p)
+		subset.Append(&p)
+	}
+
+	return subset
+}
+
+// Copy returns a deep copy of the cloud.
+func (c *Cloud32) Copy() *Cloud32 {
+	return &Cloud32{
+		XYZ:       clone(c.XYZ),
+		Normals:   clone(c.Normals),
+		Intensity: clone(c.Intensity),
+		RGB:       clone(c.RGB),
+		Time:      clone(c.Time),
+		Ring:      clone(c.Ring),
+		Label:     clone(c.Label),
+	}
+}
@@ -0,0 +1,74 @@
+package point
+
+import (
+	"reflect"
+	"testing"
+)
+
+// round32 rounds v to float32.
+func round32(v float64) float64 {
+	return float64(float32(v))
+}
+
+func TestCloud32(t *testing.T) {
+	points := Points3D{
+		{X: 500000.123456, Y: 0.1, Z: -3, Nx: 0.6, Nz: 0.8, Intensity: 0.3, R: 1, G: 2, B: 3, Time: 1700000000.123456, Ring: 65535, Label: -2},
+		{X: 1, Y: 2, Z: 3, Time: 1700000000.5},
+	}
+
+	cloud := FromPoints32(&points)
+
+	want := Attributes{Normals: true, Intensity: true, Color: true, Time: true, Ring: true, Label: true}
+	if got := cloud.Attributes(); got != want {
+		t.Fatalf("got attributes %+v, want %+v", got, want)
+	}
+
+	// Positions, normals and intensities are rounded to float32, while times keep their float64 precision.
+	for i, p := range cloud.Points().Raw() {
+		q := points[i]
+		wantPoint := *q
+		wantPoint.X, wantPoint.Y, wantPoint.Z = round32(q.X), round32(q.Y), round32(q.Z)
+		wantPoint.Nx, wantPoint.Ny, wantPoint.Nz = round32(q.Nx), round32(q.Ny), round32(q.Nz)
+		wantPoint.Intensity = round32(q.Intensity)
+
+		if *p != wantPoint {
+			t.Errorf("point %d: got %+v, want %+v", i, *p, wantPoint)
+		}
+	}
+
+	// Converting to float64 and back keeps the rounded values.
+	if again := cloud.Cloud().Cloud32(); !reflect.DeepEqual(again, cloud) {
+		t.Errorf("got %+v converting to float64 and back, want %+v", again, cloud)
+	}
+
+	subset := cloud.Subset([]int{1})
+	if subset.Len() != 1 || subset.Attributes() != want {
+		t.Fatalf("got a subset of %d points with attributes %+v, want 1 with %+v", subset.Len(), subset.Attributes(), want)
+	}
+	if x, y, z := subset.At(0); x != 1 || y != 2 || z != 3 {
+		t.Errorf("got subset point (%v, %v, %v), want (1, 2, 3)", x, y, z)
+	}
+
+	// A copy does not share the arrays of the cloud.
+	copied := cloud.Copy()
+	copied.Set(0, 0, 0, 0)
+	copied.Ring[0] = 0
+	if x, _, _ := cloud.At(0); x == 0 || cloud.Ring[0] == 0 {
+		t.Error("changing a copy changed the cloud")
+	}
+
+	// Setting a normal adds the normals missing from a cloud.
+	bare := NewCloud32(2, Attributes{})
+	bare.Append(&Point3D{X: 1})
+	bare.Append(&Point3D{X: 2})
+	if nx, ny, nz := bare.Normal(1); nx != 0 || ny != 0 || nz != 0 {
+		t.Errorf("got normal (%v, %v, %v) without normals, want zero", nx, ny, nz)
+	}
+	bare.SetNormal(1, 0, 0, 1)
+	if nx, ny, nz := bare.Normal(1); nx != 0 || ny != 0 || nz != 1 {
+		t.Errorf("got normal (%v, %v, %v), want (0, 0, 1)", nx, ny, nz)
+	}
+	if len(bare.Normals) != 6 {
+		t.Errorf("got %d normal components, want 6", len(bare.Normals))
+	}
+}
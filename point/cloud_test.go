@@ -0,0 +1,90 @@
+package point
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCloud(t *testing.T) {
+	points := Points3D{
+		{X: 1.5, Y: -2, Z: 3, Nx: 0.6, Nz: 0.8, Intensity: 0.25, R: 255, G: 128, B: 1, Time: 1700000000.123456, Ring: 31, Label: -1},
+		{X: 4e6, Y: 5e5, Z: -0.001},
+		{X: 7, Y: 8, Z: 9, Ring: 2},
+	}
+
+	cloud := FromPoints(&points)
+
+	want := Attributes{Normals: true, Intensity: true, Color: true, Time: true, Ring: true, Label: true}
+	if got := cloud.Attributes(); got != want {
+		t.Fatalf("got attributes %+v, want %+v", got, want)
+	}
+	if cloud.Len() != len(points) || len(cloud.XYZ) != 3*len(points) || len(cloud.Normals) != 3*len(points) || len(cloud.RGB) != 3*len(points) {
+		t.Fatalf("got %d points with %d coordinates, %d normal and %d color components, want %d points", cloud.Len(), len(cloud.XYZ), len(cloud.Normals), len(cloud.RGB), len(points))
+	}
+
+	// Every attribute survives the round trip, including those only some points have.
+	if got := cloud.Points(); !reflect.DeepEqual(*got, points) {
+		t.Errorf("got %v, want %v", *got, points)
+	}
+
+	subset := cloud.Subset([]int{2, 0})
+	if got, want := *subset.Points(), (Points3D{points[2], points[0]}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got subset %v, want %v", got, want)
+	}
+
+	// A copy does not share the arrays of the cloud.
+	copied := cloud.Copy()
+	copied.Set(0, 0, 0, 0)
+	copied.Label[0] = 5
+	if x, _, _ := cloud.At(0); x != 1.5 || cloud.Label[0] != -1 {
+		t.Error("changing a copy changed the cloud")
+	}
+}
+
+func TestCloudAttributes(t *testing.T) {
+	p := &Point3D{X: 1, Y: 2, Z: 3, Nz: 1, Intensity: 0.5, R: 1, Time: 2, Ring: 3, Label: 4}
+
+	tests := []struct {
+		name       string
+		attributes Attributes
+		want       Point3D
+	}{
+		{name: "positions only", want: Point3D{X: 1, Y: 2, Z: 3}},
+		{name: "intensity and ring", attributes: Attributes{Intensity: true, Ring: true}, want: Point3D{X: 1, Y: 2, Z: 3, Intensity: 0.5, Ring: 3}},
+		{name: "normals and label", attributes: Attributes{Normals: true, Label: true}, want: Point3D{X: 1, Y: 2, Z: 3, Nz: 1, Label: 4}},
+		{name: "color and time", attributes: Attributes{Color: true, Time: true}, want: Point3D{X: 1, Y: 2, Z: 3, R: 1, Time: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cloud := NewCloud(1, tt.attributes)
+			if got := cloud.Attributes(); got != tt.attributes {
+				t.Fatalf("got attributes %+v, want %+v", got, tt.attributes)
+			}
+
+			// Attributes the cloud holds no array for are dropped.
+			cloud.Append(p)
+
+			var got Point3D
+			cloud.Point(0, &got)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// Setting a normal adds the normals missing from a cloud, leaving the other points without one.
+	cloud := NewCloud(2, Attributes{})
+	cloud.Append(&Point3D{X: 1})
+	cloud.Append(&Point3D{X: 2})
+	cloud.SetNormal(1, 0, 1, 0)
+	if !cloud.Attributes().Normals {
+		t.Fatal("got no normals after setting one")
+	}
+	if nx, ny, nz := cloud.Normal(0); nx != 0 || ny != 0 || nz != 0 {
+		t.Errorf("got normal (%v, %v, %v) for the first point, want zero", nx, ny, nz)
+	}
+	if nx, ny, nz := cloud.Normal(1); nx != 0 || ny != 1 || nz != 0 {
+		t.Errorf("got normal (%v, %v, %v) for the second point, want (0, 1, 0)", nx, ny, nz)
+	}
+}
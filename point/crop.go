@@ -0,0 +1,71 @@
+package point
+
+// Predicate reports whether a point should be kept.
+type Predicate func(p *Point3D) bool
+
+type CropOptions struct {
+	// Points closer than MinRange to the origin are removed. Zero disables the check.
+	MinRange float64 `json:"minRange"`
+
+	// Points further than MaxRange from the origin are removed. Zero disables the check.
+	MaxRange float64 `json:"maxRange"`
+
+	// Flatten every point onto the z = 0 plane. Ranges are measured after flattening.
+	TwoD bool `json:"twoD"`
+
+	// Optional predicate applied after the range checks; points for which it returns false are removed.
+	Keep Predicate `json:"-"`
+}
+
+// IsZero reports whether the options leave a cloud unchanged.
+func (o *CropOptions) IsZero() bool {
+	return o == nil || (o.MinRange == 0 && o.MaxRange == 0 && !o.TwoD && o.Keep == nil)
+}
+
+// Crop returns the points which pass the options. Points are shared with the receiver unless TwoD is set,
+// in which case flattened copies are returned.
+func (p *Points3D) Crop(opts *CropOptions) *Points3D {
+	if opts.IsZero() {
+		return p
+	}
+
+	filtered := make([]*Point3D, 0, p.Len())
+
+	for _, point := range p.Raw() {
+
+		if opts.TwoD {
+			flattened := *point
+			point = &flattened
+		}
+
+		if opts.Accept(point) {
+			filtered = append(filtered, point)
+		}
+	}
+
+	wrapped := Points3D(filtered)
+
+	return &wrapped
+}
+
+// Accept reports whether a single point passes the options, flattening it in place first if TwoD is set.
+// Readers use it to crop points as they are decoded.
+func (o *CropOptions) Accept(p *Point3D) bool {
+	if o.IsZero() {
+		return true
+	}
+
+	if o.TwoD {
+		p.Z = 0
+	}
+
+	length := p.Length()
+	if o.MinRange > 0 && length < o.MinRange {
+		return false
+	}
+	if o.MaxRange > 0 && length > o.MaxRange {
+		return false
+	}
+
+	return o.Keep == nil || o.Keep(p)
+}
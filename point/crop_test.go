@@ -0,0 +1,85 @@
+package point
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCrop(t *testing.T) {
+	// Ranges of 2, 5, 13 and 13 m, the last two 5 m once flattened.
+	points := Points3D{
+		{X: 0, Y: 0, Z: 2},
+		{X: 3, Y: 4, Z: 0, Label: 1},
+		{X: 3, Y: 4, Z: 12},
+		{X: 0, Y: -5, Z: -12, Label: 1},
+	}
+
+	tests := []struct {
+		name string
+		opts *CropOptions
+		want []int
+	}{
+		{name: "nil options", want: []int{0, 1, 2, 3}},
+		{name: "zero options", opts: &CropOptions{}, want: []int{0, 1, 2, 3}},
+		{name: "min range at a point", opts: &CropOptions{MinRange: 5}, want: []int{1, 2, 3}},
+		{name: "min range past a point", opts: &CropOptions{MinRange: 5.0001}, want: []int{2, 3}},
+		{name: "max range at a point", opts: &CropOptions{MaxRange: 5}, want: []int{0, 1}},
+		{name: "max range short of a point", opts: &CropOptions{MaxRange: 4.9999}, want: []int{0}},
+		{name: "min and max range", opts: &CropOptions{MinRange: 3, MaxRange: 10}, want: []int{1}},
+		{name: "2D", opts: &CropOptions{TwoD: true}, want: []int{0, 1, 2, 3}},
+		{name: "2D max range", opts: &CropOptions{MaxRange: 5, TwoD: true}, want: []int{0, 1, 2, 3}},
+		{name: "2D min range", opts: &CropOptions{MinRange: 1, TwoD: true}, want: []int{1, 2, 3}},
+		{name: "keep", opts: &CropOptions{Keep: func(p *Point3D) bool { return p.Label == 1 }}, want: []int{1, 3}},
+		{name: "keep after the range checks", opts: &CropOptions{MaxRange: 10, Keep: func(p *Point3D) bool { return p.Label == 1 }}, want: []int{1}},
+		{name: "keep sees flattened points", opts: &CropOptions{TwoD: true, Keep: func(p *Point3D) bool { return p.Z == 0 }}, want: []int{0, 1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := points.Copy()
+			cropped := original.Crop(tt.opts)
+
+			want := make(Points3D, len(tt.want))
+			for i, index := range tt.want {
+				p := *points[index]
+				if tt.opts != nil && tt.opts.TwoD {
+					p.Z = 0
+				}
+				want[i] = &p
+			}
+			if !reflect.DeepEqual(*cropped, want) {
+				t.Fatalf("got %v, want %v", *cropped, want)
+			}
+
+			// Flattening copies the points, leaving those of the receiver as they were.
+			if !reflect.DeepEqual(original, points.Copy()) {
+				t.Errorf("cropping changed the receiver to %v", *original)
+			}
+			if len(tt.want) > 0 {
+				shared := (*cropped)[0] == (*original)[tt.want[0]]
+				if twoD := tt.opts != nil && tt.opts.TwoD; shared == twoD {
+					t.Errorf("got points shared with the receiver %v, want %v", shared, !twoD)
+				}
+			}
+
+			// Accept agrees with Crop point by point.
+			for i, p := range points {
+				q := *p
+				accepted := tt.opts.Accept(&q)
+				if kept := contains(tt.want, i); accepted != kept {
+					t.Errorf("point %d: got Accept %v, want %v", i, accepted, kept)
+				}
+			}
+		})
+	}
+}
+
+// contains reports whether indices holds i.
+func contains(indices []int, i int) bool {
+	for _, index := range indices {
+		if index == i {
+			return true
+		}
+	}
+	return false
+}
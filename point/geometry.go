@@ -0,0 +1,246 @@
+package point
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// Box is an axis-aligned bounding box.
+type Box struct {
+	Min Point3D `json:"min"`
+	Max Point3D `json:"max"`
+}
+
+// Extent returns the size of the box along each axis.
+func (b *Box) Extent() *Point3D {
+	return &Point3D{X: b.Max.X - b.Min.X, Y: b.Max.Y - b.Min.Y, Z: b.Max.Z - b.Min.Z}
+}
+
+// Contains reports whether p lies inside the box, boundary included.
+func (b *Box) Contains(p *Point3D) bool {
+	return p.X >= b.Min.X && p.X <= b.Max.X && p.Y >= b.Min.Y && p.Y <= b.Max.Y && p.Z >= b.Min.Z && p.Z <= b.Max.Z
+}
+
+// OrientedBox is a bounding box aligned with the principal axes of a cloud.
+type OrientedBox struct {
+	Center Point3D `json:"center"`
+
+	// Unit axes of the box, in decreasing order of variance, forming a right-handed frame.
+	Axes [3]Point3D `json:"axes"`
+
+	// Half the size of the box along each axis.
+	HalfExtents [3]float64 `json:"halfExtents"`
+}
+
+// Bounds returns the axis-aligned bounding box of the points of coords at the given indices, or every point
+// if indices is nil. The box of no points is the zero Box.
+func Bounds(coords Coordinates, indices []int) *Box {
+	box := &Box{
+		Min: Point3D{X: math.Inf(1), Y: math.Inf(1), Z: math.Inf(1)},
+		Max: Point3D{X: math.Inf(-1), Y: math.Inf(-1), Z: math.Inf(-1)},
+	}
+
+	n := forEach(coords, indices, func(x, y, z float64) {
+		box.Min.X, box.Max.X = math.Min(box.Min.X, x), math.Max(box.Max.X, x)
+		box.Min.Y, box.Max.Y = math.Min(box.Min.Y, y), math.Max(box.Max.Y, y)
+		box.Min.Z, box.Max.Z = math.Min(box.Min.Z, z), math.Max(box.Max.Z, z)
+	})
+	if n == 0 {
+		return &Box{}
+	}
+
+	return box
+}
+
+// Centroid returns the mean position of the points of coords at the given indices, or every point if
+// indices is nil. The centroid of no points is NaN.
+func Centroid(coords Coordinates, indices []int) *Point3D {
+	var sumX, sumY, sumZ float64
+
+	n := float64(forEach(coords, indices, func(x, y, z float64) {
+		sumX += x
+		sumY += y
+		sumZ += z
+	}))
+
+	return &Point3D{X: sumX / n, Y: sumY / n, Z: sumZ / n}
+}
+
+// Covariance returns the covariance matrix of the points of coords at the given indices, or every point if
+// indices is nil, normalized by the number of points. Positions are taken relative to their centroid, which
+// is also returned, so that large coordinates keep their precision.
+func Covariance(coords Coordinates, indices []int) ([3][3]float64, *Point3D) {
+	var cov [3][3]float64
+
+	c := Centroid(coords, indices)
+
+	n := float64(forEach(coords, indices, func(x, y, z float64) {
+		d := [3]float64{x - c.X, y - c.Y, z - c.Z}
+		for j := 0; j < 3; j++ {
+			for k := j; k < 3; k++ {
+				cov[j][k] += d[j] * d[k]
+			}
+		}
+	}))
+
+	for j := 0; j < 3; j++ {
+		for k := j; k < 3; k++ {
+			cov[j][k] /= n
+			cov[k][j] = cov[j][k]
+		}
+	}
+
+	return cov, c
+}
+
+// PrincipalAxes returns the unit principal axes of the points of coords at the given indices, or every point
+// if indices is nil, along with the variance along each. Axes are in decreasing order of variance, so the
+// last is the normal of the plane best fitting the points.
+func PrincipalAxes(coords Coordinates, indices []int) ([3]Point3D, [3]float64, error) {
+	var axes [3]Point3D
+	var variances [3]float64
+
+	cov, _ := Covariance(coords, indices)
+
+	// The covariance is symmetric positive semi-definite, so its singular vectors are its eigenvectors.
+	var svd mat.SVD
+	if ok := svd.Factorize(mat.NewDense(3, 3, []float64{
+		cov[0][0], cov[0][1], cov[0][2],
+		cov[1][0], cov[1][1], cov[1][2],
+		cov[2][0], cov[2][1], cov[2][2],
+	}), mat.SVDFull); !ok {
+		return axes, variances, fmt.Errorf("failed to compute SVD")
+	}
+
+	U := mat.NewDense(3, 3, nil)
+	svd.UTo(U)
+	values := svd.Values(nil)
+
+	for j := 0; j < 3; j++ {
+		axes[j] = Point3D{X: U.At(0, j), Y: U.At(1, j), Z: U.At(2, j)}
+		variances[j] = values[j]
+	}
+
+	return axes, variances, nil
+}
+
+// OrientedBounds returns the bounding box of the points of coords at the given indices, or every point if
+// indices is nil, aligned with their principal axes.
+func OrientedBounds(coords Coordinates, indices []int) (*OrientedBox, error) {
+	axes, _, err := PrincipalAxes(coords, indices)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the frame right-handed.
+	third := Point3D{
+		X: axes[0].Y*axes[1].Z - axes[0].Z*axes[1].Y,
+		Y: axes[0].Z*axes[1].X - axes[0].X*axes[1].Z,
+		Z: axes[0].X*axes[1].Y - axes[0].Y*axes[1].X,
+	}
+	axes[2] = third
+
+	c := Centroid(coords, indices)
+
+	lo := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+
+	forEach(coords, indices, func(x, y, z float64) {
+		dx, dy, dz := x-c.X, y-c.Y, z-c.Z
+		for j, axis := range axes {
+			v := dx*axis.X + dy*axis.Y + dz*axis.Z
+			lo[j], hi[j] = math.Min(lo[j], v), math.Max(hi[j], v)
+		}
+	})
+
+	box := &OrientedBox{Center: *c, Axes: axes}
+	for j, axis := range axes {
+		mid := (lo[j] + hi[j]) / 2
+		box.Center.X += mid * axis.X
+		box.Center.Y += mid * axis.Y
+		box.Center.Z += mid * axis.Z
+		box.HalfExtents[j] = (hi[j] - lo[j]) / 2
+	}
+
+	return box, nil
+}
+
+// forEach calls f with the position of the points of coords at the given indices, or every point if indices
+// is nil, returning the number of points visited.
+func forEach(coords Coordinates, indices []int, f func(x, y, z float64)) int {
+	if indices == nil {
+		for i := 0; i < coords.Len(); i++ {
+			f(coords.At(i))
+		}
+		return coords.Len()
+	}
+
+	for _, i := range indices {
+		f(coords.At(i))
+	}
+	return len(indices)
+}
+
+// Bounds returns the axis-aligned bounding box of the points.
+func (p *Points3D) Bounds() *Box {
+	return Bounds(p, nil)
+}
+
+// OrientedBounds returns the bounding box of the points aligned with their principal axes.
+func (p *Points3D) OrientedBounds() (*OrientedBox, error) {
+	return OrientedBounds(p, nil)
+}
+
+// Extent returns the size of the axis-aligned bounding box of the points along each axis.
+func (p *Points3D) Extent() *Point3D {
+	return p.Bounds().Extent()
+}
+
+// Centroid returns the mean position of the points.
+func (p *Points3D) Centroid() *Point3D {
+	return Centroid(p, nil)
+}
+
+// Covariance returns the covariance matrix of the point positions.
+func (p *Points3D) Covariance() [3][3]float64 {
+	cov, _ := Covariance(p, nil)
+	return cov
+}
+
+// PrincipalAxes returns the unit principal axes of the points in decreasing order of variance, along with
+// the variance along each.
+func (p *Points3D) PrincipalAxes() ([3]Point3D, [3]float64, error) {
+	return PrincipalAxes(p, nil)
+}
+
+// Subset returns the points at the given indices. Points are shared with the receiver.
+func (p *Points3D) Subset(indices []int) *Points3D {
+	points := make(Points3D, len(indices))
+	for j, i := range indices {
+		points[j] = (*p)[i]
+	}
+	return &points
+}
+
+// Merge appends the points of others to the receiver, skipping nil lists. Points are shared with others.
+func (p *Points3D) Merge(others ...*Points3D) {
+	for _, other := range others {
+		*p = append(*p, other.Raw()...)
+	}
+}
+
+// Concat returns a new list holding the points of each cloud in turn, skipping nil clouds. Points are shared
+// with the clouds.
+func Concat(clouds ...*Points3D) *Points3D {
+	var n int
+	for _, c := range clouds {
+		n += len(c.Raw())
+	}
+
+	points := make(Points3D, 0, n)
+	points.Merge(clouds...)
+
+	return &points
+}
@@ -0,0 +1,202 @@
+package point
+
+import (
+	"math"
+	"testing"
+)
+
+// grid returns the points of a regular grid spanning [0, size] along each axis with the given spacing.
+func grid(size [3]float64, spacing float64) *Points3D {
+	points := make(Points3D, 0)
+	for x := 0.0; x <= size[0]+1e-9; x += spacing {
+		for y := 0.0; y <= size[1]+1e-9; y += spacing {
+			for z := 0.0; z <= size[2]+1e-9; z += spacing {
+				points = append(points, &Point3D{X: x, Y: y, Z: z})
+			}
+		}
+	}
+	return &points
+}
+
+// rotated returns copies of the points rotated by yaw about z and then by pitch about y, shifted by t.
+func rotated(points *Points3D, yaw, pitch float64, t Point3D) *Points3D {
+	sy, cy := math.Sincos(yaw)
+	sp, cp := math.Sincos(pitch)
+
+	out := make(Points3D, 0, points.Len())
+	for _, p := range points.Raw() {
+		x, y := cy*p.X-sy*p.Y, sy*p.X+cy*p.Y
+		x, z := cp*x+sp*p.Z, -sp*x+cp*p.Z
+		out = append(out, &Point3D{X: x + t.X, Y: y + t.Y, Z: z + t.Z})
+	}
+	return &out
+}
+
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance
+}
+
+func nearPoint(p, q *Point3D, tolerance float64) bool {
+	return near(p.X, q.X, tolerance) && near(p.Y, q.Y, tolerance) && near(p.Z, q.Z, tolerance)
+}
+
+func dot(a, b Point3D) float64 {
+	return a.X*b.X + a.Y*b.Y + a.Z*b.Z
+}
+
+func TestBoundsAndCentroid(t *testing.T) {
+	points := rotated(grid([3]float64{4, 2, 1}, 0.5), 0, 0, Point3D{X: -1, Y: 10, Z: 5})
+
+	box := points.Bounds()
+	if !nearPoint(&box.Min, &Point3D{X: -1, Y: 10, Z: 5}, 1e-12) || !nearPoint(&box.Max, &Point3D{X: 3, Y: 12, Z: 6}, 1e-12) {
+		t.Errorf("got box %v to %v, want (-1, 10, 5) to (3, 12, 6)", box.Min, box.Max)
+	}
+	if extent := points.Extent(); !nearPoint(extent, &Point3D{X: 4, Y: 2, Z: 1}, 1e-12) {
+		t.Errorf("got extent %v, want (4, 2, 1)", *extent)
+	}
+	if !box.Contains(&Point3D{X: 3, Y: 12, Z: 6}) || box.Contains(&Point3D{X: 3.01, Y: 11, Z: 5.5}) {
+		t.Error("box should contain its corners and nothing outside")
+	}
+
+	if c := points.Centroid(); !nearPoint(c, &Point3D{X: 1, Y: 11, Z: 5.5}, 1e-12) {
+		t.Errorf("got centroid %v, want (1, 11, 5.5)", *c)
+	}
+
+	// Indices select the points taken into account.
+	indices := []int{0, points.Len() - 1}
+	if c := Centroid(points, indices); !nearPoint(c, &Point3D{X: 1, Y: 11, Z: 5.5}, 1e-12) {
+		t.Errorf("got centroid of the corners %v, want (1, 11, 5.5)", *c)
+	}
+	if box := Bounds(points, indices[:1]); box.Min != box.Max || box.Min != *points.Raw()[0] {
+		t.Errorf("got box %v to %v of a single point", box.Min, box.Max)
+	}
+}
+
+func TestEmptySets(t *testing.T) {
+	empty := &Points3D{}
+
+	if box := empty.Bounds(); *box != (Box{}) {
+		t.Errorf("got box %v of no points, want the zero Box", *box)
+	}
+	if box := Bounds(grid([3]float64{1, 1, 1}, 1), []int{}); *box != (Box{}) {
+		t.Errorf("got box %v of no indices, want the zero Box", *box)
+	}
+
+	c := empty.Centroid()
+	if !math.IsNaN(c.X) || !math.IsNaN(c.Y) || !math.IsNaN(c.Z) {
+		t.Errorf("got centroid %v of no points, want NaN", *c)
+	}
+}
+
+func TestCovarianceOfPlane(t *testing.T) {
+	// A flat grid in the z = 2 plane has no variance along z.
+	points := rotated(grid([3]float64{2, 2, 0}, 0.25), 0, 0, Point3D{Z: 2})
+
+	cov := points.Covariance()
+
+	// Along x and y, 9 values spaced by h = 0.25 have a variance of h² (9² - 1) / 12.
+	const want = 5.0 / 12
+	for j := 0; j < 3; j++ {
+		for k := 0; k < 3; k++ {
+			expected := 0.0
+			if j == k && j < 2 {
+				expected = want
+			}
+			if !near(cov[j][k], expected, 1e-12) {
+				t.Errorf("got covariance[%d][%d] = %v, want %v", j, k, cov[j][k], expected)
+			}
+		}
+	}
+}
+
+func TestPrincipalAxesOfTiltedPlane(t *testing.T) {
+	const yaw, pitch = 0.4, 0.3
+	points := rotated(grid([3]float64{6, 2, 0}, 0.1), yaw, pitch, Point3D{X: 1e5, Y: -2e5, Z: 30})
+
+	axes, variances, err := points.PrincipalAxes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rotated x, y and z axes of the grid, in decreasing order of variance.
+	want := rotated(&Points3D{{X: 1}, {Y: 1}, {Z: 1}}, yaw, pitch, Point3D{})
+	for j, axis := range axes {
+		if !near(math.Abs(dot(axis, *want.Raw()[j])), 1, 1e-9) {
+			t.Errorf("got axis %d = %v, want ±%v", j, axis, *want.Raw()[j])
+		}
+		if !near(dot(axis, axis), 1, 1e-12) {
+			t.Errorf("axis %d is not a unit vector", j)
+		}
+	}
+	if !(variances[0] > variances[1] && variances[1] > variances[2]) || !near(variances[2], 0, 1e-9) {
+		t.Errorf("got variances %v, want decreasing to zero along the normal", variances)
+	}
+}
+
+func TestOrientedBounds(t *testing.T) {
+	const yaw, pitch = -0.7, 0.2
+	center := Point3D{X: 50, Y: -20, Z: 3}
+
+	// A 4 x 2 x 1 box with its centre at the origin before being moved.
+	box := rotated(grid([3]float64{4, 2, 1}, 0.25), 0, 0, Point3D{X: -2, Y: -1, Z: -0.5})
+	points := rotated(box, yaw, pitch, center)
+
+	obb, err := points.OrientedBounds()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !nearPoint(&obb.Center, &center, 1e-9) {
+		t.Errorf("got centre %v, want %v", obb.Center, center)
+	}
+	for j, want := range []float64{2, 1, 0.5} {
+		if !near(obb.HalfExtents[j], want, 1e-9) {
+			t.Errorf("got half extent %d = %v, want %v", j, obb.HalfExtents[j], want)
+		}
+	}
+
+	// The axes form a right-handed orthonormal frame: the third is the cross product of the first two.
+	a, b, c := obb.Axes[0], obb.Axes[1], obb.Axes[2]
+	cross := Point3D{X: a.Y*b.Z - a.Z*b.Y, Y: a.Z*b.X - a.X*b.Z, Z: a.X*b.Y - a.Y*b.X}
+	if !nearPoint(&c, &cross, 1e-9) || !near(dot(a, b), 0, 1e-9) {
+		t.Errorf("got axes %v, want a right-handed orthonormal frame", obb.Axes)
+	}
+
+	// Every point lies within the box.
+	for _, p := range points.Raw() {
+		d := Point3D{X: p.X - obb.Center.X, Y: p.Y - obb.Center.Y, Z: p.Z - obb.Center.Z}
+		for j, axis := range obb.Axes {
+			if math.Abs(dot(d, axis)) > obb.HalfExtents[j]+1e-9 {
+				t.Fatalf("point %v lies outside the box", *p)
+			}
+		}
+	}
+}
+
+func TestSubsetMergeAndConcat(t *testing.T) {
+	a := grid([3]float64{1, 0, 0}, 1)
+	b := grid([3]float64{0, 2, 0}, 1)
+
+	subset := b.Subset([]int{2, 0})
+	if subset.Len() != 2 || subset.Raw()[0] != b.Raw()[2] || subset.Raw()[1] != b.Raw()[0] {
+		t.Errorf("got subset %v, want points 2 and 0 shared with the source", subset.Raw())
+	}
+
+	all := Concat(a, nil, b)
+	if all.Len() != a.Len()+b.Len() || all.Raw()[0] != a.Raw()[0] || all.Raw()[a.Len()] != b.Raw()[0] {
+		t.Errorf("got %d concatenated points, want the %d of a followed by the %d of b", all.Len(), a.Len(), b.Len())
+	}
+	if a.Len() != 2 {
+		t.Errorf("concatenating modified its arguments")
+	}
+
+	merged := a.Copy()
+	merged.Merge(nil, b)
+	if merged.Len() != a.Len()+b.Len() || merged.Raw()[a.Len()] != b.Raw()[0] {
+		t.Errorf("got %d merged points, want %d", merged.Len(), a.Len()+b.Len())
+	}
+
+	if empty := Concat(); empty.Len() != 0 {
+		t.Errorf("got %d points concatenating nothing", empty.Len())
+	}
+}
@@ -0,0 +1,451 @@
+package point
+
+import (
+	"math"
+)
+
+const (
+	// A subtree is rebuilt when one side holds more than ikdBalance of its points, or more than ikdDeleted
+	// of its points are deleted.
+	ikdBalance = 0.7
+	ikdDeleted = 0.5
+
+	// Subtrees smaller than this are never rebuilt.
+	ikdMinRebuildSize = 16
+)
+
+// IKDTree is an incremental k-d tree in the style of ikd-Tree, suited to maps that grow with every scan.
+// Points are inserted without rebuilding the tree, deleted lazily by marking them, and subtrees that become
+// unbalanced or hold too many deleted points are rebuilt on their own. Each node keeps the bounds of its
+// subtree, so that whole regions can be deleted at once and searches prune by bounds rather than split
+// planes.
+//
+// When a voxel size is set, inserted points are downsampled on a grid of that size: each voxel keeps the
+// point closest to its center.
+type IKDTree struct {
+	coords    Coordinates
+	voxelSize float64
+	root      *ikdNode
+
+	// Topmost unbalanced node found by the last update, rebuilt once it completes, and its ancestors, whose
+	// counts change with the rebuild.
+	rebuild   **ikdNode
+	ancestors []*ikdNode
+
+	// Nodes from the root to the one the current update is visiting.
+	path []*ikdNode
+}
+
+// ikdNode holds a single point and splits its subtree on dim at the position of that point.
+type ikdNode struct {
+	index       int
+	dim         int
+	left, right *ikdNode
+
+	// Number of points in the subtree, deleted ones included, and number of those deleted.
+	size, invalid int
+
+	// Whether the point of the node is deleted, and whether every point below it is too but has not been
+	// marked yet.
+	deleted, treeDeleted bool
+
+	// Bounds of the points in the subtree, which may still cover deleted points.
+	lo, hi [3]float64
+}
+
+// NewIKDTree returns a tree holding the points of coords at the given indices, or every point if indices is
+// nil, downsampled on a grid of voxelSize. A voxelSize of zero keeps every point.
+func NewIKDTree(coords Coordinates, voxelSize float64, indices []int) *IKDTree {
+	t := &IKDTree{coords: coords, voxelSize: voxelSize}
+
+	if indices == nil {
+		indices = make([]int, coords.Len())
+		for i := range indices {
+			indices[i] = i
+		}
+	}
+
+	if voxelSize > 0 {
+		t.Insert(indices...)
+	} else {
+		t.root = t.build(append([]int(nil), indices...))
+	}
+
+	return t
+}
+
+// Coordinates returns the cloud the tree indexes.
+func (t *IKDTree) Coordinates() Coordinates {
+	return t.coords
+}
+
+// Len returns the number of points in the tree that are not deleted.
+func (t *IKDTree) Len() int {
+	if t.root == nil {
+		return 0
+	}
+	return t.root.size - t.root.invalid
+}
+
+// Insert adds the points of the cloud at the given indices, downsampling them if the tree has a voxel size.
+// A point must not be inserted again until it has been removed.
+func (t *IKDTree) Insert(indices ...int) {
+	for _, i := range indices {
+		x, y, z := t.coords.At(i)
+		p := [3]float64{x, y, z}
+
+		if t.voxelSize > 0 && !t.keep(p) {
+			continue
+		}
+
+		t.insert(&t.root, i, p, 0)
+		t.rebalance()
+	}
+}
+
+// keep reports whether p is closer to the center of its voxel than the points already held there, deleting
+// them if so.
+func (t *IKDTree) keep(p [3]float64) bool {
+	var lo, center [3]float64
+	for d := range p {
+		lo[d] = math.Floor(p[d]/t.voxelSize) * t.voxelSize
+		center[d] = lo[d] + t.voxelSize/2
+	}
+	box := Box{
+		Min: Point3D{X: lo[0], Y: lo[1], Z: lo[2]},
+		Max: Point3D{X: lo[0] + t.voxelSize, Y: lo[1] + t.voxelSize, Z: lo[2] + t.voxelSize},
+	}
+
+	dist := func(q [3]float64) float64 {
+		dx, dy, dz := q[0]-center[0], q[1]-center[1], q[2]-center[2]
+		return dx*dx + dy*dy + dz*dz
+	}
+
+	// The voxel is half open, so leave out held points on its upper faces.
+	var held []int
+	for _, i := range t.InBox(&box) {
+		x, y, z := t.coords.At(i)
+		if x < box.Max.X && y < box.Max.Y && z < box.Max.Z {
+			held = append(held, i)
+		}
+	}
+
+	d := dist(p)
+	for _, i := range held {
+		x, y, z := t.coords.At(i)
+		if dist([3]float64{x, y, z}) <= d {
+			return false
+		}
+	}
+
+	t.Remove(held...)
+	return true
+}
+
+func (t *IKDTree) insert(n **ikdNode, i int, p [3]float64, dim int) {
+	node := *n
+	if node == nil {
+		*n = &ikdNode{index: i, dim: dim, size: 1, lo: p, hi: p}
+		return
+	}
+
+	t.enter(node)
+	defer t.leave()
+
+	node.pushDown()
+	node.size++
+	for d := range p {
+		node.lo[d] = math.Min(node.lo[d], p[d])
+		node.hi[d] = math.Max(node.hi[d], p[d])
+	}
+
+	next := (node.dim + 1) % 3
+	if p[node.dim] < coordinate(t.coords, node.index, node.dim) {
+		t.insert(&node.left, i, p, next)
+	} else {
+		t.insert(&node.right, i, p, next)
+	}
+
+	if node.unbalanced() {
+		t.mark(n)
+	}
+}
+
+// Remove deletes the points of the cloud at the given indices. Points not in the tree are ignored.
+func (t *IKDTree) Remove(indices ...int) {
+	for _, i := range indices {
+		x, y, z := t.coords.At(i)
+		if t.remove(&t.root, i, [3]float64{x, y, z}) {
+			t.rebalance()
+		}
+	}
+}
+
+func (t *IKDTree) remove(n **ikdNode, i int, p [3]float64) bool {
+	node := *n
+	if node == nil || node.invalid == node.size || node.distance(p) > 0 {
+		return false
+	}
+
+	t.enter(node)
+	defer t.leave()
+
+	// A point removed and inserted again is held by a deleted node as well as a live one.
+	found := false
+	if node.index == i && !node.deleted {
+		node.deleted, found = true, true
+	} else {
+		// Points equal to the split may lie on either side once rebuilt.
+		found = t.remove(&node.left, i, p) || t.remove(&node.right, i, p)
+	}
+	if !found {
+		return false
+	}
+
+	node.invalid++
+	if node.unbalanced() {
+		t.mark(n)
+	}
+	return true
+}
+
+// RemoveBox deletes every point inside box, returning the number deleted. Subtrees lying wholly inside the
+// box are marked in one step.
+func (t *IKDTree) RemoveBox(box *Box) int {
+	removed := t.removeBox(&t.root, box)
+	t.rebalance()
+	return removed
+}
+
+func (t *IKDTree) removeBox(n **ikdNode, box *Box) int {
+	node := *n
+	if node == nil || node.invalid == node.size {
+		return 0
+	}
+	if node.hi[0] < box.Min.X || node.lo[0] > box.Max.X ||
+		node.hi[1] < box.Min.Y || node.lo[1] > box.Max.Y ||
+		node.hi[2] < box.Min.Z || node.lo[2] > box.Max.Z {
+		return 0
+	}
+
+	if box.Contains(&Point3D{X: node.lo[0], Y: node.lo[1], Z: node.lo[2]}) &&
+		box.Contains(&Point3D{X: node.hi[0], Y: node.hi[1], Z: node.hi[2]}) {
+		removed := node.size - node.invalid
+		node.markDeleted()
+		return removed
+	}
+
+	t.enter(node)
+	defer t.leave()
+
+	node.pushDown()
+
+	removed := 0
+	if x, y, z := t.coords.At(node.index); !node.deleted && box.Contains(&Point3D{X: x, Y: y, Z: z}) {
+		node.deleted = true
+		removed++
+	}
+	removed += t.removeBox(&node.left, box) + t.removeBox(&node.right, box)
+
+	node.invalid += removed
+	if removed > 0 && node.unbalanced() {
+		t.mark(n)
+	}
+	return removed
+}
+
+// InBox returns the indices of the points inside box.
+func (t *IKDTree) InBox(box *Box) []int {
+	var indices []int
+	t.inBox(t.root, box, &indices)
+	return indices
+}
+
+func (t *IKDTree) inBox(node *ikdNode, box *Box, indices *[]int) {
+	if node == nil || node.invalid == node.size {
+		return
+	}
+	if node.hi[0] < box.Min.X || node.lo[0] > box.Max.X ||
+		node.hi[1] < box.Min.Y || node.lo[1] > box.Max.Y ||
+		node.hi[2] < box.Min.Z || node.lo[2] > box.Max.Z {
+		return
+	}
+
+	if x, y, z := t.coords.At(node.index); !node.deleted && box.Contains(&Point3D{X: x, Y: y, Z: z}) {
+		*indices = append(*indices, node.index)
+	}
+	t.inBox(node.left, box, indices)
+	t.inBox(node.right, box, indices)
+}
+
+// Nearest returns the index of the point closest to (x, y, z) and its squared distance, or -1 if the tree
+// is empty.
+func (t *IKDTree) Nearest(x, y, z float64) (int, float64) {
+	indices, distances := t.Search(x, y, z, 1, 0)
+	if len(indices) == 0 {
+		return -1, math.Inf(1)
+	}
+	return indices[0], distances[0]
+}
+
+// Search returns the indices of up to k points closest to (x, y, z) that lie within radius of it, and their
+// squared distances, nearest first. A k of zero places no limit on the number of points and a radius of
+// zero places no limit on their distance.
+func (t *IKDTree) Search(x, y, z float64, k int, radius float64) ([]int, []float64) {
+	c := newCollector(k, radius)
+	t.search(t.root, [3]float64{x, y, z}, c)
+	return c.results()
+}
+
+func (t *IKDTree) search(node *ikdNode, q [3]float64, c *collector) {
+	if node == nil || node.invalid == node.size || node.distance(q) > c.bound() {
+		return
+	}
+
+	// Deleted marks may not have been pushed down, in which case the subtree is wholly deleted and skipped above.
+	if !node.deleted {
+		c.add(node.index, squaredDistance(t.coords, node.index, q))
+	}
+
+	near, far := node.left, node.right
+	if near != nil && far != nil && far.distance(q) < near.distance(q) {
+		near, far = far, near
+	}
+	t.search(near, q, c)
+	t.search(far, q, c)
+}
+
+// enter and leave track the path from the root to the node an update is visiting.
+func (t *IKDTree) enter(node *ikdNode) {
+	t.path = append(t.path, node)
+}
+
+func (t *IKDTree) leave() {
+	t.path = t.path[:len(t.path)-1]
+}
+
+// mark records the node being visited, held by n, as the one to rebuild. Updates unwind from the leaves, so
+// the last node marked is the topmost.
+func (t *IKDTree) mark(n **ikdNode) {
+	t.rebuild = n
+	t.ancestors = append(t.ancestors[:0], t.path[:len(t.path)-1]...)
+}
+
+// rebalance rebuilds the subtree found unbalanced by the last update.
+func (t *IKDTree) rebalance() {
+	if t.rebuild == nil {
+		return
+	}
+
+	n := t.rebuild
+	t.rebuild = nil
+
+	var indices []int
+	size := (*n).size
+	(*n).flatten(&indices)
+	*n = t.build(indices)
+
+	// The rebuilt subtree no longer holds its deleted points, so neither do the counts of its ancestors.
+	dropped := size - len(indices)
+	for _, a := range t.ancestors {
+		a.size -= dropped
+		a.invalid -= dropped
+	}
+	t.ancestors = t.ancestors[:0]
+}
+
+// build returns a balanced subtree over the points at the given indices, reordering them.
+func (t *IKDTree) build(indices []int) *ikdNode {
+	if len(indices) == 0 {
+		return nil
+	}
+
+	box := Bounds(t.coords, indices)
+	extent := box.Extent()
+
+	dim := 0
+	if extent.Y > extent.X {
+		dim = 1
+	}
+	if extent.Z > math.Max(extent.X, extent.Y) {
+		dim = 2
+	}
+
+	mid := len(indices) / 2
+	selectNth(t.coords, indices, mid, dim)
+
+	return &ikdNode{
+		index: indices[mid],
+		dim:   dim,
+		left:  t.build(indices[:mid]),
+		right: t.build(indices[mid+1:]),
+		size:  len(indices),
+		lo:    [3]float64{box.Min.X, box.Min.Y, box.Min.Z},
+		hi:    [3]float64{box.Max.X, box.Max.Y, box.Max.Z},
+	}
+}
+
+// flatten appends the points of the subtree that are not deleted to indices.
+func (n *ikdNode) flatten(indices *[]int) {
+	if n == nil || n.invalid == n.size {
+		return
+	}
+
+	n.pushDown()
+	if !n.deleted {
+		*indices = append(*indices, n.index)
+	}
+	n.left.flatten(indices)
+	n.right.flatten(indices)
+}
+
+func (n *ikdNode) markDeleted() {
+	n.deleted, n.treeDeleted = true, true
+	n.invalid = n.size
+}
+
+// pushDown passes a pending deletion of the whole subtree on to the children.
+func (n *ikdNode) pushDown() {
+	if !n.treeDeleted {
+		return
+	}
+	if n.left != nil {
+		n.left.markDeleted()
+	}
+	if n.right != nil {
+		n.right.markDeleted()
+	}
+	n.treeDeleted = false
+}
+
+func (n *ikdNode) unbalanced() bool {
+	if n.size < ikdMinRebuildSize {
+		return false
+	}
+
+	var left, right int
+	if n.left != nil {
+		left = n.left.size
+	}
+	if n.right != nil {
+		right = n.right.size
+	}
+
+	return float64(max(left, right)) > ikdBalance*float64(n.size) || float64(n.invalid) > ikdDeleted*float64(n.size)
+}
+
+// distance returns the squared distance from q to the bounds of the subtree, zero if it lies inside.
+func (n *ikdNode) distance(q [3]float64) float64 {
+	var sum float64
+	for d := range q {
+		var v float64
+		switch {
+		case q[d] < n.lo[d]:
+			v = n.lo[d] - q[d]
+		case q[d] > n.hi[d]:
+			v = q[d] - n.hi[d]
+		}
+		sum += v * v
+	}
+	return sum
+}
@@ -0,0 +1,193 @@
+package point
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// checkIKDNode fails the test unless the counts and bounds of each node match the points below it, and
+// returns the number of points in the subtree and the number deleted. Nodes below a pending deletion hold
+// stale counts until it is pushed down, so only their bounds are checked.
+func checkIKDNode(t *testing.T, tree *IKDTree, n *ikdNode, deletedAbove bool) (int, int) {
+	t.Helper()
+
+	if n == nil {
+		return 0, 0
+	}
+
+	childrenDeleted := deletedAbove || n.treeDeleted
+	leftSize, leftInvalid := checkIKDNode(t, tree, n.left, childrenDeleted)
+	rightSize, rightInvalid := checkIKDNode(t, tree, n.right, childrenDeleted)
+
+	size, invalid := 1+leftSize+rightSize, leftInvalid+rightInvalid
+	if deletedAbove || n.deleted {
+		invalid++
+	}
+	if !deletedAbove && (n.size != size || n.invalid != invalid) {
+		t.Fatalf("node of point %d counts %d points and %d deleted, want %d and %d", n.index, n.size, n.invalid, size, invalid)
+	}
+
+	for _, child := range []*ikdNode{n.left, n.right} {
+		if child == nil {
+			continue
+		}
+		for d := 0; d < 3; d++ {
+			if child.lo[d] < n.lo[d] || child.hi[d] > n.hi[d] {
+				t.Fatalf("bounds of the node of point %d do not cover its children", n.index)
+			}
+		}
+	}
+	x, y, z := tree.coords.At(n.index)
+	if n.distance([3]float64{x, y, z}) > 0 {
+		t.Fatalf("bounds of the node of point %d do not cover the point", n.index)
+	}
+
+	return size, invalid
+}
+
+// randomBox returns a box of up to 8 m across within the region of randomCloud.
+func randomBox(rng *rand.Rand) *Box {
+	var lo, size [3]float64
+	for d := range lo {
+		lo[d] = rng.Float64()*24 - 2
+		size[d] = rng.Float64() * 8
+	}
+	return &Box{
+		Min: Point3D{X: lo[0], Y: lo[1], Z: lo[2]},
+		Max: Point3D{X: lo[0] + size[0], Y: lo[1] + size[1], Z: lo[2] + size[2]},
+	}
+}
+
+// checkInBox fails the test unless InBox returns exactly the points held inside box.
+func checkInBox(t *testing.T, tree *IKDTree, held map[int]bool, box *Box) {
+	t.Helper()
+
+	var want []int
+	for i := range held {
+		x, y, z := tree.coords.At(i)
+		if box.Contains(&Point3D{X: x, Y: y, Z: z}) {
+			want = append(want, i)
+		}
+	}
+	got := tree.InBox(box)
+
+	sort.Ints(want)
+	sort.Ints(got)
+	if len(got) != len(want) {
+		t.Fatalf("got %d points in box %v, want %d", len(got), *box, len(want))
+	}
+	for j := range got {
+		if got[j] != want[j] {
+			t.Fatalf("got point %d in box %v, want %d", got[j], *box, want[j])
+		}
+	}
+}
+
+// TestIKDTreeRandomUpdates interleaves inserts, removals and box removals, which rebuild parts of the tree
+// and leave deletions pending on others, comparing the tree with brute force after every update.
+func TestIKDTreeRandomUpdates(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	points := randomCloud(rng, 4000)
+
+	held := make(map[int]bool)
+	var initial []int
+	for i := 0; i < 500; i++ {
+		initial = append(initial, i)
+		held[i] = true
+	}
+	tree := NewIKDTree(points, 0, initial)
+
+	for update := 0; update < 300; update++ {
+		switch rng.Intn(3) {
+		case 0:
+			// Points removed earlier may be inserted again.
+			var inserted []int
+			for j := 0; j < 1+rng.Intn(60); j++ {
+				if i := rng.Intn(points.Len()); !held[i] {
+					held[i] = true
+					inserted = append(inserted, i)
+				}
+			}
+			tree.Insert(inserted...)
+		case 1:
+			var removed []int
+			for j := 0; j < 1+rng.Intn(60); j++ {
+				i := rng.Intn(points.Len())
+				delete(held, i)
+				removed = append(removed, i)
+			}
+			tree.Remove(removed...)
+		case 2:
+			box := randomBox(rng)
+			want := 0
+			for i := range held {
+				x, y, z := points.At(i)
+				if box.Contains(&Point3D{X: x, Y: y, Z: z}) {
+					delete(held, i)
+					want++
+				}
+			}
+			if got := tree.RemoveBox(box); got != want {
+				t.Fatalf("update %d: removed %d points in box %v, want %d", update, got, *box, want)
+			}
+		}
+
+		if tree.root != nil {
+			checkIKDNode(t, tree, tree.root, false)
+		}
+		checkInBox(t, tree, held, randomBox(rng))
+		checkSearches(t, rng, tree, held)
+	}
+}
+
+// TestIKDTreeDownsampling checks that each voxel keeps the point inserted closest to its center.
+func TestIKDTreeDownsampling(t *testing.T) {
+	const voxelSize = 0.5
+
+	rng := rand.New(rand.NewSource(5))
+	points := randomCloud(rng, 3000)
+
+	indices := make([]int, points.Len())
+	for i := range indices {
+		indices[i] = i
+	}
+	tree := NewIKDTree(points, voxelSize, indices[:1000])
+	tree.Insert(indices[1000:]...)
+
+	type voxel [3]int
+	key := func(i int) (voxel, float64) {
+		x, y, z := points.At(i)
+		var v voxel
+		var d float64
+		for j, c := range [3]float64{x, y, z} {
+			v[j] = int(math.Floor(c / voxelSize))
+			offset := c - (float64(v[j])+0.5)*voxelSize
+			d += offset * offset
+		}
+		return v, d
+	}
+
+	closest := make(map[voxel]float64)
+	for _, i := range indices {
+		v, d := key(i)
+		if best, ok := closest[v]; !ok || d < best {
+			closest[v] = d
+		}
+	}
+
+	kept := tree.InBox(&Box{Min: Point3D{X: -100, Y: -100, Z: -100}, Max: Point3D{X: 100, Y: 100, Z: 100}})
+	if len(kept) != tree.Len() || tree.Len() != len(closest) {
+		t.Fatalf("tree holds %d points, %d found in a box around them, want one for each of %d voxels", tree.Len(), len(kept), len(closest))
+	}
+	seen := make(map[voxel]bool)
+	for _, i := range kept {
+		v, d := key(i)
+		if seen[v] || d != closest[v] {
+			t.Fatalf("voxel %v keeps point %d at %v from its center, want a single point at %v", v, i, d, closest[v])
+		}
+		seen[v] = true
+	}
+	checkIKDNode(t, tree, tree.root, false)
+}
@@ -0,0 +1,304 @@
+package point
+
+import (
+	"math"
+)
+
+const (
+	// octLeafSize is the number of points a leaf of an Octree holds before it is split.
+	octLeafSize = 16
+
+	// octMinHalfSize stops leaves holding many coincident points from being split indefinitely.
+	octMinHalfSize = 1e-6
+)
+
+// Octree is an adaptive octree over the points of a cloud. Leaves split as points are inserted and merge
+// back as they are removed, and the root grows to cover points inserted outside it, so the tree never needs
+// rebuilding. Points with non-finite coordinates are ignored.
+type Octree struct {
+	coords Coordinates
+	root   *octNode
+	count  int
+}
+
+// octNode is a cube holding the points in a leaf, or split into up to eight children.
+type octNode struct {
+	center [3]float64
+	half   float64
+
+	// Number of points under the node.
+	count int
+
+	indices  []int
+	children *[8]*octNode
+}
+
+// NewOctree returns an octree holding the points of coords at the given indices, or every point if indices
+// is nil.
+func NewOctree(coords Coordinates, indices []int) *Octree {
+	o := &Octree{coords: coords}
+
+	// Size the root to the initial points so that it does not have to grow while they are inserted.
+	if box := Bounds(coords, indices); coords.Len() > 0 && (indices == nil || len(indices) > 0) {
+		extent := box.Extent()
+		half := math.Max(math.Max(extent.X, extent.Y), extent.Z)/2 + octMinHalfSize
+		if !math.IsInf(half, 0) && !math.IsNaN(half) {
+			o.root = &octNode{
+				center: [3]float64{(box.Min.X + box.Max.X) / 2, (box.Min.Y + box.Max.Y) / 2, (box.Min.Z + box.Max.Z) / 2},
+				half:   half,
+			}
+		}
+	}
+
+	if indices == nil {
+		for i := 0; i < coords.Len(); i++ {
+			o.Insert(i)
+		}
+	} else {
+		o.Insert(indices...)
+	}
+
+	return o
+}
+
+// Coordinates returns the cloud the octree indexes.
+func (o *Octree) Coordinates() Coordinates {
+	return o.coords
+}
+
+// Len returns the number of points in the octree.
+func (o *Octree) Len() int {
+	return o.count
+}
+
+func (o *Octree) position(i int) ([3]float64, bool) {
+	x, y, z := o.coords.At(i)
+	p := [3]float64{x, y, z}
+	for _, v := range p {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return p, false
+		}
+	}
+	return p, true
+}
+
+// Insert adds the points of the cloud at the given indices. A point must not be inserted twice.
+func (o *Octree) Insert(indices ...int) {
+	for _, i := range indices {
+		p, ok := o.position(i)
+		if !ok {
+			continue
+		}
+
+		if o.root == nil {
+			o.root = &octNode{center: p, half: 1}
+		}
+		for !o.root.contains(p) {
+			o.grow(p)
+		}
+
+		o.root.insert(o.coords, i, p)
+		o.count++
+	}
+}
+
+// grow doubles the size of the root towards p.
+func (o *Octree) grow(p [3]float64) {
+	old := o.root
+
+	var center [3]float64
+	for d := range center {
+		if p[d] >= old.center[d] {
+			center[d] = old.center[d] + old.half
+		} else {
+			center[d] = old.center[d] - old.half
+		}
+	}
+
+	root := &octNode{center: center, half: 2 * old.half, count: old.count, children: &[8]*octNode{}}
+	root.children[root.octant(old.center)] = old
+	o.root = root
+}
+
+// Remove removes the points of the cloud at the given indices. Points not in the octree are ignored.
+func (o *Octree) Remove(indices ...int) {
+	for _, i := range indices {
+		p, ok := o.position(i)
+		if !ok || o.root == nil || !o.root.contains(p) {
+			continue
+		}
+		if o.root.remove(i, p) {
+			o.count--
+		}
+	}
+}
+
+// Nearest returns the index of the point closest to (x, y, z) and its squared distance, or -1 if the octree
+// is empty.
+func (o *Octree) Nearest(x, y, z float64) (int, float64) {
+	indices, distances := o.Search(x, y, z, 1, 0)
+	if len(indices) == 0 {
+		return -1, math.Inf(1)
+	}
+	return indices[0], distances[0]
+}
+
+// Search returns the indices of up to k points closest to (x, y, z) that lie within radius of it, and their
+// squared distances, nearest first. A k of zero places no limit on the number of points and a radius of
+// zero places no limit on their distance.
+func (o *Octree) Search(x, y, z float64, k int, radius float64) ([]int, []float64) {
+	if o.count == 0 {
+		return nil, nil
+	}
+
+	c := newCollector(k, radius)
+	o.search(o.root, [3]float64{x, y, z}, c)
+
+	return c.results()
+}
+
+func (o *Octree) search(n *octNode, q [3]float64, c *collector) {
+	if n.children == nil {
+		for _, i := range n.indices {
+			c.add(i, squaredDistance(o.coords, i, q))
+		}
+		return
+	}
+
+	// Visit the children nearest first so that the bound tightens quickly.
+	var order [8]*octNode
+	var dists [8]float64
+	visited := 0
+	for _, child := range n.children {
+		if child == nil || child.count == 0 {
+			continue
+		}
+		d := child.distance(q)
+		j := visited
+		for ; j > 0 && dists[j-1] > d; j-- {
+			order[j], dists[j] = order[j-1], dists[j-1]
+		}
+		order[j], dists[j] = child, d
+		visited++
+	}
+
+	for j := 0; j < visited; j++ {
+		if dists[j] > c.bound() {
+			break
+		}
+		o.search(order[j], q, c)
+	}
+}
+
+func (n *octNode) contains(p [3]float64) bool {
+	for d := range p {
+		if math.Abs(p[d]-n.center[d]) > n.half {
+			return false
+		}
+	}
+	return true
+}
+
+// octant returns the position among the children of the child containing p.
+func (n *octNode) octant(p [3]float64) int {
+	var octant int
+	for d := range p {
+		if p[d] >= n.center[d] {
+			octant |= 1 << d
+		}
+	}
+	return octant
+}
+
+// distance returns the squared distance from q to the cube of the node, zero if it lies inside.
+func (n *octNode) distance(q [3]float64) float64 {
+	var sum float64
+	for d := range q {
+		if v := math.Abs(q[d]-n.center[d]) - n.half; v > 0 {
+			sum += v * v
+		}
+	}
+	return sum
+}
+
+func (n *octNode) child(octant int) *octNode {
+	if n.children[octant] == nil {
+		half := n.half / 2
+		center := n.center
+		for d := range center {
+			if octant&(1<<d) != 0 {
+				center[d] += half
+			} else {
+				center[d] -= half
+			}
+		}
+		n.children[octant] = &octNode{center: center, half: half}
+	}
+	return n.children[octant]
+}
+
+func (n *octNode) insert(coords Coordinates, i int, p [3]float64) {
+	n.count++
+
+	if n.children != nil {
+		n.child(n.octant(p)).insert(coords, i, p)
+		return
+	}
+
+	n.indices = append(n.indices, i)
+	if len(n.indices) <= octLeafSize || n.half <= octMinHalfSize {
+		return
+	}
+
+	// Split the leaf.
+	indices := n.indices
+	n.indices = nil
+	n.children = &[8]*octNode{}
+	for _, j := range indices {
+		x, y, z := coords.At(j)
+		q := [3]float64{x, y, z}
+		n.child(n.octant(q)).insert(coords, j, q)
+	}
+}
+
+// remove removes point i at p from under the node, reporting whether it was found.
+func (n *octNode) remove(i int, p [3]float64) bool {
+	if n.children == nil {
+		for j, held := range n.indices {
+			if held == i {
+				n.indices[j] = n.indices[len(n.indices)-1]
+				n.indices = n.indices[:len(n.indices)-1]
+				n.count--
+				return true
+			}
+		}
+		return false
+	}
+
+	child := n.children[n.octant(p)]
+	if child == nil || !child.remove(i, p) {
+		return false
+	}
+	n.count--
+
+	// Merge the children back once they fit in a leaf.
+	if n.count <= octLeafSize {
+		n.indices = n.gather(make([]int, 0, n.count))
+		n.children = nil
+	}
+
+	return true
+}
+
+// gather appends the points under the node to indices.
+func (n *octNode) gather(indices []int) []int {
+	if n.children == nil {
+		return append(indices, n.indices...)
+	}
+	for _, child := range n.children {
+		if child != nil {
+			indices = child.gather(indices)
+		}
+	}
+	return indices
+}
@@ -13,6 +13,24 @@ type Point3D struct {
 	Nx float64 `json:"nx"`
 	Ny float64 `json:"ny"`
 	Nz float64 `json:"nz"`
+
+	// Intensity - only used if provided by the sensor.
+	Intensity float64 `json:"intensity"`
+
+	// Color - only used if provided by the sensor.
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+
+	// Acquisition time in seconds - only used if provided by the sensor. The time base is that of the source,
+	// e.g. Unix time for lidar frames, GPS time for LAS files or the offset from the message stamp for ROS clouds.
+	Time float64 `json:"time"`
+
+	// Laser ring, 0 being the lowest beam - only used if provided by the sensor.
+	Ring int `json:"ring"`
+
+	// Classification or semantic label - only used if provided.
+	Label int `json:"label"`
 }
 
 func (p *Point3D) Subtract(q *Point3D) *Point3D {
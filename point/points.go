@@ -11,11 +11,13 @@ func (p *Points3D) Raw() []*Point3D {
 	return *p
 }
 
+// Copy returns a deep copy of the points, including their attributes.
 func (p *Points3D) Copy() *Points3D {
 	points := make(Points3D, len(*p))
 
 	for i, point := range *p {
-		points[i] = &Point3D{X: point.X, Y: point.Y, Z: point.Z}
+		copied := *point
+		points[i] = &copied
 	}
 
 	return &points
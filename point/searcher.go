@@ -0,0 +1,145 @@
+package point
+
+import (
+	"container/heap"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// NeighborSearcher answers nearest neighbor queries over the points of a cloud. Results are indices into
+// Coordinates and squared Euclidean distances, nearest first.
+type NeighborSearcher interface {
+	// Coordinates returns the cloud the searcher indexes.
+	Coordinates() Coordinates
+
+	// Len returns the number of points held by the searcher.
+	Len() int
+
+	// Nearest returns the index of the point closest to (x, y, z) and its squared distance, or -1 if the
+	// searcher is empty.
+	Nearest(x, y, z float64) (int, float64)
+
+	// Search returns up to k points closest to (x, y, z) that lie within radius of it. A k of zero places no
+	// limit on the number of points and a radius of zero places no limit on their distance.
+	Search(x, y, z float64, k int, radius float64) ([]int, []float64)
+}
+
+// DynamicSearcher is a NeighborSearcher whose points can be inserted and removed without rebuilding it, as
+// needed to maintain a map during scan-to-map odometry. Points are referred to by their index in
+// Coordinates, so new points are appended to the cloud before being inserted, and a point's position must
+// not change while it is held.
+type DynamicSearcher interface {
+	NeighborSearcher
+	Insert(indices ...int)
+	Remove(indices ...int)
+}
+
+var (
+	_ NeighborSearcher = &KDTree{}
+	_ DynamicSearcher  = &VoxelMap{}
+	_ DynamicSearcher  = &Octree{}
+	_ DynamicSearcher  = &IKDTree{}
+)
+
+// SearchBatch runs Search for every point of queries, spreading the queries over the available CPUs. The
+// searcher must not be modified while the batch runs.
+func SearchBatch(s NeighborSearcher, queries Coordinates, k int, radius float64) ([][]int, [][]float64) {
+	indices := make([][]int, queries.Len())
+	distances := make([][]float64, queries.Len())
+
+	workers := min(runtime.GOMAXPROCS(0), queries.Len())
+	chunk := (queries.Len() + workers - 1) / max(workers, 1)
+
+	var wg sync.WaitGroup
+	for start := 0; start < queries.Len(); start += chunk {
+		end := min(start+chunk, queries.Len())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				x, y, z := queries.At(i)
+				indices[i], distances[i] = s.Search(x, y, z, k, radius)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return indices, distances
+}
+
+// collector gathers the results of a search, keeping at most k neighbors if k is positive and only those
+// within maxDist.
+type collector struct {
+	k       int
+	maxDist float64
+	heap    neighborHeap
+}
+
+func newCollector(k int, radius float64) *collector {
+	c := &collector{k: k, maxDist: math.Inf(1)}
+	if radius > 0 {
+		c.maxDist = radius * radius
+	}
+	return c
+}
+
+func (c *collector) add(i int, d float64) {
+	if d > c.maxDist {
+		return
+	}
+	if c.k <= 0 || c.heap.Len() < c.k {
+		heap.Push(&c.heap, neighbor{index: i, dist: d})
+	} else if d < c.heap[0].dist {
+		c.heap[0] = neighbor{index: i, dist: d}
+		heap.Fix(&c.heap, 0)
+	}
+}
+
+// bound returns the squared distance beyond which points can no longer be collected.
+func (c *collector) bound() float64 {
+	if c.k > 0 && c.heap.Len() == c.k {
+		return math.Min(c.maxDist, c.heap[0].dist)
+	}
+	return c.maxDist
+}
+
+// results returns the collected neighbors, nearest first.
+func (c *collector) results() ([]int, []float64) {
+	// Popping the max-heap yields the farthest neighbor first.
+	indices := make([]int, c.heap.Len())
+	distances := make([]float64, c.heap.Len())
+	for i := len(indices) - 1; i >= 0; i-- {
+		nb := heap.Pop(&c.heap).(neighbor)
+		indices[i], distances[i] = nb.index, nb.dist
+	}
+	return indices, distances
+}
+
+type neighbor struct {
+	index int
+	dist  float64
+}
+
+// neighborHeap is a max-heap of neighbors ordered by distance.
+type neighborHeap []neighbor
+
+func (h neighborHeap) Len() int           { return len(h) }
+func (h neighborHeap) Less(i, j int) bool { return h[i].dist > h[j].dist }
+func (h neighborHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap) Push(x any)        { *h = append(*h, x.(neighbor)) }
+
+func (h *neighborHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// squaredDistance returns the squared distance between the ith point of coords and q.
+func squaredDistance(coords Coordinates, i int, q [3]float64) float64 {
+	x, y, z := coords.At(i)
+	dx, dy, dz := x-q[0], y-q[1], z-q[2]
+	return dx*dx + dy*dy + dz*dz
+}
@@ -0,0 +1,237 @@
+package point
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// randomCloud returns n points in clusters spread over a 20 m cube. Coordinates are rounded to 5 cm so that
+// some points coincide and distances tie.
+func randomCloud(rng *rand.Rand, n int) *Points3D {
+	centers := make([][3]float64, 8)
+	for i := range centers {
+		centers[i] = [3]float64{rng.Float64() * 20, rng.Float64() * 20, rng.Float64() * 20}
+	}
+
+	points := make(Points3D, n)
+	for i := range points {
+		c := centers[rng.Intn(len(centers))]
+		round := func(v float64) float64 { return math.Round(v*20) / 20 }
+		points[i] = &Point3D{
+			X: round(c[0] + rng.NormFloat64()),
+			Y: round(c[1] + rng.NormFloat64()),
+			Z: round(c[2] + rng.NormFloat64()),
+		}
+	}
+	return &points
+}
+
+// randomQuery returns a query near the cloud, or now and then far outside it.
+func randomQuery(rng *rand.Rand) [3]float64 {
+	scale := 24.0
+	if rng.Intn(8) == 0 {
+		scale = 200
+	}
+	return [3]float64{rng.Float64()*scale - 2, rng.Float64()*scale - 2, rng.Float64()*scale - 2}
+}
+
+// bruteForce returns the squared distances Search should return for the points held, nearest first.
+func bruteForce(coords Coordinates, held map[int]bool, q [3]float64, k int, radius float64) []float64 {
+	var distances []float64
+	for i := range held {
+		if d := squaredDistance(coords, i, q); radius <= 0 || d <= radius*radius {
+			distances = append(distances, d)
+		}
+	}
+	sort.Float64s(distances)
+	if k > 0 && len(distances) > k {
+		distances = distances[:k]
+	}
+	return distances
+}
+
+// checkSearch fails the test unless a search of s matches a brute force search of the points held. Indices
+// are compared through their distances, as points at the same distance may be returned in any order.
+func checkSearch(t *testing.T, s NeighborSearcher, held map[int]bool, q [3]float64, k int, radius float64) {
+	t.Helper()
+
+	indices, distances := s.Search(q[0], q[1], q[2], k, radius)
+	want := bruteForce(s.Coordinates(), held, q, k, radius)
+
+	if len(indices) != len(want) || len(distances) != len(want) {
+		t.Fatalf("search at %v with k %d and radius %v returned %d points, want %d", q, k, radius, len(indices), len(want))
+	}
+	seen := make(map[int]bool)
+	for j, i := range indices {
+		if !held[i] || seen[i] {
+			t.Fatalf("search at %v returned point %d, which is not held or returned twice", q, i)
+		}
+		seen[i] = true
+		if distances[j] != want[j] || squaredDistance(s.Coordinates(), i, q) != want[j] {
+			t.Fatalf("search at %v with k %d and radius %v returned distance %v at %d, want %v", q, k, radius, distances[j], j, want[j])
+		}
+	}
+
+	if nearest, d := s.Nearest(q[0], q[1], q[2]); len(held) == 0 && nearest != -1 || len(held) > 0 && d != bruteForce(s.Coordinates(), held, q, 1, 0)[0] {
+		t.Fatalf("nearest to %v is point %d at %v", q, nearest, d)
+	}
+}
+
+// checkSearches runs random searches against s.
+func checkSearches(t *testing.T, rng *rand.Rand, s NeighborSearcher, held map[int]bool) {
+	t.Helper()
+
+	if s.Len() != len(held) {
+		t.Fatalf("holds %d points, want %d", s.Len(), len(held))
+	}
+	for j := 0; j < 10; j++ {
+		k := []int{0, 1, 5, 40}[rng.Intn(4)]
+		radius := []float64{0, 0.3, 1.5, 6}[rng.Intn(4)]
+		if k == 0 && radius == 0 {
+			radius = 2
+		}
+		checkSearch(t, s, held, randomQuery(rng), k, radius)
+	}
+}
+
+// dynamicSearchers returns a constructor for each DynamicSearcher backend.
+func dynamicSearchers() map[string]func(coords Coordinates, indices []int) DynamicSearcher {
+	return map[string]func(coords Coordinates, indices []int) DynamicSearcher{
+		"VoxelMap/small voxels": func(coords Coordinates, indices []int) DynamicSearcher { return NewVoxelMap(coords, 0.25, indices) },
+		"VoxelMap/large voxels": func(coords Coordinates, indices []int) DynamicSearcher { return NewVoxelMap(coords, 4, indices) },
+		"Octree":                func(coords Coordinates, indices []int) DynamicSearcher { return NewOctree(coords, indices) },
+		"IKDTree":               func(coords Coordinates, indices []int) DynamicSearcher { return NewIKDTree(coords, 0, indices) },
+	}
+}
+
+// TestDynamicSearchers interleaves inserts and removals, comparing searches with brute force after each.
+func TestDynamicSearchers(t *testing.T) {
+	for name, build := range dynamicSearchers() {
+		t.Run(name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			points := randomCloud(rng, 3000)
+
+			// Start from a small region, so that later points lie outside the initial bounds.
+			held := make(map[int]bool)
+			var initial []int
+			for i, p := range points.Raw() {
+				if p.X < 8 && p.Y < 8 && p.Z < 8 && len(initial) < 50 {
+					initial = append(initial, i)
+					held[i] = true
+				}
+			}
+			s := build(points, initial)
+			checkSearches(t, rng, s, held)
+
+			for round := 0; round < 30; round++ {
+				var inserted []int
+				for j := 0; j < 150; j++ {
+					if i := rng.Intn(points.Len()); !held[i] {
+						held[i] = true
+						inserted = append(inserted, i)
+					}
+				}
+				s.Insert(inserted...)
+
+				// Removals include points never inserted or already removed, which are ignored.
+				var removed []int
+				for j := 0; j < 120; j++ {
+					i := rng.Intn(points.Len())
+					delete(held, i)
+					removed = append(removed, i)
+				}
+				s.Remove(removed...)
+
+				checkSearches(t, rng, s, held)
+			}
+
+			// Empty the searcher.
+			for i := range held {
+				s.Remove(i)
+				delete(held, i)
+			}
+			checkSearches(t, rng, s, held)
+		})
+	}
+}
+
+func TestVoxelMapSkipsNonFinitePoints(t *testing.T) {
+	points := &Points3D{
+		{X: 1, Y: 2, Z: 3},
+		{X: math.NaN(), Y: 0, Z: 0},
+		{X: 0, Y: math.Inf(1), Z: 0},
+		{X: 0, Y: 0, Z: math.Inf(-1)},
+		{X: -4, Y: 0.5, Z: 2},
+	}
+
+	m := NewVoxelMap(points, 1, nil)
+	if m.Len() != 2 {
+		t.Fatalf("got %d points, want the 2 finite ones", m.Len())
+	}
+	if m.lo != (voxelKey{-4, 0, 2}) || m.hi != (voxelKey{1, 2, 3}) {
+		t.Errorf("got voxel bounds %v to %v, want the bounds of the finite points", m.lo, m.hi)
+	}
+
+	checkSearch(t, m, map[int]bool{0: true, 4: true}, [3]float64{0, 0, 0}, 0, 0)
+	if indices, _ := m.Search(math.NaN(), 0, 0, 1, 0); len(indices) != 0 {
+		t.Errorf("got %d neighbors of a NaN query, want none", len(indices))
+	}
+
+	m.Remove(1, 2, 3, 0)
+	if m.Len() != 1 {
+		t.Errorf("got %d points after removing, want 1", m.Len())
+	}
+}
+
+// TestVoxelMapShells checks searches that stop after a few shells and those that fall back to visiting the
+// occupied voxels, against points spread over a large empty region.
+func TestVoxelMapShells(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+
+	points := make(Points3D, 0)
+	held := make(map[int]bool)
+	for i := 0; i < 40; i++ {
+		points = append(points, &Point3D{X: rng.Float64() * 100, Y: rng.Float64() * 100, Z: rng.Float64() * 2})
+		held[i] = true
+	}
+
+	m := NewVoxelMap(&points, 0.5, nil)
+	for j := 0; j < 200; j++ {
+		q := [3]float64{rng.Float64()*140 - 20, rng.Float64()*140 - 20, rng.Float64()*10 - 5}
+		checkSearch(t, m, held, q, 1+rng.Intn(3), 0)
+		checkSearch(t, m, held, q, 0, 10)
+	}
+}
+
+func TestOctreeGrowAndMerge(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	points := randomCloud(rng, 400)
+
+	// Points far away on every side make the root grow in each direction.
+	for _, p := range [][3]float64{{-500, 0, 0}, {0, 900, 0}, {0, 0, -3000}, {1e4, 1e4, 1e4}} {
+		points.Merge(&Points3D{{X: p[0], Y: p[1], Z: p[2]}})
+	}
+
+	o := NewOctree(points, []int{0})
+	held := map[int]bool{0: true}
+	for i := 1; i < points.Len(); i++ {
+		o.Insert(i)
+		held[i] = true
+	}
+	checkSearches(t, rng, o, held)
+	if !o.root.contains([3]float64{1e4, 1e4, 1e4}) || !o.root.contains([3]float64{-500, 0, -3000}) {
+		t.Errorf("root of half size %v at %v does not cover every point", o.root.half, o.root.center)
+	}
+
+	// Removing all but a few points merges the leaves back into the root.
+	for i := 0; i < points.Len()-octLeafSize; i++ {
+		o.Remove(i)
+		delete(held, i)
+	}
+	checkSearches(t, rng, o, held)
+	if o.root.children != nil || len(o.root.indices) != octLeafSize || o.root.count != octLeafSize {
+		t.Errorf("got a root with %d points and children %v, want a single leaf of %d", len(o.root.indices), o.root.children != nil, octLeafSize)
+	}
+}
@@ -0,0 +1,359 @@
+package point
+
+import (
+	"container/heap"
+	"math"
+)
+
+// kdLeafSize is the largest number of points held by a leaf of a KDTree.
+const kdLeafSize = 8
+
+// KDTree is a static k-d tree over the positions of a cloud, answering nearest, k-nearest, radius and
+// hybrid queries. It holds point indices rather than points, so it works on any Coordinates, Points3D
+// included, without copying them or allocating per point. Queries return indices into the cloud and squared
+// Euclidean distances, as returned by Point3D.Distance, and are safe to run concurrently.
+type KDTree struct {
+	coords  Coordinates
+	indices []int
+	nodes   []kdNode
+}
+
+// kdNode is either a leaf covering indices[start:end], or splits its points on dim at split.
+type kdNode struct {
+	start, end  int
+	dim         int
+	split       float64
+	left, right int
+}
+
+// NewKDTree builds a tree over the points of coords at the given indices, or over every point if indices is nil.
+func NewKDTree(coords Coordinates, indices []int) *KDTree {
+	if indices == nil {
+		indices = make([]int, coords.Len())
+		for i := range indices {
+			indices[i] = i
+		}
+	} else {
+		indices = append([]int(nil), indices...)
+	}
+
+	t := &KDTree{coords: coords, indices: indices}
+	if len(indices) > 0 {
+		t.build(0, len(indices))
+	}
+	return t
+}
+
+// Coordinates returns the cloud the tree was built over.
+func (t *KDTree) Coordinates() Coordinates {
+	return t.coords
+}
+
+// Len returns the number of points in the tree.
+func (t *KDTree) Len() int {
+	return len(t.indices)
+}
+
+// coordinate returns the position of the ith point of coords along dim.
+func coordinate(coords Coordinates, i int, dim int) float64 {
+	x, y, z := coords.At(i)
+	switch dim {
+	case 0:
+		return x
+	case 1:
+		return y
+	default:
+		return z
+	}
+}
+
+// build creates the node for indices[start:end], returning its position in nodes.
+func (t *KDTree) build(start, end int) int {
+	node := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{start: start, end: end, left: -1, right: -1})
+
+	if end-start <= kdLeafSize {
+		return node
+	}
+
+	// Split the widest dimension at its median.
+	lo := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}
+	hi := [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, i := range t.indices[start:end] {
+		x, y, z := t.coords.At(i)
+		for d, v := range [3]float64{x, y, z} {
+			lo[d] = math.Min(lo[d], v)
+			hi[d] = math.Max(hi[d], v)
+		}
+	}
+	dim := 0
+	for d := 1; d < 3; d++ {
+		if hi[d]-lo[d] > hi[dim]-lo[dim] {
+			dim = d
+		}
+	}
+	if hi[dim] == lo[dim] {
+		return node // Every point is identical.
+	}
+
+	mid := start + (end-start)/2
+	selectNth(t.coords, t.indices[start:end], mid-start, dim)
+
+	// Read the split before the children reorder their points.
+	t.nodes[node].dim = dim
+	t.nodes[node].split = coordinate(t.coords, t.indices[mid], dim)
+
+	left := t.build(start, mid)
+	right := t.build(mid, end)
+	t.nodes[node].left, t.nodes[node].right = left, right
+
+	return node
+}
+
+// selectNth partially sorts indices so that position n holds the point of coords that would be there if they
+// were sorted on dim, with no greater point before it and no lesser point after it.
+func selectNth(coords Coordinates, indices []int, n, dim int) {
+	start, end := 0, len(indices)
+	for end-start > 1 {
+		pivot := coordinate(coords, indices[start+(end-start)/2], dim)
+
+		// Three-way partition around the pivot.
+		lt, i, gt := start, start, end
+		for i < gt {
+			v := coordinate(coords, indices[i], dim)
+			switch {
+			case v < pivot:
+				indices[lt], indices[i] = indices[i], indices[lt]
+				lt++
+				i++
+			case v > pivot:
+				gt--
+				indices[gt], indices[i] = indices[i], indices[gt]
+			default:
+				i++
+			}
+		}
+
+		switch {
+		case n < lt:
+			end = lt
+		case n >= gt:
+			start = gt
+		default:
+			return
+		}
+	}
+}
+
+// Nearest returns the index of the point closest to (x, y, z) and its squared distance, or -1 if the tree
+// is empty.
+func (t *KDTree) Nearest(x, y, z float64) (int, float64) {
+	if len(t.nodes) == 0 {
+		return -1, math.Inf(1)
+	}
+
+	best, bestDist := -1, math.Inf(1)
+	t.nearest(0, [3]float64{x, y, z}, &best, &bestDist)
+	return best, bestDist
+}
+
+func (t *KDTree) nearest(node int, q [3]float64, best *int, bestDist *float64) {
+	n := &t.nodes[node]
+
+	if n.left < 0 {
+		for _, i := range t.indices[n.start:n.end] {
+			if d := squaredDistance(t.coords, i, q); d < *bestDist {
+				*best, *bestDist = i, d
+			}
+		}
+		return
+	}
+
+	diff := q[n.dim] - n.split
+	near, far := n.left, n.right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	t.nearest(near, q, best, bestDist)
+	if diff*diff < *bestDist {
+		t.nearest(far, q, best, bestDist)
+	}
+}
+
+// NearestApprox returns the index of a point close to (x, y, z) and its squared distance, or -1 if the tree
+// is empty, searching less of the tree than Nearest. The point returned is at most 1 + epsilon times further
+// than the nearest, and if maxLeaves is positive the search stops after checking that many leaves, nearest
+// first, in which case there is no bound on its distance. An epsilon and maxLeaves of zero give the same
+// result as Nearest.
+func (t *KDTree) NearestApprox(x, y, z, epsilon float64, maxLeaves int) (int, float64) {
+	if len(t.nodes) == 0 {
+		return -1, math.Inf(1)
+	}
+
+	s := approxSearch{
+		q:         [3]float64{x, y, z},
+		scale:     1 / ((1 + epsilon) * (1 + epsilon)),
+		maxLeaves: maxLeaves,
+		best:      -1,
+		bestDist:  math.Inf(1),
+	}
+	if maxLeaves > 0 {
+		t.nearestApproxBranches(&s)
+	} else {
+		t.nearestApprox(0, &s)
+	}
+
+	return s.best, s.bestDist
+}
+
+type approxSearch struct {
+	q [3]float64
+
+	// Factor applied to the best squared distance when deciding whether to visit a branch.
+	scale float64
+
+	maxLeaves, leaves int
+	best              int
+	bestDist          float64
+}
+
+// check compares the points of a leaf with the best found so far.
+func (s *approxSearch) check(t *KDTree, leaf int) {
+	n := &t.nodes[leaf]
+	for _, i := range t.indices[n.start:n.end] {
+		if d := squaredDistance(t.coords, i, s.q); d < s.bestDist {
+			s.best, s.bestDist = i, d
+		}
+	}
+	s.leaves++
+}
+
+func (t *KDTree) nearestApprox(node int, s *approxSearch) {
+	n := &t.nodes[node]
+
+	if n.left < 0 {
+		s.check(t, node)
+		return
+	}
+
+	diff := s.q[n.dim] - n.split
+	near, far := n.left, n.right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	t.nearestApprox(near, s)
+	if diff*diff < s.bestDist*s.scale {
+		t.nearestApprox(far, s)
+	}
+}
+
+// nearestApproxBranches checks leaves in order of the distance from the query to their cell, so that a limit on
+// the number of leaves is spent on those most likely to hold the nearest point rather than on the neighbors of
+// the first leaf reached.
+func (t *KDTree) nearestApproxBranches(s *approxSearch) {
+	branches := branchHeap{{node: 0}}
+
+	for branches.Len() > 0 && s.leaves < s.maxLeaves {
+		b := heap.Pop(&branches).(branch)
+		if b.dist >= s.bestDist*s.scale {
+			return
+		}
+
+		// Descend to the leaf containing the query, queuing the branches passed by.
+		node := b.node
+		for t.nodes[node].left >= 0 {
+			n := &t.nodes[node]
+			diff := s.q[n.dim] - n.split
+			near, far := n.left, n.right
+			if diff >= 0 {
+				near, far = far, near
+			}
+
+			heap.Push(&branches, branch{node: far, dist: math.Max(b.dist, diff*diff)})
+			node = near
+		}
+
+		s.check(t, node)
+	}
+}
+
+// branch is a node of a KDTree and a lower bound on the squared distance from the query to its points.
+type branch struct {
+	node int
+	dist float64
+}
+
+// branchHeap is a min-heap of branches ordered by distance.
+type branchHeap []branch
+
+func (h branchHeap) Len() int           { return len(h) }
+func (h branchHeap) Less(i, j int) bool { return h[i].dist < h[j].dist }
+func (h branchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *branchHeap) Push(x any)        { *h = append(*h, x.(branch)) }
+
+func (h *branchHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// NearestK returns the indices of the k points closest to (x, y, z) and their squared distances, nearest first.
+func (t *KDTree) NearestK(x, y, z float64, k int) ([]int, []float64) {
+	if k <= 0 {
+		return nil, nil
+	}
+	return t.Search(x, y, z, k, 0)
+}
+
+// NearestRadius returns the indices of the points within radius of (x, y, z) and their squared distances,
+// nearest first.
+func (t *KDTree) NearestRadius(x, y, z, radius float64) ([]int, []float64) {
+	if radius <= 0 {
+		return nil, nil
+	}
+	return t.Search(x, y, z, 0, radius)
+}
+
+// Search returns the indices of up to k points closest to (x, y, z) that lie within radius of it, and their
+// squared distances, nearest first. A k of zero places no limit on the number of points and a radius of
+// zero places no limit on their distance.
+func (t *KDTree) Search(x, y, z float64, k int, radius float64) ([]int, []float64) {
+	if len(t.nodes) == 0 {
+		return nil, nil
+	}
+
+	c := newCollector(k, radius)
+	t.search(0, [3]float64{x, y, z}, c)
+
+	return c.results()
+}
+
+// SearchBatch runs Search for every point of queries, spreading the queries over the available CPUs.
+func (t *KDTree) SearchBatch(queries Coordinates, k int, radius float64) ([][]int, [][]float64) {
+	return SearchBatch(t, queries, k, radius)
+}
+
+func (t *KDTree) search(node int, q [3]float64, c *collector) {
+	n := &t.nodes[node]
+
+	if n.left < 0 {
+		for _, i := range t.indices[n.start:n.end] {
+			c.add(i, squaredDistance(t.coords, i, q))
+		}
+		return
+	}
+
+	diff := q[n.dim] - n.split
+	near, far := n.left, n.right
+	if diff >= 0 {
+		near, far = far, near
+	}
+
+	t.search(near, q, c)
+	if diff*diff <= c.bound() {
+		t.search(far, q, c)
+	}
+}
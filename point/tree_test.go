@@ -0,0 +1,91 @@
+package point
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// countingCoordinates counts the positions read from a cloud.
+type countingCoordinates struct {
+	Coordinates
+	reads int
+}
+
+func (c *countingCoordinates) At(i int) (float64, float64, float64) {
+	c.reads++
+	return c.Coordinates.At(i)
+}
+
+func TestNearestApprox(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	points := randomCloud(rng, 5000)
+	tree := NewKDTree(points, nil)
+
+	queries := make([][3]float64, 500)
+	for i := range queries {
+		queries[i] = randomQuery(rng)
+	}
+
+	for _, epsilon := range []float64{0, 0.1, 0.5, 2} {
+		var further int
+		for _, q := range queries {
+			_, want := tree.Nearest(q[0], q[1], q[2])
+			i, got := tree.NearestApprox(q[0], q[1], q[2], epsilon, 0)
+
+			if d := squaredDistance(points, i, q); d != got {
+				t.Fatalf("epsilon %v: got squared distance %v to point %d, which lies %v away", epsilon, got, i, d)
+			}
+			if bound := (1 + epsilon) * (1 + epsilon) * want; got > bound {
+				t.Errorf("epsilon %v: got squared distance %v for query %v, want at most %v", epsilon, got, q, bound)
+			}
+			if got > want {
+				further++
+			}
+		}
+
+		if epsilon == 0 && further > 0 {
+			t.Errorf("got %d queries further than the nearest point without an epsilon", further)
+		}
+		if epsilon == 2 && further == 0 {
+			t.Errorf("got the nearest point for every query with epsilon %v, so the test does not exercise it", epsilon)
+		}
+	}
+}
+
+func TestNearestApproxMaxLeaves(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	coords := &countingCoordinates{Coordinates: randomCloud(rng, 5000)}
+	tree := NewKDTree(coords, nil)
+
+	var unbounded int
+	for i := 0; i < 500; i++ {
+		q := randomQuery(rng)
+
+		for _, maxLeaves := range []int{1, 3} {
+			coords.reads = 0
+			if index, _ := tree.NearestApprox(q[0], q[1], q[2], 0, maxLeaves); index < 0 {
+				t.Fatalf("got no point for query %v", q)
+			}
+
+			// Only the points of the leaves checked are read.
+			if bound := maxLeaves * kdLeafSize; coords.reads > bound {
+				t.Errorf("got %d points read with %d leaves, want at most %d", coords.reads, maxLeaves, bound)
+			}
+		}
+
+		coords.reads = 0
+		_, exact := tree.NearestApprox(q[0], q[1], q[2], 0, 0)
+		if coords.reads > 3*kdLeafSize {
+			unbounded++
+		}
+
+		// A limit the search does not reach leaves it exact.
+		if _, d := tree.NearestApprox(q[0], q[1], q[2], 0, tree.Len()); d != exact {
+			t.Errorf("got squared distance %v with a limit of every leaf for query %v, want %v", d, q, exact)
+		}
+	}
+
+	if unbounded == 0 {
+		t.Error("got every query answered from 3 leaves without a limit, so the test does not exercise it")
+	}
+}
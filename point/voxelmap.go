@@ -0,0 +1,196 @@
+package point
+
+import (
+	"math"
+)
+
+// VoxelMap hashes the points of a cloud into cubic voxels. Inserting and removing a point takes constant
+// time, which suits maps that are updated with every scan, and searches only visit the voxels around the
+// query. Searches are fastest when the voxel size is close to the typical search radius. Points with
+// non-finite coordinates are ignored.
+type VoxelMap struct {
+	coords Coordinates
+	size   float64
+	voxels map[voxelKey][]int
+	count  int
+
+	// Bounds of every voxel occupied so far, beyond which searches stop expanding.
+	lo, hi voxelKey
+}
+
+type voxelKey struct {
+	i, j, k int
+}
+
+// NewVoxelMap returns a map with the given voxel size holding the points of coords at the given indices,
+// or every point if indices is nil.
+func NewVoxelMap(coords Coordinates, voxelSize float64, indices []int) *VoxelMap {
+	m := &VoxelMap{
+		coords: coords,
+		size:   voxelSize,
+		voxels: make(map[voxelKey][]int),
+		lo:     voxelKey{math.MaxInt, math.MaxInt, math.MaxInt},
+		hi:     voxelKey{math.MinInt, math.MinInt, math.MinInt},
+	}
+
+	if indices == nil {
+		for i := 0; i < coords.Len(); i++ {
+			m.Insert(i)
+		}
+	} else {
+		m.Insert(indices...)
+	}
+
+	return m
+}
+
+// Coordinates returns the cloud the map indexes.
+func (m *VoxelMap) Coordinates() Coordinates {
+	return m.coords
+}
+
+// Len returns the number of points in the map.
+func (m *VoxelMap) Len() int {
+	return m.count
+}
+
+// finite reports whether every coordinate of a point is finite, so that it has a voxel.
+func finite(x, y, z float64) bool {
+	for _, v := range [3]float64{x, y, z} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *VoxelMap) key(x, y, z float64) voxelKey {
+	return voxelKey{
+		i: int(math.Floor(x / m.size)),
+		j: int(math.Floor(y / m.size)),
+		k: int(math.Floor(z / m.size)),
+	}
+}
+
+// Insert adds the points of the cloud at the given indices. A point must not be inserted twice.
+func (m *VoxelMap) Insert(indices ...int) {
+	for _, i := range indices {
+		x, y, z := m.coords.At(i)
+		if !finite(x, y, z) {
+			continue
+		}
+
+		v := m.key(x, y, z)
+		m.voxels[v] = append(m.voxels[v], i)
+		m.count++
+
+		m.lo = voxelKey{min(m.lo.i, v.i), min(m.lo.j, v.j), min(m.lo.k, v.k)}
+		m.hi = voxelKey{max(m.hi.i, v.i), max(m.hi.j, v.j), max(m.hi.k, v.k)}
+	}
+}
+
+// Remove removes the points of the cloud at the given indices. Points not in the map are ignored.
+func (m *VoxelMap) Remove(indices ...int) {
+	for _, i := range indices {
+		x, y, z := m.coords.At(i)
+		if !finite(x, y, z) {
+			continue
+		}
+
+		v := m.key(x, y, z)
+		voxel := m.voxels[v]
+
+		for j, held := range voxel {
+			if held != i {
+				continue
+			}
+			voxel[j] = voxel[len(voxel)-1]
+			voxel = voxel[:len(voxel)-1]
+			m.count--
+			break
+		}
+
+		if len(voxel) == 0 {
+			delete(m.voxels, v)
+		} else {
+			m.voxels[v] = voxel
+		}
+	}
+}
+
+// Nearest returns the index of the point closest to (x, y, z) and its squared distance, or -1 if the map
+// is empty.
+func (m *VoxelMap) Nearest(x, y, z float64) (int, float64) {
+	indices, distances := m.Search(x, y, z, 1, 0)
+	if len(indices) == 0 {
+		return -1, math.Inf(1)
+	}
+	return indices[0], distances[0]
+}
+
+// Search returns the indices of up to k points closest to (x, y, z) that lie within radius of it, and their
+// squared distances, nearest first. A k of zero places no limit on the number of points and a radius of
+// zero places no limit on their distance.
+func (m *VoxelMap) Search(x, y, z float64, k int, radius float64) ([]int, []float64) {
+	if m.count == 0 || !finite(x, y, z) {
+		return nil, nil
+	}
+
+	c := newCollector(k, radius)
+	q := [3]float64{x, y, z}
+	center := m.key(x, y, z)
+
+	// Shells of voxels further out than this hold no points.
+	last := max(center.i-m.lo.i, m.hi.i-center.i, center.j-m.lo.j, m.hi.j-center.j, center.k-m.lo.k, m.hi.k-center.k)
+
+	for s := 0; s <= last; s++ {
+		// The query may lie anywhere in its voxel, so points in shell s are at least s-1 voxels away.
+		if gap := float64(s-1) * m.size; s > 0 && gap*gap > c.bound() {
+			break
+		}
+
+		// Once a shell spans more voxels than are occupied, visit the occupied ones directly instead.
+		if shell := 2*s + 1; shell*shell*shell > len(m.voxels) {
+			for v, voxel := range m.voxels {
+				if chebyshev(v, center) >= s {
+					m.collect(voxel, q, c)
+				}
+			}
+			break
+		}
+
+		for di := -s; di <= s; di++ {
+			for dj := -s; dj <= s; dj++ {
+				// Inside the shell only its two faces along k are visited.
+				step := 2 * s
+				if di == -s || di == s || dj == -s || dj == s || s == 0 {
+					step = 1
+				}
+				for dk := -s; dk <= s; dk += step {
+					if voxel, ok := m.voxels[voxelKey{center.i + di, center.j + dj, center.k + dk}]; ok {
+						m.collect(voxel, q, c)
+					}
+				}
+			}
+		}
+	}
+
+	return c.results()
+}
+
+func (m *VoxelMap) collect(voxel []int, q [3]float64, c *collector) {
+	for _, i := range voxel {
+		c.add(i, squaredDistance(m.coords, i, q))
+	}
+}
+
+// chebyshev returns the number of voxels between a and b along the axis on which they are furthest apart.
+func chebyshev(a, b voxelKey) int {
+	abs := func(v int) int {
+		if v < 0 {
+			return -v
+		}
+		return v
+	}
+	return max(abs(a.i-b.i), abs(a.j-b.j), abs(a.k-b.k))
+}
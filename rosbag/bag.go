@@ -0,0 +1,217 @@
+package rosbag
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+const bagMagic = "#ROSBAG V2.0\n"
+
+// Bag record op codes.
+const (
+	bagOpMessageData = 0x02
+	bagOpBagHeader   = 0x03
+	bagOpIndexData   = 0x04
+	bagOpChunk       = 0x05
+	bagOpChunkInfo   = 0x06
+	bagOpConnection  = 0x07
+)
+
+type bagConnection struct {
+	topic string
+	typ   string
+}
+
+// BagReader reads the messages of a ROS 1 bag (format version 2.0) in file order.
+type BagReader struct {
+	r           *bufio.Reader
+	connections map[uint32]*bagConnection
+
+	// Records of the chunk currently being read.
+	chunk *bytes.Reader
+}
+
+// NewBagReader checks the bag version line of r.
+func NewBagReader(r io.Reader) (*BagReader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+
+	magic := make([]byte, len(bagMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != bagMagic {
+		return nil, errors.New("incorrect header; not a version 2.0 rosbag")
+	}
+
+	return &BagReader{r: br, connections: make(map[uint32]*bagConnection)}, nil
+}
+
+// Next returns the next message of the bag, or io.EOF once every message has been read.
+func (b *BagReader) Next() (*Message, error) {
+	for {
+		var header map[string][]byte
+		var data []byte
+		var err error
+
+		if b.chunk != nil && b.chunk.Len() > 0 {
+			header, data, err = readBagRecord(b.chunk)
+		} else {
+			b.chunk = nil
+			header, data, err = readBagRecord(b.r)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		op, ok := header["op"]
+		if !ok || len(op) != 1 {
+			return nil, errors.New("bag record has no op field")
+		}
+
+		switch op[0] {
+		case bagOpChunk:
+			records, err := decompressChunk(string(header["compression"]), data, int(headerUint32(header, "size")))
+			if err != nil {
+				return nil, err
+			}
+			b.chunk = bytes.NewReader(records)
+		case bagOpConnection:
+			conn, ok := header["conn"]
+			if !ok || len(conn) != 4 {
+				return nil, errors.New("connection record has no conn field")
+			}
+			fields, err := parseBagHeader(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read connection header: %w", err)
+			}
+			topic := string(header["topic"])
+			if t, ok := fields["topic"]; ok {
+				topic = string(t)
+			}
+			b.connections[binary.LittleEndian.Uint32(conn)] = &bagConnection{topic: topic, typ: string(fields["type"])}
+		case bagOpMessageData:
+			conn, ok := b.connections[headerUint32(header, "conn")]
+			if !ok {
+				return nil, errors.New("message refers to an unknown connection")
+			}
+
+			var logTime time.Time
+			if t := header["time"]; len(t) == 8 {
+				logTime = time.Unix(int64(binary.LittleEndian.Uint32(t)), int64(binary.LittleEndian.Uint32(t[4:])))
+			}
+
+			return &Message{Topic: conn.topic, Type: conn.typ, Encoding: EncodingROS1, LogTime: logTime, Data: data}, nil
+		case bagOpBagHeader, bagOpIndexData, bagOpChunkInfo:
+			continue
+		default:
+			return nil, fmt.Errorf("unknown bag record op %#x", op[0])
+		}
+	}
+}
+
+// readBagRecord reads a record header and its data.
+func readBagRecord(r io.Reader) (map[string][]byte, []byte, error) {
+	headerBytes, err := readBagBlock(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := readBagBlock(r)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, nil, err
+	}
+
+	header, err := parseBagHeader(headerBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, data, nil
+}
+
+// readBagBlock reads a length prefixed block, returning io.EOF only if r ends before the length.
+func readBagBlock(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("bag ends within a record")
+		}
+		return nil, err
+	}
+
+	block, err := readBlock(r, int64(binary.LittleEndian.Uint32(length[:])))
+	if err != nil {
+		return nil, errors.New("bag ends within a record")
+	}
+	return block, nil
+}
+
+// readBlock reads exactly n bytes. The buffer grows as bytes arrive rather than being sized from a length
+// read from the file, and in-memory readers are checked against the bytes they have left.
+func readBlock(r io.Reader, n int64) ([]byte, error) {
+	if lr, ok := r.(interface{ Len() int }); ok && n > int64(lr.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	var block bytes.Buffer
+	if _, err := io.CopyN(&block, r, n); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return block.Bytes(), nil
+}
+
+// parseBagHeader splits a record header into its name=value fields.
+func parseBagHeader(b []byte) (map[string][]byte, error) {
+	fields := make(map[string][]byte)
+
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errors.New("truncated header field")
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		if len(b) < 4+n {
+			return nil, errors.New("truncated header field")
+		}
+
+		name, value, ok := bytes.Cut(b[4:4+n], []byte("="))
+		if !ok {
+			return nil, errors.New("header field has no '='")
+		}
+		fields[string(name)] = value
+
+		b = b[4+n:]
+	}
+
+	return fields, nil
+}
+
+func headerUint32(header map[string][]byte, name string) uint32 {
+	if v := header[name]; len(v) == 4 {
+		return binary.LittleEndian.Uint32(v)
+	}
+	return 0
+}
+
+// decompressChunk decompresses the records of a bag chunk.
+func decompressChunk(compression string, data []byte, size int) ([]byte, error) {
+	switch compression {
+	case "none", "":
+		return data, nil
+	case "bz2":
+		out := make([]byte, 0, capacityHint(data, size))
+		buf := bytes.NewBuffer(out)
+		if _, err := buf.ReadFrom(bzip2.NewReader(bytes.NewReader(data))); err != nil {
+			return nil, fmt.Errorf("failed to decompress chunk: %w", err)
+		}
+		return buf.Bytes(), nil
+	case "lz4":
+		return lz4DecompressFrame(data, size)
+	default:
+		return nil, fmt.Errorf("unsupported chunk compression %q", compression)
+	}
+}
@@ -0,0 +1,185 @@
+package rosbag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bagField returns a name=value record header field.
+func bagField(name string, value []byte) []byte {
+	field := binary.LittleEndian.AppendUint32(nil, uint32(len(name)+1+len(value)))
+	field = append(field, name...)
+	field = append(field, '=')
+	return append(field, value...)
+}
+
+// bagRecord returns a record with the given header fields and data.
+func bagRecord(data []byte, fields ...[]byte) []byte {
+	header := bytes.Join(fields, nil)
+	record := binary.LittleEndian.AppendUint32(nil, uint32(len(header)))
+	record = append(record, header...)
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(data)))
+	return append(record, data...)
+}
+
+func bagUint32(v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(nil, v)
+}
+
+func bagTime(t time.Time) []byte {
+	return binary.LittleEndian.AppendUint32(bagUint32(uint32(t.Unix())), uint32(t.Nanosecond()))
+}
+
+// bagLogTime is the record time of the messages of bagRecords, one second apart.
+var bagLogTime = time.Unix(1700000100, 500)
+
+// bagRecords returns the connection and message records of a bag holding a PointCloud2 on /points, a
+// LaserScan on /scan and a std_msgs/String on /chatter, in that order.
+func bagRecords() []byte {
+	connections := []struct{ topic, typ string }{
+		{"/points", TypePointCloud2},
+		{"/scan", TypeLaserScan},
+		{"/chatter", "std_msgs/String"},
+	}
+
+	stamp := time.Unix(1700000000, 0)
+	messages := [][]byte{
+		pointCloud2(EncodingROS1, binary.LittleEndian, stamp, ousterLayout, ousterData(binary.LittleEndian, ousterPoints)),
+		laserScan(EncodingROS1, stamp, 0, math.Pi/2, 0.001, 0.1, 30, []float64{1, 2}, nil),
+		append(bagUint32(5), "hello"...),
+	}
+
+	var records []byte
+	for i, c := range connections {
+		header := bytes.Join([][]byte{bagField("topic", []byte(c.topic)), bagField("type", []byte(c.typ)), bagField("md5sum", []byte("*"))}, nil)
+		records = append(records, bagRecord(header, bagField("op", []byte{bagOpConnection}), bagField("conn", bagUint32(uint32(i))), bagField("topic", []byte(c.topic)))...)
+	}
+	for i, msg := range messages {
+		logTime := bagLogTime.Add(time.Duration(i) * time.Second)
+		records = append(records, bagRecord(msg, bagField("op", []byte{bagOpMessageData}), bagField("conn", bagUint32(uint32(i))), bagField("time", bagTime(logTime)))...)
+	}
+
+	return records
+}
+
+// bagFile returns a bag holding records in a single chunk compressed as given.
+func bagFile(compression string, compressed []byte, size int) []byte {
+	file := []byte(bagMagic)
+	file = append(file, bagRecord(make([]byte, 16), bagField("op", []byte{bagOpBagHeader}), bagField("chunk_count", bagUint32(1)))...)
+	file = append(file, bagRecord(compressed, bagField("op", []byte{bagOpChunk}), bagField("compression", []byte(compression)), bagField("size", bagUint32(uint32(size))))...)
+	return append(file, bagRecord(nil, bagField("op", []byte{bagOpIndexData}), bagField("ver", bagUint32(1)))...)
+}
+
+// testdata/bag_chunk.bz2 and testdata/bag_chunk.lz4 hold bagRecords compressed by the reference tools, e.g.
+// bzip2 -9 and lz4 -9, and have to be regenerated if bagRecords changes.
+func TestBagReader(t *testing.T) {
+	records := bagRecords()
+
+	tests := []struct {
+		name        string
+		compression string
+		file        string
+	}{
+		{name: "uncompressed chunk", compression: "none"},
+		{name: "bz2 chunk", compression: "bz2", file: "testdata/bag_chunk.bz2"},
+		{name: "lz4 chunk", compression: "lz4", file: "testdata/bag_chunk.lz4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunk := records
+			if tt.file != "" {
+				var err error
+				if chunk, err = os.ReadFile(tt.file); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			decompressed, err := decompressChunk(tt.compression, chunk, len(records))
+			if err != nil {
+				t.Fatalf("decompressChunk: %v", err)
+			}
+			if !bytes.Equal(decompressed, records) {
+				t.Fatalf("%s does not hold bagRecords, regenerate it", tt.file)
+			}
+
+			scanner, err := NewCloudScanner(bytes.NewReader(bagFile(tt.compression, chunk, len(records))))
+			if err != nil {
+				t.Fatalf("NewCloudScanner: %v", err)
+			}
+
+			var topics []string
+			for scanner.Scan() {
+				cloud := scanner.Cloud()
+				topics = append(topics, cloud.Topic)
+
+				wantPoints, wantLogTime := 3, bagLogTime
+				if cloud.Topic == "/scan" {
+					wantPoints, wantLogTime = 2, bagLogTime.Add(time.Second)
+				}
+				if cloud.Points.Len() != wantPoints {
+					t.Errorf("%s: got %d points, want %d", cloud.Topic, cloud.Points.Len(), wantPoints)
+				}
+				if !cloud.LogTime.Equal(wantLogTime) {
+					t.Errorf("%s: got log time %v, want %v", cloud.Topic, cloud.LogTime, wantLogTime)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if got, want := strings.Join(topics, ","), "/points,/scan"; got != want {
+				t.Errorf("got clouds on %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestBagTopicFilter(t *testing.T) {
+	records := bagRecords()
+
+	tests := []struct {
+		topics []string
+		want   string
+	}{
+		{topics: []string{"/scan"}, want: "/scan"},
+		{topics: []string{"/points", "/chatter"}, want: "/points"},
+		{topics: []string{"/missing"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.topics, ","), func(t *testing.T) {
+			scanner, err := NewCloudScanner(bytes.NewReader(bagFile("none", records, len(records))), tt.topics...)
+			if err != nil {
+				t.Fatalf("NewCloudScanner: %v", err)
+			}
+
+			var topics []string
+			for scanner.Scan() {
+				topics = append(topics, scanner.Cloud().Topic)
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if got := strings.Join(topics, ","); got != tt.want {
+				t.Errorf("got clouds on %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBagCorruptBlockLength(t *testing.T) {
+	file := append([]byte(bagMagic), 0xff, 0xff, 0xff, 0xff, 'o', 'p')
+
+	r, err := NewBagReader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err == nil || !strings.Contains(err.Error(), "ends within a record") {
+		t.Fatalf("got error %v, want a truncated record", err)
+	}
+}
@@ -0,0 +1,151 @@
+package rosbag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// LZ4 frames are used for compressed bag and MCAP chunks.
+// See https://github.com/lz4/lz4/blob/dev/doc/lz4_Frame_format.md.
+
+const lz4FrameMagic = 0x184d2204
+
+var errLZ4Corrupt = errors.New("corrupt lz4 data")
+
+// maxCompressionRatio bounds the capacity reserved for decompressed data, as the sizes recorded in chunk
+// headers are only hints and may be corrupt.
+const maxCompressionRatio = 8
+
+// capacityHint returns the capacity to reserve for decompressing src into size bytes.
+func capacityHint(src []byte, size int) int {
+	return min(size, maxCompressionRatio*len(src))
+}
+
+// lz4DecompressFrame decompresses a complete LZ4 frame. size is a hint for the decompressed size.
+func lz4DecompressFrame(src []byte, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid decompressed size %d", size)
+	}
+	if len(src) < 7 || binary.LittleEndian.Uint32(src) != lz4FrameMagic {
+		return nil, errors.New("missing lz4 frame magic")
+	}
+
+	flags := src[4]
+	if flags>>6 != 1 {
+		return nil, fmt.Errorf("unsupported lz4 frame version %d", flags>>6)
+	}
+	blockChecksum := flags&0x10 != 0
+	contentSize := flags&0x08 != 0
+	dictionary := flags&0x01 != 0
+
+	ip := 6 // Magic, FLG and BD.
+	if contentSize {
+		ip += 8
+	}
+	if dictionary {
+		return nil, errors.New("lz4 frames with dictionaries are not supported")
+	}
+	ip++ // Header checksum.
+
+	out := make([]byte, 0, capacityHint(src, size))
+
+	for {
+		if ip+4 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		blockSize := binary.LittleEndian.Uint32(src[ip:])
+		ip += 4
+
+		if blockSize == 0 {
+			break // End mark; a content checksum may follow.
+		}
+
+		uncompressed := blockSize&0x80000000 != 0
+		n := int(blockSize & 0x7fffffff)
+		if ip+n > len(src) {
+			return nil, errLZ4Corrupt
+		}
+
+		var err error
+		if uncompressed {
+			out = append(out, src[ip:ip+n]...)
+		} else if out, err = lz4DecompressBlock(src[ip:ip+n], out); err != nil {
+			return nil, err
+		}
+
+		ip += n
+		if blockChecksum {
+			ip += 4
+		}
+	}
+
+	return out, nil
+}
+
+// lz4DecompressBlock appends the decompressed block to out. Matches may reference data already in out,
+// which supports frames of linked blocks.
+func lz4DecompressBlock(src []byte, out []byte) ([]byte, error) {
+	for ip := 0; ip < len(src); {
+		token := src[ip]
+		ip++
+
+		literals := int(token >> 4)
+		if literals == 15 {
+			for {
+				if ip >= len(src) {
+					return nil, errLZ4Corrupt
+				}
+				b := src[ip]
+				ip++
+				literals += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		if ip+literals > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		out = append(out, src[ip:ip+literals]...)
+		ip += literals
+
+		if ip == len(src) {
+			break // The last sequence only holds literals.
+		}
+
+		if ip+2 > len(src) {
+			return nil, errLZ4Corrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[ip:]))
+		ip += 2
+
+		length := int(token & 0x0f)
+		if length == 15 {
+			for {
+				if ip >= len(src) {
+					return nil, errLZ4Corrupt
+				}
+				b := src[ip]
+				ip++
+				length += int(b)
+				if b != 255 {
+					break
+				}
+			}
+		}
+		length += 4
+
+		start := len(out) - offset
+		if offset == 0 || start < 0 {
+			return nil, errLZ4Corrupt
+		}
+
+		// Byte by byte as the match may overlap the output being written.
+		for i := 0; i < length; i++ {
+			out = append(out, out[start+i])
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,247 @@
+package rosbag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const mcapMagic = "\x89MCAP0\r\n"
+
+// MCAP record op codes.
+const (
+	mcapOpHeader  = 0x01
+	mcapOpFooter  = 0x02
+	mcapOpSchema  = 0x03
+	mcapOpChannel = 0x04
+	mcapOpMessage = 0x05
+	mcapOpChunk   = 0x06
+	mcapOpDataEnd = 0x0f
+)
+
+type mcapChannel struct {
+	topic    string
+	schemaID uint16
+	encoding string
+}
+
+// MCAPReader reads the messages of an MCAP file in file order.
+type MCAPReader struct {
+	r        *bufio.Reader
+	schemas  map[uint16]string
+	channels map[uint16]*mcapChannel
+	zstd     *zstd.Decoder
+
+	// Records of the chunk currently being read.
+	chunk *bytes.Reader
+	done  bool
+}
+
+// NewMCAPReader checks the magic bytes of r.
+func NewMCAPReader(r io.Reader) (*MCAPReader, error) {
+	br := bufio.NewReaderSize(r, 1<<16)
+
+	magic := make([]byte, len(mcapMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != mcapMagic {
+		return nil, errors.New("incorrect header; not an MCAP file")
+	}
+
+	return &MCAPReader{
+		r:        br,
+		schemas:  make(map[uint16]string),
+		channels: make(map[uint16]*mcapChannel),
+	}, nil
+}
+
+// Next returns the next message of the file, or io.EOF once every message has been read.
+func (m *MCAPReader) Next() (*Message, error) {
+	for {
+		var op byte
+		var record []byte
+		var err error
+
+		if m.chunk != nil && m.chunk.Len() > 0 {
+			op, record, err = readMCAPRecord(m.chunk)
+		} else {
+			m.chunk = nil
+			if m.done {
+				return nil, io.EOF
+			}
+			op, record, err = readMCAPRecord(m.r)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r := &mcapFields{b: record}
+
+		switch op {
+		case mcapOpSchema:
+			id := r.uint16()
+			name := r.string()
+			if r.err != nil {
+				return nil, fmt.Errorf("failed to read schema: %w", r.err)
+			}
+			m.schemas[id] = name
+		case mcapOpChannel:
+			id := r.uint16()
+			c := &mcapChannel{schemaID: r.uint16(), topic: r.string(), encoding: r.string()}
+			if r.err != nil {
+				return nil, fmt.Errorf("failed to read channel: %w", r.err)
+			}
+			m.channels[id] = c
+		case mcapOpMessage:
+			id := r.uint16()
+			r.uint32() // Sequence.
+			logTime := r.uint64()
+			r.uint64() // Publish time.
+			if r.err != nil {
+				return nil, fmt.Errorf("failed to read message: %w", r.err)
+			}
+
+			c, ok := m.channels[id]
+			if !ok {
+				return nil, errors.New("message refers to an unknown channel")
+			}
+
+			return &Message{
+				Topic:    c.topic,
+				Type:     m.schemas[c.schemaID],
+				Encoding: c.encoding,
+				LogTime:  time.Unix(0, int64(logTime)),
+				Data:     r.b[r.pos:],
+			}, nil
+		case mcapOpChunk:
+			r.uint64() // Message start time.
+			r.uint64() // Message end time.
+			size := r.uint64()
+			if size > math.MaxInt64 {
+				return nil, fmt.Errorf("chunk has an invalid uncompressed size %d", size)
+			}
+			r.uint32() // Uncompressed CRC.
+			compression := r.string()
+			records := r.bytes64()
+			if r.err != nil {
+				return nil, fmt.Errorf("failed to read chunk: %w", r.err)
+			}
+
+			decompressed, err := m.decompress(compression, records, int(size))
+			if err != nil {
+				return nil, err
+			}
+			m.chunk = bytes.NewReader(decompressed)
+		case mcapOpDataEnd, mcapOpFooter:
+			// The summary section only repeats what has been read.
+			m.done = true
+		default:
+			continue
+		}
+	}
+}
+
+func (m *MCAPReader) decompress(compression string, data []byte, size int) ([]byte, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("invalid decompressed size %d", size)
+	}
+
+	switch compression {
+	case "":
+		return data, nil
+	case "lz4":
+		return lz4DecompressFrame(data, size)
+	case "zstd":
+		if m.zstd == nil {
+			decoder, err := zstd.NewReader(nil)
+			if err != nil {
+				return nil, err
+			}
+			m.zstd = decoder
+		}
+		return m.zstd.DecodeAll(data, make([]byte, 0, capacityHint(data, size)))
+	default:
+		return nil, fmt.Errorf("unsupported chunk compression %q", compression)
+	}
+}
+
+// readMCAPRecord reads an op code and the content of its record.
+func readMCAPRecord(r io.Reader) (byte, []byte, error) {
+	var prefix [9]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, errors.New("mcap file ends within a record")
+		}
+		return 0, nil, err
+	}
+
+	length := binary.LittleEndian.Uint64(prefix[1:])
+	if length > 1<<32 {
+		return 0, nil, fmt.Errorf("mcap record of %d bytes is too large", length)
+	}
+
+	record, err := readBlock(r, int64(length))
+	if err != nil {
+		return 0, nil, errors.New("mcap file ends within a record")
+	}
+
+	return prefix[0], record, nil
+}
+
+// mcapFields reads the little-endian fields of a record, remembering the first error.
+type mcapFields struct {
+	b   []byte
+	pos int
+	err error
+}
+
+func (f *mcapFields) next(n int) []byte {
+	if f.err != nil || f.pos+n > len(f.b) {
+		if f.err == nil {
+			f.err = errors.New("record is truncated")
+		}
+		return nil
+	}
+	b := f.b[f.pos : f.pos+n]
+	f.pos += n
+	return b
+}
+
+func (f *mcapFields) uint16() uint16 {
+	if b := f.next(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (f *mcapFields) uint32() uint32 {
+	if b := f.next(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (f *mcapFields) uint64() uint64 {
+	if b := f.next(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (f *mcapFields) string() string {
+	return string(f.next(int(f.uint32())))
+}
+
+func (f *mcapFields) bytes64() []byte {
+	n := f.uint64()
+	if n > uint64(len(f.b)) {
+		f.err = errors.New("record is truncated")
+		return nil
+	}
+	return f.next(int(n))
+}
@@ -0,0 +1,227 @@
+package rosbag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// mcapRecord returns a record with the given op code and content.
+func mcapRecord(op byte, content []byte) []byte {
+	record := []byte{op}
+	record = binary.LittleEndian.AppendUint64(record, uint64(len(content)))
+	return append(record, content...)
+}
+
+// mcapChunk returns the content of a chunk record holding records compressed as given.
+func mcapChunk(size uint64, compression string, records []byte) []byte {
+	var b []byte
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, size)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(compression)))
+	b = append(b, compression...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(records)))
+	return append(b, records...)
+}
+
+// lz4Frame returns an LZ4 frame holding data as a single uncompressed block.
+func lz4Frame(data []byte) []byte {
+	frame := binary.LittleEndian.AppendUint32(nil, lz4FrameMagic)
+	frame = append(frame, 0x40, 0x70, 0)
+	frame = binary.LittleEndian.AppendUint32(frame, uint32(len(data))|0x80000000)
+	frame = append(frame, data...)
+	return binary.LittleEndian.AppendUint32(frame, 0)
+}
+
+func TestMCAPCorruptSizes(t *testing.T) {
+	channel := binary.LittleEndian.AppendUint16(nil, 1)
+	channel = binary.LittleEndian.AppendUint16(channel, 0)
+	channel = binary.LittleEndian.AppendUint32(channel, 6)
+	channel = append(channel, "/cloud"...)
+	channel = binary.LittleEndian.AppendUint32(channel, 3)
+	channel = append(channel, "cdr"...)
+
+	message := binary.LittleEndian.AppendUint16(nil, 1)
+	message = append(message, make([]byte, 20)...)
+	message = append(message, "payload"...)
+
+	records := append(mcapRecord(mcapOpChannel, channel), mcapRecord(mcapOpMessage, message)...)
+
+	tests := []struct {
+		name string
+		file []byte
+		err  string
+	}{
+		{
+			name: "lz4 chunk with a huge uncompressed size",
+			file: mcapRecord(mcapOpChunk, mcapChunk(math.MaxUint64-1, "lz4", lz4Frame(records))),
+			err:  "invalid uncompressed size",
+		},
+		{
+			name: "lz4 chunk with an oversized uncompressed size hint",
+			file: mcapRecord(mcapOpChunk, mcapChunk(math.MaxInt64, "lz4", lz4Frame(records))),
+		},
+		{
+			name: "string longer than its record",
+			file: mcapRecord(mcapOpSchema, []byte{1, 0, 0xff, 0xff, 0xff, 0xff}),
+			err:  "record is truncated",
+		},
+		{
+			name: "record longer than the file",
+			file: binary.LittleEndian.AppendUint64([]byte{mcapOpSchema}, math.MaxUint32),
+			err:  "ends within a record",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewMCAPReader(bytes.NewReader(append([]byte(mcapMagic), tt.file...)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := r.Next()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Topic != "/cloud" || string(msg.Data) != "payload" {
+				t.Errorf("got message on %q with data %q", msg.Topic, msg.Data)
+			}
+		})
+	}
+}
+
+func mcapString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func mcapSchemaRecord(id uint16, name string) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, id)
+	b = mcapString(b, name)
+	b = mcapString(b, "ros2msg")
+	return mcapRecord(mcapOpSchema, binary.LittleEndian.AppendUint32(b, 0))
+}
+
+func mcapChannelRecord(id, schemaID uint16, topic string) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, id)
+	b = binary.LittleEndian.AppendUint16(b, schemaID)
+	b = mcapString(b, topic)
+	b = mcapString(b, EncodingCDR)
+	return mcapRecord(mcapOpChannel, binary.LittleEndian.AppendUint32(b, 0))
+}
+
+func mcapMessageRecord(channel uint16, logTime time.Time, data []byte) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, channel)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, uint64(logTime.UnixNano()))
+	b = binary.LittleEndian.AppendUint64(b, uint64(logTime.UnixNano()))
+	return mcapRecord(mcapOpMessage, append(b, data...))
+}
+
+// mcapLogTime is the log time of the first message of mcapFile, later messages are one second apart.
+var mcapLogTime = time.Unix(1700000200, 0)
+
+// mcapScanRecords returns the records of the lz4 chunk of mcapFile: a LaserScan on /scan.
+func mcapScanRecords() []byte {
+	scan := laserScan(EncodingCDR, time.Unix(1700000000, 0), 0, math.Pi/2, 0.001, 0.1, 30, []float64{1, 2}, nil)
+	return mcapMessageRecord(2, mcapLogTime.Add(time.Second), scan)
+}
+
+// mcapFile returns an MCAP file with CDR encoded messages on /points in a zstd chunk, /scan in an lz4 chunk,
+// then /chatter and /points again outside any chunk. lz4Chunk holds mcapScanRecords compressed.
+func mcapFile(t *testing.T, lz4Chunk []byte) []byte {
+	t.Helper()
+
+	stamp := time.Unix(1700000000, 0)
+	cloud := pointCloud2(EncodingCDR, binary.LittleEndian, stamp, ousterLayout, ousterData(binary.LittleEndian, ousterPoints))
+
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pointsRecords := mcapMessageRecord(1, mcapLogTime, cloud)
+	zstdChunk := encoder.EncodeAll(pointsRecords, nil)
+
+	file := []byte(mcapMagic)
+	file = append(file, mcapRecord(mcapOpHeader, mcapString(mcapString(nil, "ros2"), "test"))...)
+	file = append(file, mcapSchemaRecord(1, "sensor_msgs/msg/PointCloud2")...)
+	file = append(file, mcapSchemaRecord(2, "sensor_msgs/msg/LaserScan")...)
+	file = append(file, mcapSchemaRecord(3, "std_msgs/msg/String")...)
+	file = append(file, mcapChannelRecord(1, 1, "/points")...)
+	file = append(file, mcapChannelRecord(2, 2, "/scan")...)
+	file = append(file, mcapChannelRecord(3, 3, "/chatter")...)
+	file = append(file, mcapRecord(mcapOpChunk, mcapChunk(uint64(len(pointsRecords)), "zstd", zstdChunk))...)
+	file = append(file, mcapRecord(mcapOpChunk, mcapChunk(uint64(len(mcapScanRecords())), "lz4", lz4Chunk))...)
+	file = append(file, mcapMessageRecord(3, mcapLogTime.Add(2*time.Second), []byte{0, 1, 0, 0, 6, 0, 0, 0, 'h', 'e', 'l', 'l', 'o', 0})...)
+	file = append(file, mcapMessageRecord(1, mcapLogTime.Add(3*time.Second), cloud)...)
+	file = append(file, mcapRecord(mcapOpDataEnd, binary.LittleEndian.AppendUint32(nil, 0))...)
+	file = append(file, mcapRecord(mcapOpFooter, make([]byte, 20))...)
+	return append(file, mcapMagic...)
+}
+
+// testdata/mcap_chunk.lz4 holds mcapScanRecords compressed by the reference tool, e.g. lz4 -9, and has to be
+// regenerated if mcapScanRecords changes.
+func TestMCAPReader(t *testing.T) {
+	lz4Chunk, err := os.ReadFile("testdata/mcap_chunk.lz4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decompressed, err := lz4DecompressFrame(lz4Chunk, 0); err != nil || !bytes.Equal(decompressed, mcapScanRecords()) {
+		t.Fatalf("testdata/mcap_chunk.lz4 does not hold mcapScanRecords (error %v), regenerate it", err)
+	}
+
+	tests := []struct {
+		topics []string
+		want   string
+	}{
+		{want: "/points,/scan,/points"},
+		{topics: []string{"/scan", "/chatter"}, want: "/scan"},
+		{topics: []string{"/points"}, want: "/points,/points"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			scanner, err := NewCloudScanner(bytes.NewReader(mcapFile(t, lz4Chunk)), tt.topics...)
+			if err != nil {
+				t.Fatalf("NewCloudScanner: %v", err)
+			}
+
+			var topics []string
+			for scanner.Scan() {
+				cloud := scanner.Cloud()
+				topics = append(topics, cloud.Topic)
+
+				wantPoints := 3
+				if cloud.Topic == "/scan" {
+					wantPoints = 2
+					if want := mcapLogTime.Add(time.Second); !cloud.LogTime.Equal(want) {
+						t.Errorf("got log time %v, want %v", cloud.LogTime, want)
+					}
+				}
+				if cloud.Points.Len() != wantPoints {
+					t.Errorf("%s: got %d points, want %d", cloud.Topic, cloud.Points.Len(), wantPoints)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if got := strings.Join(topics, ","); got != tt.want {
+				t.Errorf("got clouds on %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
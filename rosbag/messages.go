@@ -0,0 +1,494 @@
+package rosbag
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Message encodings.
+const (
+	EncodingROS1 = "ros1"
+	EncodingCDR  = "cdr"
+)
+
+// Message types decoded into clouds, without the /msg/ infix used by ROS 2.
+const (
+	TypePointCloud2 = "sensor_msgs/PointCloud2"
+	TypeLaserScan   = "sensor_msgs/LaserScan"
+)
+
+// Message is a serialized message read from a recording.
+type Message struct {
+	Topic    string
+	Type     string
+	Encoding string
+
+	// Time the message was recorded.
+	LogTime time.Time
+
+	Data []byte
+}
+
+// Cloud is a point cloud decoded from a PointCloud2 or LaserScan message.
+type Cloud struct {
+	Topic   string
+	FrameID string
+
+	// Stamp of the message header.
+	Stamp time.Time
+
+	// Time the message was recorded.
+	LogTime time.Time
+
+	// Points holds the finite points of the message. Point times are in seconds and keep the time base of the
+	// message, which for most drivers and for scans is the offset from Stamp.
+	Points *point.Points3D
+
+	// Extra holds the values of fields without a point.Point3D field, keyed by field name.
+	// Each slice has one value per point.
+	Extra map[string][]float64
+}
+
+// normalizeType strips the /msg/ infix of ROS 2 type names.
+func normalizeType(t string) string {
+	return strings.Replace(t, "/msg/", "/", 1)
+}
+
+// DecodeCloud decodes a PointCloud2 or LaserScan message.
+func DecodeCloud(msg *Message) (*Cloud, error) {
+	r, err := newMessageReader(msg.Data, msg.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	cloud := &Cloud{Topic: msg.Topic, LogTime: msg.LogTime, Extra: make(map[string][]float64)}
+	if cloud.Stamp, cloud.FrameID, err = r.header(); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	switch normalizeType(msg.Type) {
+	case TypePointCloud2:
+		err = decodePointCloud2(r, cloud)
+	case TypeLaserScan:
+		err = decodeLaserScan(r, cloud)
+	default:
+		return nil, fmt.Errorf("unsupported message type %q", msg.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s on %s: %w", msg.Type, msg.Topic, err)
+	}
+
+	return cloud, nil
+}
+
+// PointField datatypes.
+const (
+	fieldInt8    = 1
+	fieldUint8   = 2
+	fieldInt16   = 3
+	fieldUint16  = 4
+	fieldInt32   = 5
+	fieldUint32  = 6
+	fieldFloat32 = 7
+	fieldFloat64 = 8
+)
+
+type pointField struct {
+	name     string
+	offset   int
+	datatype uint8
+	count    int
+}
+
+func (f *pointField) size() int {
+	switch f.datatype {
+	case fieldInt8, fieldUint8:
+		return 1
+	case fieldInt16, fieldUint16:
+		return 2
+	case fieldInt32, fieldUint32, fieldFloat32:
+		return 4
+	case fieldFloat64:
+		return 8
+	default:
+		return 0
+	}
+}
+
+func (f *pointField) decode(b []byte, order binary.ByteOrder) float64 {
+	switch f.datatype {
+	case fieldInt8:
+		return float64(int8(b[0]))
+	case fieldUint8:
+		return float64(b[0])
+	case fieldInt16:
+		return float64(int16(order.Uint16(b)))
+	case fieldUint16:
+		return float64(order.Uint16(b))
+	case fieldInt32:
+		return float64(int32(order.Uint32(b)))
+	case fieldUint32:
+		return float64(order.Uint32(b))
+	case fieldFloat32:
+		return float64(math.Float32frombits(order.Uint32(b)))
+	default:
+		return math.Float64frombits(order.Uint64(b))
+	}
+}
+
+func decodePointCloud2(r *messageReader, cloud *Cloud) error {
+	height, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	width, err := r.uint32()
+	if err != nil {
+		return err
+	}
+
+	numFields, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	// Each field takes at least a name length, offset, datatype and count.
+	if uint64(numFields)*13 > uint64(r.remaining()) {
+		return errShortMessage
+	}
+	fields := make([]*pointField, numFields)
+	for i := range fields {
+		f := &pointField{}
+		if f.name, err = r.string(); err != nil {
+			return err
+		}
+		offset, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		if f.datatype, err = r.uint8(); err != nil {
+			return err
+		}
+		count, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		f.offset, f.count = int(offset), int(count)
+		if f.size() == 0 {
+			return fmt.Errorf("field %q has unknown datatype %d", f.name, f.datatype)
+		}
+		fields[i] = f
+	}
+
+	bigEndian, err := r.uint8()
+	if err != nil {
+		return err
+	}
+	pointStep, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	rowStep, err := r.uint32()
+	if err != nil {
+		return err
+	}
+	data, err := r.bytes()
+	if err != nil {
+		return err
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian != 0 {
+		order = binary.BigEndian
+	}
+
+	// Every decoded value is read at the offset of its field, so each field must hold at least one value
+	// within the point step.
+	for _, f := range fields {
+		if f.count == 0 {
+			return fmt.Errorf("field %q has a zero count", f.name)
+		}
+		if f.offset+f.size()*f.count > int(pointStep) {
+			return fmt.Errorf("field %q does not fit in a point step of %d", f.name, pointStep)
+		}
+	}
+	if width > 0 && height > 0 && (pointStep == 0 || rowStep == 0) {
+		return errors.New("cloud has a zero point or row step")
+	}
+	if uint64(height)*uint64(rowStep) > uint64(len(data)) || uint64(width)*uint64(pointStep) > uint64(rowStep) {
+		return errors.New("data is smaller than the cloud dimensions")
+	}
+
+	var x, y, z, nx, ny, nz, intensity, rgb, timestamp, ring, label *pointField
+	timeScale := 1.0
+	extra := make([]*pointField, 0)
+	for _, f := range fields {
+		switch f.name {
+		case "x":
+			x = f
+		case "y":
+			y = f
+		case "z":
+			z = f
+		case "normal_x":
+			nx = f
+		case "normal_y":
+			ny = f
+		case "normal_z":
+			nz = f
+		case "intensity":
+			intensity = f
+		case "rgb", "rgba":
+			rgb = f
+		case "time", "timestamp":
+			timestamp = f
+		case "t":
+			// Ouster drivers publish nanosecond offsets.
+			timestamp, timeScale = f, 1e-9
+		case "ring":
+			ring = f
+		case "label":
+			label = f
+		default:
+			if f.count == 1 {
+				extra = append(extra, f)
+			}
+		}
+	}
+	if x == nil || y == nil || z == nil {
+		return errors.New("cloud has no x, y and z fields")
+	}
+
+	n := int(width * height)
+	points := make(point.Points3D, 0, n)
+	for _, f := range extra {
+		cloud.Extra[f.name] = make([]float64, 0, n)
+	}
+
+	for row := 0; row < int(height); row++ {
+		for col := 0; col < int(width); col++ {
+			b := data[row*int(rowStep)+col*int(pointStep):]
+
+			p := &point.Point3D{
+				X: x.decode(b[x.offset:], order),
+				Y: y.decode(b[y.offset:], order),
+				Z: z.decode(b[z.offset:], order),
+			}
+			if math.IsNaN(p.X) || math.IsNaN(p.Y) || math.IsNaN(p.Z) || math.IsInf(p.X, 0) || math.IsInf(p.Y, 0) || math.IsInf(p.Z, 0) {
+				continue
+			}
+
+			if nx != nil && ny != nil && nz != nil {
+				p.Nx = nx.decode(b[nx.offset:], order)
+				p.Ny = ny.decode(b[ny.offset:], order)
+				p.Nz = nz.decode(b[nz.offset:], order)
+			}
+			if intensity != nil {
+				p.Intensity = intensity.decode(b[intensity.offset:], order)
+			}
+			if rgb != nil && rgb.size() == 4 {
+				// Colors are packed into the bits of the field.
+				packed := order.Uint32(b[rgb.offset:])
+				p.R, p.G, p.B = uint8(packed>>16), uint8(packed>>8), uint8(packed)
+			}
+
+			if timestamp != nil {
+				p.Time = timestamp.decode(b[timestamp.offset:], order) * timeScale
+			}
+			if ring != nil {
+				p.Ring = int(ring.decode(b[ring.offset:], order))
+			}
+			if label != nil {
+				p.Label = int(label.decode(b[label.offset:], order))
+			}
+
+			for _, f := range extra {
+				cloud.Extra[f.name] = append(cloud.Extra[f.name], f.decode(b[f.offset:], order))
+			}
+
+			points = append(points, p)
+		}
+	}
+
+	cloud.Points = &points
+	return nil
+}
+
+func decodeLaserScan(r *messageReader, cloud *Cloud) error {
+	var values [7]float32
+	for i := range values {
+		v, err := r.uint32()
+		if err != nil {
+			return err
+		}
+		values[i] = math.Float32frombits(v)
+	}
+	angleMin, angleIncrement, timeIncrement := float64(values[0]), float64(values[2]), float64(values[3])
+	rangeMin, rangeMax := float64(values[5]), float64(values[6])
+
+	ranges, err := r.float32s()
+	if err != nil {
+		return err
+	}
+	intensities, err := r.float32s()
+	if err != nil {
+		return err
+	}
+
+	points := make(point.Points3D, 0, len(ranges))
+
+	for i, rng := range ranges {
+		d := float64(rng)
+		if math.IsNaN(d) || math.IsInf(d, 0) || d < rangeMin || d > rangeMax {
+			continue
+		}
+
+		sin, cos := math.Sincos(angleMin + float64(i)*angleIncrement)
+		p := &point.Point3D{X: d * cos, Y: d * sin, Time: float64(i) * timeIncrement}
+		if i < len(intensities) {
+			p.Intensity = float64(intensities[i])
+		}
+
+		points = append(points, p)
+	}
+
+	cloud.Points = &points
+	return nil
+}
+
+// messageReader deserializes ROS 1 messages and ROS 2 CDR messages.
+type messageReader struct {
+	data  []byte
+	pos   int
+	order binary.ByteOrder
+
+	// CDR aligns primitives to their size, relative to the end of the encapsulation header.
+	cdr bool
+}
+
+var errShortMessage = errors.New("message is truncated")
+
+func newMessageReader(data []byte, encoding string) (*messageReader, error) {
+	switch encoding {
+	case EncodingROS1:
+		return &messageReader{data: data, order: binary.LittleEndian}, nil
+	case EncodingCDR:
+		if len(data) < 4 {
+			return nil, errShortMessage
+		}
+		r := &messageReader{data: data[4:], order: binary.LittleEndian, cdr: true}
+		if data[1]&0x01 == 0 {
+			r.order = binary.BigEndian
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unsupported message encoding %q", encoding)
+	}
+}
+
+func (r *messageReader) align(n int) {
+	if r.cdr {
+		r.pos = (r.pos + n - 1) / n * n
+	}
+}
+
+// remaining returns the number of unread bytes.
+func (r *messageReader) remaining() int {
+	return len(r.data) - r.pos
+}
+
+func (r *messageReader) next(n int) ([]byte, error) {
+	r.align(n)
+	if r.pos+n > len(r.data) {
+		return nil, errShortMessage
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *messageReader) uint8() (uint8, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (r *messageReader) uint32() (uint32, error) {
+	b, err := r.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return r.order.Uint32(b), nil
+}
+
+// bytes reads a length prefixed byte sequence without copying it.
+func (r *messageReader) bytes() ([]byte, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if r.pos+int(n) > len(r.data) {
+		return nil, errShortMessage
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *messageReader) string() (string, error) {
+	b, err := r.bytes()
+	if err != nil {
+		return "", err
+	}
+	// CDR strings include their null terminator.
+	return strings.TrimRight(string(b), "\x00"), nil
+}
+
+func (r *messageReader) float32s() ([]float32, error) {
+	n, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if uint64(n)*4 > uint64(r.remaining()) {
+		return nil, errShortMessage
+	}
+	values := make([]float32, n)
+	for i := range values {
+		v, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = math.Float32frombits(v)
+	}
+	return values, nil
+}
+
+// header reads a std_msgs/Header, which only has a sequence number in ROS 1.
+func (r *messageReader) header() (time.Time, string, error) {
+	if !r.cdr {
+		if _, err := r.uint32(); err != nil {
+			return time.Time{}, "", err
+		}
+	}
+
+	sec, err := r.uint32()
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	nsec, err := r.uint32()
+	if err != nil {
+		return time.Time{}, "", err
+	}
+	frameID, err := r.string()
+	if err != nil {
+		return time.Time{}, "", err
+	}
+
+	return time.Unix(int64(sec), int64(nsec)), frameID, nil
+}
@@ -0,0 +1,368 @@
+package rosbag
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// messageWriter serializes ROS 1 messages and ROS 2 CDR messages, mirroring messageReader.
+type messageWriter struct {
+	b     []byte
+	order binary.ByteOrder
+
+	// Start of the CDR body that alignment is relative to, or -1 for ROS 1.
+	start int
+}
+
+func newMessageWriter(encoding string, order binary.ByteOrder) *messageWriter {
+	if encoding == EncodingROS1 {
+		return &messageWriter{order: binary.LittleEndian, start: -1}
+	}
+
+	w := &messageWriter{order: order, start: 4}
+	w.b = []byte{0, 0, 0, 0}
+	if order == binary.LittleEndian {
+		w.b[1] = 1
+	}
+	return w
+}
+
+func (w *messageWriter) align(n int) {
+	if w.start < 0 {
+		return
+	}
+	for (len(w.b)-w.start)%n != 0 {
+		w.b = append(w.b, 0)
+	}
+}
+
+func (w *messageWriter) uint8(v uint8) {
+	w.b = append(w.b, v)
+}
+
+func (w *messageWriter) uint32(v uint32) {
+	w.align(4)
+	w.b = append(w.b, 0, 0, 0, 0)
+	w.order.PutUint32(w.b[len(w.b)-4:], v)
+}
+
+func (w *messageWriter) float32(v float64) {
+	w.uint32(math.Float32bits(float32(v)))
+}
+
+func (w *messageWriter) bytes(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.b = append(w.b, b...)
+}
+
+func (w *messageWriter) string(s string) {
+	if w.start >= 0 {
+		s += "\x00"
+	}
+	w.bytes([]byte(s))
+}
+
+func (w *messageWriter) float32s(values []float64) {
+	w.uint32(uint32(len(values)))
+	for _, v := range values {
+		w.float32(v)
+	}
+}
+
+func (w *messageWriter) header(stamp time.Time, frameID string) {
+	if w.start < 0 {
+		w.uint32(0) // Sequence.
+	}
+	w.uint32(uint32(stamp.Unix()))
+	w.uint32(uint32(stamp.Nanosecond()))
+	w.string(frameID)
+}
+
+// cloudLayout describes the fields and shape of a PointCloud2 message.
+type cloudLayout struct {
+	fields        []pointField
+	height, width int
+	bigEndian     bool
+	pointStep     int
+	rowStep       int
+}
+
+// pointCloud2 serializes a PointCloud2 message holding data.
+func pointCloud2(encoding string, order binary.ByteOrder, stamp time.Time, layout *cloudLayout, data []byte) []byte {
+	w := newMessageWriter(encoding, order)
+	w.header(stamp, "lidar")
+	w.uint32(uint32(layout.height))
+	w.uint32(uint32(layout.width))
+
+	w.uint32(uint32(len(layout.fields)))
+	for _, f := range layout.fields {
+		w.string(f.name)
+		w.uint32(uint32(f.offset))
+		w.uint8(f.datatype)
+		w.uint32(uint32(f.count))
+	}
+
+	bigEndian := uint8(0)
+	if layout.bigEndian {
+		bigEndian = 1
+	}
+	w.uint8(bigEndian)
+	w.uint32(uint32(layout.pointStep))
+	w.uint32(uint32(layout.rowStep))
+	w.bytes(data)
+	w.uint8(0) // is_dense.
+
+	return w.b
+}
+
+// ousterLayout is the layout of the organized clouds of an Ouster driver: float32 positions, packed rgb,
+// nanosecond time offsets in t, a ring and a reflectivity extra, plus a two value descriptor that is not kept.
+// Rows are padded by four bytes.
+var ousterLayout = &cloudLayout{
+	fields: []pointField{
+		{name: "x", offset: 0, datatype: fieldFloat32, count: 1},
+		{name: "y", offset: 4, datatype: fieldFloat32, count: 1},
+		{name: "z", offset: 8, datatype: fieldFloat32, count: 1},
+		{name: "rgb", offset: 16, datatype: fieldFloat32, count: 1},
+		{name: "t", offset: 20, datatype: fieldUint32, count: 1},
+		{name: "ring", offset: 24, datatype: fieldUint16, count: 1},
+		{name: "reflectivity", offset: 26, datatype: fieldUint16, count: 1},
+		{name: "descriptor", offset: 28, datatype: fieldInt8, count: 2},
+	},
+	height:    2,
+	width:     2,
+	pointStep: 30,
+	rowStep:   64,
+}
+
+type ousterPoint struct {
+	x, y, z      float64
+	rgb          uint32
+	t            uint32
+	ring         uint16
+	reflectivity uint16
+}
+
+// ousterData returns the data of an ousterLayout cloud holding points row by row.
+func ousterData(order binary.ByteOrder, points []ousterPoint) []byte {
+	l := ousterLayout
+	data := make([]byte, l.height*l.rowStep)
+	for i, p := range points {
+		b := data[i/l.width*l.rowStep+i%l.width*l.pointStep:]
+		order.PutUint32(b[0:], math.Float32bits(float32(p.x)))
+		order.PutUint32(b[4:], math.Float32bits(float32(p.y)))
+		order.PutUint32(b[8:], math.Float32bits(float32(p.z)))
+		order.PutUint32(b[16:], p.rgb)
+		order.PutUint32(b[20:], p.t)
+		order.PutUint16(b[24:], p.ring)
+		order.PutUint16(b[26:], p.reflectivity)
+		b[28], b[29] = 0xff, 0x7f
+	}
+	return data
+}
+
+var ousterPoints = []ousterPoint{
+	{x: 1, y: 2, z: 3, rgb: 0x00ff8001, t: 50000000, ring: 0, reflectivity: 7},
+	{x: math.NaN(), y: 0, z: 0, rgb: 0, t: 0, ring: 0, reflectivity: 0},
+	{x: -4.5, y: 0.25, z: 6, rgb: 0x00102030, t: 0, ring: 1, reflectivity: 100},
+	{x: 10, y: -20, z: 0.125, rgb: 0xff000000, t: 99999999, ring: 1, reflectivity: 65535},
+}
+
+func TestDecodePointCloud2(t *testing.T) {
+	stamp := time.Unix(1700000000, 250000000)
+
+	want := point.Points3D{
+		{X: 1, Y: 2, Z: 3, R: 0xff, G: 0x80, B: 0x01, Time: 0.05, Ring: 0},
+		{X: -4.5, Y: 0.25, Z: 6, R: 0x10, G: 0x20, B: 0x30, Time: 0, Ring: 1},
+		{X: 10, Y: -20, Z: 0.125, Time: 0.099999999, Ring: 1},
+	}
+	wantExtra := map[string][]float64{"reflectivity": {7, 100, 65535}}
+
+	tests := []struct {
+		name      string
+		encoding  string
+		order     binary.ByteOrder
+		bigEndian bool
+	}{
+		{name: "ros1", encoding: EncodingROS1, order: binary.LittleEndian},
+		{name: "ros1 with big-endian data", encoding: EncodingROS1, order: binary.LittleEndian, bigEndian: true},
+		{name: "little-endian cdr", encoding: EncodingCDR, order: binary.LittleEndian},
+		{name: "big-endian cdr with big-endian data", encoding: EncodingCDR, order: binary.BigEndian, bigEndian: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := *ousterLayout
+			layout.bigEndian = tt.bigEndian
+
+			var dataOrder binary.ByteOrder = binary.LittleEndian
+			if tt.bigEndian {
+				dataOrder = binary.BigEndian
+			}
+
+			msg := &Message{
+				Topic:    "/points",
+				Type:     "sensor_msgs/msg/PointCloud2",
+				Encoding: tt.encoding,
+				Data:     pointCloud2(tt.encoding, tt.order, stamp, &layout, ousterData(dataOrder, ousterPoints)),
+			}
+
+			cloud, err := DecodeCloud(msg)
+			if err != nil {
+				t.Fatalf("DecodeCloud: %v", err)
+			}
+
+			if cloud.Topic != "/points" || cloud.FrameID != "lidar" || !cloud.Stamp.Equal(stamp) {
+				t.Errorf("got topic %q, frame %q and stamp %v, want /points, lidar and %v", cloud.Topic, cloud.FrameID, cloud.Stamp, stamp)
+			}
+			if cloud.Points.Len() != len(want) {
+				t.Fatalf("got %d points, want %d", cloud.Points.Len(), len(want))
+			}
+			for i, p := range cloud.Points.Raw() {
+				got := *p
+				if math.Abs(got.Time-want[i].Time) > 1e-12 {
+					t.Errorf("point %d: got time %v, want %v", i, got.Time, want[i].Time)
+				}
+				got.Time = want[i].Time
+				if got != *want[i] {
+					t.Errorf("point %d: got %+v, want %+v", i, got, *want[i])
+				}
+			}
+			if !reflect.DeepEqual(cloud.Extra, wantExtra) {
+				t.Errorf("got extra %v, want %v", cloud.Extra, wantExtra)
+			}
+		})
+	}
+}
+
+func TestDecodePointCloud2Malformed(t *testing.T) {
+	xyz := []pointField{
+		{name: "x", offset: 0, datatype: fieldFloat32, count: 1},
+		{name: "y", offset: 4, datatype: fieldFloat32, count: 1},
+		{name: "z", offset: 8, datatype: fieldFloat32, count: 1},
+	}
+	with := func(fields ...pointField) []pointField {
+		return append(append([]pointField{}, xyz...), fields...)
+	}
+
+	tests := []struct {
+		name   string
+		fields []pointField
+		data   int
+		err    string
+	}{
+		{
+			name:   "zero count field at the end of the point",
+			fields: with(pointField{name: "intensity", offset: 12, datatype: fieldFloat32, count: 0}),
+			err:    "zero count",
+		},
+		{
+			name:   "zero count rgb at the end of the point",
+			fields: with(pointField{name: "rgb", offset: 12, datatype: fieldUint32, count: 0}),
+			err:    "zero count",
+		},
+		{
+			name:   "zero count extra at the end of the point",
+			fields: with(pointField{name: "reflectivity", offset: 12, datatype: fieldUint16, count: 0}),
+			err:    "zero count",
+		},
+		{
+			name:   "field past the point step",
+			fields: with(pointField{name: "ring", offset: 11, datatype: fieldUint16, count: 1}),
+			err:    "does not fit",
+		},
+		{
+			name:   "unknown datatype",
+			fields: with(pointField{name: "ring", offset: 8, datatype: 9, count: 1}),
+			err:    "unknown datatype",
+		},
+		{
+			name:   "missing z",
+			fields: xyz[:2],
+			err:    "no x, y and z",
+		},
+		{
+			name:   "data smaller than the cloud",
+			fields: xyz,
+			data:   -1,
+			err:    "smaller than the cloud",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout := &cloudLayout{fields: tt.fields, height: 1, width: 3, pointStep: 12, rowStep: 36}
+			data := make([]byte, 36+tt.data)
+
+			msg := &Message{Type: TypePointCloud2, Encoding: EncodingROS1, Data: pointCloud2(EncodingROS1, binary.LittleEndian, time.Unix(0, 0), layout, data)}
+			if _, err := DecodeCloud(msg); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// laserScan serializes a LaserScan message.
+func laserScan(encoding string, stamp time.Time, angleMin, angleIncrement, timeIncrement, rangeMin, rangeMax float64, ranges, intensities []float64) []byte {
+	w := newMessageWriter(encoding, binary.LittleEndian)
+	w.header(stamp, "laser")
+	for _, v := range []float64{angleMin, angleMin + angleIncrement*float64(len(ranges)-1), angleIncrement, timeIncrement, 0.1, rangeMin, rangeMax} {
+		w.float32(v)
+	}
+	w.float32s(ranges)
+	w.float32s(intensities)
+	return w.b
+}
+
+func TestDecodeLaserScan(t *testing.T) {
+	stamp := time.Unix(1700000000, 0)
+	ranges := []float64{2, math.NaN(), 0.05, 4, 100}
+	intensities := []float64{10, 20, 30, 40, 50}
+
+	for _, encoding := range []string{EncodingROS1, EncodingCDR} {
+		t.Run(encoding, func(t *testing.T) {
+			msg := &Message{
+				Topic:    "/scan",
+				Type:     TypeLaserScan,
+				Encoding: encoding,
+				Data:     laserScan(encoding, stamp, -math.Pi/2, math.Pi/4, 0.001, 0.1, 30, ranges, intensities),
+			}
+
+			cloud, err := DecodeCloud(msg)
+			if err != nil {
+				t.Fatalf("DecodeCloud: %v", err)
+			}
+			if cloud.FrameID != "laser" || !cloud.Stamp.Equal(stamp) {
+				t.Errorf("got frame %q and stamp %v, want laser and %v", cloud.FrameID, cloud.Stamp, stamp)
+			}
+
+			// Only the first and fourth ranges are finite and within [0.1, 30].
+			want := []point.Point3D{
+				{X: 0, Y: -2, Intensity: 10, Time: 0},
+				{X: 4 * math.Cos(math.Pi/4), Y: 4 * math.Sin(math.Pi/4), Intensity: 40, Time: 0.003},
+			}
+			if cloud.Points.Len() != len(want) {
+				t.Fatalf("got %d points, want %d", cloud.Points.Len(), len(want))
+			}
+			for i, p := range cloud.Points.Raw() {
+				if math.Abs(p.X-want[i].X) > 1e-6 || math.Abs(p.Y-want[i].Y) > 1e-6 || p.Z != 0 ||
+					p.Intensity != want[i].Intensity || math.Abs(p.Time-want[i].Time) > 1e-9 {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLaserScanCorruptLength(t *testing.T) {
+	r := &messageReader{data: []byte{0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0}, order: binary.LittleEndian}
+	if _, err := r.float32s(); err != errShortMessage {
+		t.Fatalf("got error %v, want %v", err, errShortMessage)
+	}
+}
@@ -0,0 +1,127 @@
+package rosbag
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// MessageReader reads serialized messages from a recording.
+type MessageReader interface {
+	// Next returns the next message, or io.EOF once every message has been read.
+	Next() (*Message, error)
+}
+
+// NewMessageReader detects whether r holds a ROS 1 bag or an MCAP file.
+func NewMessageReader(r io.Reader) (MessageReader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(bagMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case string(magic) == bagMagic:
+		return NewBagReader(br)
+	case len(magic) >= len(mcapMagic) && string(magic[:len(mcapMagic)]) == mcapMagic:
+		return NewMCAPReader(br)
+	default:
+		return nil, errors.New("not a rosbag or MCAP file")
+	}
+}
+
+// CloudScanner streams the PointCloud2 and LaserScan messages of a recording as clouds.
+type CloudScanner struct {
+	reader MessageReader
+	topics map[string]bool
+	cloud  *Cloud
+	err    error
+}
+
+// NewCloudScanner reads clouds from r, which holds a ROS 1 bag or an MCAP file. Only messages on the given
+// topics are decoded; without topics every PointCloud2 and LaserScan message is.
+func NewCloudScanner(r io.Reader, topics ...string) (*CloudScanner, error) {
+	reader, err := NewMessageReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &CloudScanner{reader: reader}
+	if len(topics) > 0 {
+		s.topics = make(map[string]bool, len(topics))
+		for _, t := range topics {
+			s.topics[t] = true
+		}
+	}
+
+	return s, nil
+}
+
+// Scan advances to the next cloud.
+func (s *CloudScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		msg, err := s.reader.Next()
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+
+		if s.topics != nil && !s.topics[msg.Topic] {
+			continue
+		}
+		if t := normalizeType(msg.Type); t != TypePointCloud2 && t != TypeLaserScan {
+			continue
+		}
+
+		cloud, err := DecodeCloud(msg)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		s.cloud = cloud
+		return true
+	}
+}
+
+// Cloud returns the cloud read by the last call to Scan.
+func (s *CloudScanner) Cloud() *Cloud {
+	return s.cloud
+}
+
+// Err returns the first error encountered while scanning.
+func (s *CloudScanner) Err() error {
+	return s.err
+}
+
+// ReadClouds reads every cloud on the given topics of a ROS 1 bag or MCAP file.
+func ReadClouds(filePath string, topics ...string) ([]*Cloud, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner, err := NewCloudScanner(file, topics...)
+	if err != nil {
+		return nil, err
+	}
+
+	clouds := make([]*Cloud, 0)
+	for scanner.Scan() {
+		clouds = append(clouds, scanner.Cloud())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return clouds, nil
+}
@@ -0,0 +1,352 @@
+package xyz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// Data is the decoded content of a text file.
+type Data struct {
+	// Columns holds the attribute name of each column.
+	Columns []string
+
+	Points *point.Points3D
+
+	// Extra holds the values of columns without a point.Point3D field, keyed by column name.
+	// Each slice has one value per point.
+	Extra map[string][]float64
+}
+
+// attribute identifies the point.Point3D field a column maps onto.
+type attribute int
+
+const (
+	attributeExtra attribute = iota
+	attributeSkip
+	attributeX
+	attributeY
+	attributeZ
+	attributeNx
+	attributeNy
+	attributeNz
+	attributeIntensity
+	attributeRed
+	attributeGreen
+	attributeBlue
+	attributeTime
+	attributeRing
+	attributeLabel
+)
+
+var attributes = map[string]attribute{
+	"_":         attributeSkip,
+	"x":         attributeX,
+	"y":         attributeY,
+	"z":         attributeZ,
+	"nx":        attributeNx,
+	"ny":        attributeNy,
+	"nz":        attributeNz,
+	"normal_x":  attributeNx,
+	"normal_y":  attributeNy,
+	"normal_z":  attributeNz,
+	"i":         attributeIntensity,
+	"intensity": attributeIntensity,
+	"r":         attributeRed,
+	"g":         attributeGreen,
+	"b":         attributeBlue,
+	"red":       attributeRed,
+	"green":     attributeGreen,
+	"blue":      attributeBlue,
+	"t":         attributeTime,
+	"time":      attributeTime,
+	"timestamp": attributeTime,
+	"ring":      attributeRing,
+	"label":     attributeLabel,
+}
+
+// Decode reads a text stream and crops its points according to opts.
+func Decode(r io.Reader, opts *ReadOptions) (*point.Points3D, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	data, err := DecodeAll(r, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Points.Crop(&opts.CropOptions), nil
+}
+
+// DecodeAll reads every row of a text stream.
+func DecodeAll(r io.Reader, opts *ReadOptions) (*Data, error) {
+	if opts == nil {
+		opts = DefaultReadOptions
+	}
+
+	comment := opts.Comment
+	if comment == "" {
+		comment = "#"
+	}
+
+	scanner := bufio.NewScanner(r)
+
+	var (
+		split   func(string) []string
+		mapping []attribute
+		data    = &Data{Extra: make(map[string][]float64)}
+		points  = make(point.Points3D, 0)
+		skipped = 0
+		row     = 0
+
+		// Raw values of the red, green and blue columns, converted once each column is complete. Nil for
+		// channels without a column.
+		colors [3][]float64
+	)
+
+	for scanner.Scan() {
+		row++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, comment) {
+			continue
+		}
+		if skipped < opts.SkipRows {
+			skipped++
+			continue
+		}
+
+		if split == nil {
+			split = splitter(opts.Delimiter, line)
+		}
+		tokens := split(line)
+
+		if mapping == nil {
+			var header []string
+			if !isNumeric(tokens[0]) {
+				header = make([]string, len(tokens))
+				for i, t := range tokens {
+					header[i] = strings.ToLower(strings.Trim(t, `"'`))
+				}
+			}
+
+			columns, err := resolveColumns(opts.Columns, header)
+			if err != nil {
+				return nil, err
+			}
+
+			data.Columns = columns
+			mapping = make([]attribute, len(columns))
+			for i, name := range columns {
+				mapping[i] = attributes[name]
+				switch mapping[i] {
+				case attributeExtra:
+					data.Extra[name] = make([]float64, 0)
+				case attributeRed, attributeGreen, attributeBlue:
+					colors[mapping[i]-attributeRed] = make([]float64, 0)
+				}
+			}
+
+			if header != nil {
+				continue
+			}
+		}
+
+		if len(tokens) < len(mapping) {
+			return nil, fmt.Errorf("row %d has %d columns, expected %d", row, len(tokens), len(mapping))
+		}
+
+		n := len(points)
+		for c := range colors {
+			if colors[c] != nil {
+				colors[c] = append(colors[c], 0)
+			}
+		}
+
+		p := &point.Point3D{}
+		for i, a := range mapping {
+			if a == attributeSkip {
+				continue
+			}
+
+			v, err := strconv.ParseFloat(strings.Trim(tokens[i], `"'`), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d column %d: %w", row, i, err)
+			}
+
+			switch a {
+			case attributeX:
+				p.X = v
+			case attributeY:
+				p.Y = v
+			case attributeZ:
+				p.Z = v
+			case attributeNx:
+				p.Nx = v
+			case attributeNy:
+				p.Ny = v
+			case attributeNz:
+				p.Nz = v
+			case attributeIntensity:
+				p.Intensity = v
+			case attributeRed, attributeGreen, attributeBlue:
+				colors[a-attributeRed][n] = v
+			case attributeTime:
+				p.Time = v
+			case attributeRing:
+				p.Ring = int(v)
+			case attributeLabel:
+				p.Label = int(v)
+			default:
+				name := data.Columns[i]
+				data.Extra[name] = append(data.Extra[name], v)
+			}
+		}
+		points = append(points, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	setColors(points, colors, opts.ColorScale)
+	data.Points = &points
+
+	return data, nil
+}
+
+// resolveColumns returns the attribute name of each column from the mapping in spec, falling back to the
+// header row when spec is empty.
+func resolveColumns(spec string, header []string) ([]string, error) {
+	var columns []string
+
+	switch {
+	case strings.TrimSpace(spec) != "":
+		fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == ';' })
+
+		indexed := strings.Contains(spec, "=")
+		for _, f := range fields {
+			if !indexed {
+				columns = append(columns, strings.ToLower(f))
+				continue
+			}
+
+			name, index, ok := strings.Cut(f, "=")
+			if !ok {
+				return nil, fmt.Errorf("column %q is missing an index", f)
+			}
+			i, err := strconv.Atoi(index)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index for column %q", f)
+			}
+			for len(columns) <= i {
+				columns = append(columns, "_")
+			}
+			if columns[i] != "_" {
+				return nil, fmt.Errorf("column %d is mapped twice", i)
+			}
+			columns[i] = strings.ToLower(name)
+		}
+	case header != nil:
+		columns = header
+	default:
+		columns = []string{"x", "y", "z"}
+	}
+
+	for _, required := range []string{"x", "y", "z"} {
+		found := false
+		for _, c := range columns {
+			found = found || c == required
+		}
+		if !found {
+			return nil, fmt.Errorf("no column mapped to %q", required)
+		}
+	}
+
+	seen := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		if c != "_" && seen[c] {
+			return nil, fmt.Errorf("attribute %q is mapped to more than one column", c)
+		}
+		seen[c] = true
+	}
+
+	return columns, nil
+}
+
+// splitter returns a function splitting rows on delim, or on the delimiter detected from line if delim is zero.
+func splitter(delim rune, line string) func(string) []string {
+	if delim == 0 {
+		switch {
+		case strings.ContainsRune(line, '\t'):
+			delim = '\t'
+		case strings.ContainsRune(line, ','):
+			delim = ','
+		case strings.ContainsRune(line, ';'):
+			delim = ';'
+		default:
+			delim = ' '
+		}
+	}
+
+	if delim == ' ' {
+		return strings.Fields
+	}
+
+	sep := string(delim)
+	return func(line string) []string {
+		tokens := strings.Split(line, sep)
+		for i := range tokens {
+			tokens[i] = strings.TrimSpace(tokens[i])
+		}
+		return tokens
+	}
+}
+
+func isNumeric(token string) bool {
+	_, err := strconv.ParseFloat(strings.Trim(token, `"'`), 64)
+	return err == nil
+}
+
+// setColors converts the red, green and blue columns to 8 bits, multiplying them by scale. A zero scale is
+// decided from every color column together, as the channels of a cloud share an encoding: 255 if all their
+// values lie in [0, 1], so the colors are taken as normalized, and 1 otherwise.
+func setColors(points point.Points3D, colors [3][]float64, scale float64) {
+	if scale == 0 {
+		scale = 255
+		for _, values := range colors {
+			for _, v := range values {
+				if v < 0 || v > 1 {
+					scale = 1
+				}
+			}
+		}
+	}
+
+	for c, values := range colors {
+		if values == nil {
+			continue
+		}
+
+		for i, p := range points {
+			v := toColor(values[i] * scale)
+			switch c {
+			case 0:
+				p.R = v
+			case 1:
+				p.G = v
+			default:
+				p.B = v
+			}
+		}
+	}
+}
+
+// toColor converts a color channel to 8 bits.
+func toColor(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
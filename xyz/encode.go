@@ -0,0 +1,120 @@
+package xyz
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// column is a named column whose value is computed for the ith point.
+type column struct {
+	name  string
+	value func(i int, p *point.Point3D) float64
+}
+
+// Encode writes points to w as delimited text. Normals, intensity and color are only written when at least
+// one point has them populated.
+func Encode(w io.Writer, points *point.Points3D, opts *WriteOptions) error {
+	if opts == nil {
+		opts = DefaultWriteOptions
+	}
+
+	columns, err := writeColumns(points, opts.Extra)
+	if err != nil {
+		return err
+	}
+
+	delim := opts.Delimiter
+	if delim == 0 {
+		delim = ' '
+	}
+
+	bw := bufio.NewWriter(w)
+
+	if opts.Header {
+		for j, c := range columns {
+			if j > 0 {
+				bw.WriteRune(delim)
+			}
+			bw.WriteString(c.name)
+		}
+		bw.WriteByte('\n')
+	}
+
+	var buf []byte
+	for i, p := range points.Raw() {
+		buf = buf[:0]
+		for j, c := range columns {
+			if j > 0 {
+				buf = append(buf, string(delim)...)
+			}
+			buf = strconv.AppendFloat(buf, c.value(i, p), 'g', -1, 64)
+		}
+		buf = append(buf, '\n')
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+func writeColumns(points *point.Points3D, extra map[string][]float64) ([]column, error) {
+	columns := []column{
+		{"x", func(_ int, p *point.Point3D) float64 { return p.X }},
+		{"y", func(_ int, p *point.Point3D) float64 { return p.Y }},
+		{"z", func(_ int, p *point.Point3D) float64 { return p.Z }},
+	}
+
+	a := points.Attributes()
+
+	if a.Normals {
+		columns = append(columns,
+			column{"nx", func(_ int, p *point.Point3D) float64 { return p.Nx }},
+			column{"ny", func(_ int, p *point.Point3D) float64 { return p.Ny }},
+			column{"nz", func(_ int, p *point.Point3D) float64 { return p.Nz }},
+		)
+	}
+	if a.Intensity {
+		columns = append(columns, column{"intensity", func(_ int, p *point.Point3D) float64 { return p.Intensity }})
+	}
+	if a.Color {
+		columns = append(columns,
+			column{"red", func(_ int, p *point.Point3D) float64 { return float64(p.R) }},
+			column{"green", func(_ int, p *point.Point3D) float64 { return float64(p.G) }},
+			column{"blue", func(_ int, p *point.Point3D) float64 { return float64(p.B) }},
+		)
+	}
+	if a.Time {
+		columns = append(columns, column{"time", func(_ int, p *point.Point3D) float64 { return p.Time }})
+	}
+	if a.Ring {
+		columns = append(columns, column{"ring", func(_ int, p *point.Point3D) float64 { return float64(p.Ring) }})
+	}
+	if a.Label {
+		columns = append(columns, column{"label", func(_ int, p *point.Point3D) float64 { return float64(p.Label) }})
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := extra[name]
+		if len(values) != points.Len() {
+			return nil, fmt.Errorf("extra column %q has %d values for %d points", name, len(values), points.Len())
+		}
+		if _, ok := attributes[name]; ok {
+			return nil, fmt.Errorf("extra column %q clashes with a point attribute", name)
+		}
+		columns = append(columns, column{name, func(i int, _ *point.Point3D) float64 { return values[i] }})
+	}
+
+	return columns, nil
+}
@@ -0,0 +1,90 @@
+package xyz
+
+import (
+	"os"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+type ReadOptions struct {
+	// Cropping applied to the points once read. The zero value keeps every point.
+	point.CropOptions
+
+	// Mapping of columns onto attributes. Either names in column order, e.g. "x,y,z,intensity", or
+	// name=index pairs using zero-based indices, e.g. "x=3,y=4,z=5". Columns named "_" are ignored and names
+	// without a point.Point3D field are returned in Data.Extra. Empty uses the header row when the file has
+	// one and "x,y,z" otherwise.
+	Columns string `json:"columns"`
+
+	// Column separator. Zero detects tabs, commas, semicolons or runs of spaces from the first row.
+	Delimiter rune `json:"delimiter"`
+
+	// Rows starting with this prefix are skipped. Empty defaults to "#".
+	Comment string `json:"comment"`
+
+	// Number of rows to skip before reading, not counting comments. A non-numeric first row is always
+	// treated as a header row.
+	SkipRows int `json:"skipRows"`
+
+	// Factor color columns are multiplied by to give 8 bit values, e.g. 255 for colors normalized to [0, 1].
+	// Zero detects it from the color columns together: colors whose values all lie in [0, 1] are taken as
+	// normalized.
+	ColorScale float64 `json:"colorScale"`
+}
+
+// DefaultReadOptions reads x, y, z from the first three columns unless the file has a header row.
+var DefaultReadOptions *ReadOptions = &ReadOptions{}
+
+type WriteOptions struct {
+	// Column separator. Zero writes a single space.
+	Delimiter rune `json:"delimiter"`
+
+	// Write a header row naming the columns.
+	Header bool `json:"header"`
+
+	// Additional per-point columns to write after the point attributes, keyed by column name.
+	// Each slice must hold one value per point.
+	Extra map[string][]float64 `json:"-"`
+}
+
+var DefaultWriteOptions *WriteOptions = &WriteOptions{}
+
+// CSVWriteOptions writes comma separated values with a header row.
+var CSVWriteOptions *WriteOptions = &WriteOptions{Delimiter: ',', Header: true}
+
+// Read reads the points of a text file and crops them according to opts.
+func Read(filePath string, opts *ReadOptions) (*point.Points3D, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Decode(file, opts)
+}
+
+// ReadAll reads every row of a text file, returning the columns without a point.Point3D field.
+func ReadAll(filePath string, opts *ReadOptions) (*Data, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return DecodeAll(file, opts)
+}
+
+// Write writes points to a text file, replacing any existing file.
+func Write(filePath string, points *point.Points3D, opts *WriteOptions) error {
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := Encode(file, points, opts); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
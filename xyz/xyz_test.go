@@ -0,0 +1,206 @@
+package xyz
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flynnletford/icp-go/point"
+)
+
+// textPoints returns points with every attribute populated. Positions need every significant digit or an
+// exponent to be written exactly, and colors go above 1 so that they are not taken as normalized when read back.
+func textPoints() *point.Points3D {
+	points := point.Points3D{
+		{X: 500000.123456, Y: 5400000.654321, Z: 30.5, Nx: 0, Ny: 0, Nz: 1, Intensity: 10, R: 255, G: 128, B: 1, Time: 1.5e9 + 0.123456, Ring: 3, Label: -2},
+		{X: -1.5, Y: 0.1, Z: 8, Nx: 1, Ny: 0, Nz: 0, Intensity: 0.5, R: 0, G: 0, B: 7, Time: 1.5e9 + 0.5, Ring: 0, Label: 7},
+		{X: 0, Y: -4, Z: 1e-9, Nx: 0, Ny: -1, Nz: 0, Intensity: 0, R: 12, G: 34, B: 56, Time: 1.5e9 + 0.75, Ring: 31, Label: 0},
+	}
+	return &points
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	extra := map[string][]float64{
+		"residual":  {0.5, 0.25, 0},
+		"scalar_id": {1, 0, 1},
+	}
+
+	tests := []struct {
+		name      string
+		delimiter rune
+	}{
+		{name: "space", delimiter: 0},
+		{name: "comma", delimiter: ','},
+		{name: "tab", delimiter: '\t'},
+		{name: "semicolon", delimiter: ';'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := textPoints()
+
+			var buf bytes.Buffer
+			if err := Encode(&buf, points, &WriteOptions{Delimiter: tt.delimiter, Header: true, Extra: extra}); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+
+			data, err := DecodeAll(&buf, nil)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			wantColumns := []string{"x", "y", "z", "nx", "ny", "nz", "intensity", "red", "green", "blue", "time", "ring", "label", "residual", "scalar_id"}
+			if !reflect.DeepEqual(data.Columns, wantColumns) {
+				t.Errorf("got columns %v, want %v", data.Columns, wantColumns)
+			}
+			if !reflect.DeepEqual(data.Points, points) {
+				t.Errorf("got points %v, want %v", data.Points, points)
+			}
+			if !reflect.DeepEqual(data.Extra, extra) {
+				t.Errorf("got extra %v, want %v", data.Extra, extra)
+			}
+		})
+	}
+}
+
+func TestEncodeWithoutHeader(t *testing.T) {
+	points := point.Points3D{{X: 1, Y: 2, Z: 3}, {X: -4, Y: 5.5, Z: 0}}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, &points, nil); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if got, want := buf.String(), "1 2 3\n-4 5.5 0\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	decoded, err := Decode(&buf, nil)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !reflect.DeepEqual(*decoded, points) {
+		t.Errorf("got %v, want %v", *decoded, points)
+	}
+}
+
+func TestDecodeHeader(t *testing.T) {
+	const file = "\"X\",\"Y\",\"Z\",Normal_X,normal_y,NZ,I,Red,g,B,Timestamp,Ring,Class,Label,_\n" +
+		"1,2,3,0,0.6,0.8,40,200,100,0,1.5e9,12,6,3,ignored\n"
+
+	data, err := DecodeAll(strings.NewReader(file), nil)
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+
+	// Names are matched case-insensitively through their aliases, and columns without a field are kept by name.
+	wantColumns := []string{"x", "y", "z", "normal_x", "normal_y", "nz", "i", "red", "g", "b", "timestamp", "ring", "class", "label", "_"}
+	if !reflect.DeepEqual(data.Columns, wantColumns) {
+		t.Errorf("got columns %v, want %v", data.Columns, wantColumns)
+	}
+
+	want := point.Point3D{X: 1, Y: 2, Z: 3, Ny: 0.6, Nz: 0.8, Intensity: 40, R: 200, G: 100, Time: 1.5e9, Ring: 12, Label: 3}
+	if data.Points.Len() != 1 || *data.Points.Raw()[0] != want {
+		t.Errorf("got %v, want %+v", *data.Points, want)
+	}
+	if got, want := data.Extra, map[string][]float64{"class": {6}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got extra %v, want %v", got, want)
+	}
+
+	// Columns override the names of a header, which is still skipped.
+	data, err = DecodeAll(strings.NewReader("x y z\n1 2 3\n"), &ReadOptions{Columns: "z y x"})
+	if err != nil {
+		t.Fatalf("DecodeAll: %v", err)
+	}
+	if want := []point.Point3D{{X: 3, Y: 2, Z: 1}}; data.Points.Len() != 1 || *data.Points.Raw()[0] != want[0] {
+		t.Errorf("got %v with the header overridden, want %+v", *data.Points, want)
+	}
+}
+
+func TestDecodeOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		opts  *ReadOptions
+		want  []point.Point3D
+		extra map[string][]float64
+	}{
+		{
+			name: "indexed columns skipping rows and comments",
+			file: "exported by scanner\n# comment\n7;1;2;3;0.5\n8;4;5;6;0.25\n",
+			opts: &ReadOptions{Columns: "x=1,y=2,z=3,intensity=4,id=0", SkipRows: 1},
+			want: []point.Point3D{
+				{X: 1, Y: 2, Z: 3, Intensity: 0.5},
+				{X: 4, Y: 5, Z: 6, Intensity: 0.25},
+			},
+			extra: map[string][]float64{"id": {7, 8}},
+		},
+		{
+			name: "quoted header with an ignored column",
+			file: "\"X\",\"Y\",\"Z\",\"_\"\n1,2,3,x\n",
+			want: []point.Point3D{{X: 1, Y: 2, Z: 3}},
+		},
+		{
+			name: "normalized colors",
+			file: "x y z r g b\n0 0 0 1 0.5 0\n1 1 1 0 0 1\n",
+			want: []point.Point3D{{R: 255, G: 128}, {X: 1, Y: 1, Z: 1, B: 255}},
+		},
+		{
+			name: "colors not normalized when any channel exceeds 1",
+			file: "x y z r g b\n0 0 0 1 0 200\n",
+			want: []point.Point3D{{R: 1, B: 200}},
+		},
+		{
+			name: "explicit color scale",
+			file: "0 0 0 1 0.5 0\n",
+			opts: &ReadOptions{Columns: "x y z r g b", ColorScale: 100},
+			want: []point.Point3D{{R: 100, G: 50}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := DecodeAll(strings.NewReader(tt.file), tt.opts)
+			if err != nil {
+				t.Fatalf("DecodeAll: %v", err)
+			}
+
+			if data.Points.Len() != len(tt.want) {
+				t.Fatalf("got %d points, want %d", data.Points.Len(), len(tt.want))
+			}
+			for i, p := range data.Points.Raw() {
+				if *p != tt.want[i] {
+					t.Errorf("point %d: got %+v, want %+v", i, *p, tt.want[i])
+				}
+			}
+			if tt.extra == nil {
+				tt.extra = map[string][]float64{}
+			}
+			if !reflect.DeepEqual(data.Extra, tt.extra) {
+				t.Errorf("got extra %v, want %v", data.Extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		opts *ReadOptions
+	}{
+		{name: "missing z column", file: "x,y\n1,2\n"},
+		{name: "column mapped twice", file: "1 2 3\n", opts: &ReadOptions{Columns: "x=0,y=1,z=1"}},
+		{name: "attribute mapped twice", file: "1 2 3 4\n", opts: &ReadOptions{Columns: "x,y,z,x"}},
+		{name: "short row", file: "1 2 3\n4 5\n"},
+		{name: "non-numeric value", file: "1 2 3\n4 five 6\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeAll(strings.NewReader(tt.file), tt.opts); err == nil {
+				t.Errorf("got no error")
+			}
+		})
+	}
+}